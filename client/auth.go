@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// apiKeyCredentials Sends an API key as a bearer token with every request
+type apiKeyCredentials struct {
+	key    string
+	secure bool
+}
+
+func (c apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.key}, nil
+}
+
+func (c apiKeyCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+// dialCredentials returns the dial options for authenticating with the server,
+// configured from the environment:
+//
+// GEDOC_CA_FILE enables TLS, trusting the server's certificate if signed by it
+// GEDOC_CERT_FILE and GEDOC_KEY_FILE present a client certificate
+// GEDOC_API_KEY sends an API key with every request
+func dialCredentials() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	caFile := os.Getenv("GEDOC_CA_FILE")
+
+	if caFile == "" {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+		if certFile := os.Getenv("GEDOC_CERT_FILE"); certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, os.Getenv("GEDOC_KEY_FILE"))
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if key := os.Getenv("GEDOC_API_KEY"); key != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(apiKeyCredentials{key: key, secure: caFile != ""}))
+	}
+
+	return opts, nil
+}
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	pb "github.com/episub/gedoc/gedoc/lib"
-	"github.com/opentracing/opentracing-go"
 	"golang.org/x/net/context"
 )
 
@@ -17,23 +16,18 @@ const (
 )
 
 func main() {
-	// Opentracing
-	tracer, closer := initJaeger("gRPCclient")
-	defer closer.Close()
-
-	// StartSpanFromContext uses the global tracer, so we need to set it here to
-	// be our jaeger tracer
-	opentracing.SetGlobalTracer(tracer)
-
 	ctx := context.Background()
 
+	shutdownTracing := initTracing(ctx, "gRPCclient")
+	defer shutdownTracing(ctx)
+
 	mergeFiles(ctx)
 	fetchPDF(ctx)
 }
 
 func mergeFiles(ctx context.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "mergeFiles")
-	defer span.Finish()
+	ctx, span := tracer.Start(ctx, "mergeFiles")
+	defer span.End()
 
 	// Set up a connection to the server.
 	conn, err := createClientGRPCConn(ctx, address)
@@ -71,8 +65,8 @@ func mergeFiles(ctx context.Context) {
 }
 
 func fetchPDF(ctx context.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "fetchPDF")
-	defer span.Finish()
+	ctx, span := tracer.Start(ctx, "fetchPDF")
+	defer span.End()
 
 	// Set up a connection to the server.
 	conn, err := createClientGRPCConn(ctx, address)
@@ -111,8 +105,8 @@ func fetchPDF(ctx context.Context) {
 
 // loadFiles loads all files in the listed folder, returning their bytes in an array
 func loadFiles(ctx context.Context, folder string) ([]*pb.File, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "loadFiles")
-	defer span.Finish()
+	ctx, span := tracer.Start(ctx, "loadFiles")
+	defer span.End()
 
 	var files []*pb.File
 	fileNames, err := ioutil.ReadDir(folder)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+)
+
+var tracer = otel.Tracer("github.com/episub/gedoc/client")
+
+// initTracing exports spans with the exporter named by GEDOC_TRACE_EXPORTER,
+// returning a function that flushes them.  otlp, the default, is configured
+// with the standard OTEL_EXPORTER_OTLP_* variables, stdout prints spans and
+// none disables tracing
+func initTracing(ctx context.Context, service string) func(context.Context) error {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch name := os.Getenv("GEDOC_TRACE_EXPORTER"); name {
+	case "", "otlp":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "none":
+		return func(context.Context) error { return nil }
+	default:
+		log.Fatalf("unknown trace exporter %q", name)
+	}
+	if err != nil {
+		log.Fatalf("cannot init tracing: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// Don't complain when no collector is running
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(error) {}))
+
+	return provider.Shutdown
+}
+
+// ctx is the incoming gRPC request's context
+// addr is the address for the new outbound request
+func createClientGRPCConn(ctx context.Context, addr string) (*grpc.ClientConn, error) {
+	_, span := tracer.Start(ctx, "createGRPCConn")
+	defer span.End()
+
+	opts, err := dialCredentials()
+	if err != nil {
+		log.Println("Failed to load credentials: ", err)
+		return nil, err
+	}
+
+	opts = append(opts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		log.Println("Failed to connect to application addr: ", err)
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,166 @@
+// Package builder compiles LaTeX to PDF, converts images to PDF and merges
+// PDFs, using latexmk, qpdf, ImageMagick and Tesseract.  It holds the logic
+// behind the gedoc server, so that batch jobs can use it without running one
+package builder
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+)
+
+// tracer creates every span in the package.  It uses the global provider, so
+// spans are exported alongside the caller's own
+var tracer = otel.Tracer("github.com/episub/gedoc/gedoc/builder")
+
+// Work The kinds of heavy work a Limiter is asked to make room for
+type Work string
+
+const (
+	WorkLatex Work = "latex"
+	WorkImage Work = "image"
+	WorkMerge Work = "merge"
+)
+
+// Limiter Limits how much work runs at once.  Acquire waits for room for work,
+// returning a function that frees it again.  Its errors are returned as they
+// are, and never cause a merged file to be skipped
+type Limiter interface {
+	Acquire(ctx context.Context, work Work) (func(), error)
+}
+
+// unlimited A Limiter that never waits
+type unlimited struct{}
+
+func (unlimited) Acquire(context.Context, Work) (func(), error) {
+	return func() {}, nil
+}
+
+// LimitError An error from the Limiter, kept apart from errors about files
+type LimitError struct {
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// File An input file, named as it should be written when building LaTeX.
+// Folder, when set, is the directory beneath the build directory it is
+// written to
+type File struct {
+	Name   string
+	Folder string
+	Data   []byte
+}
+
+// Builder Builds and merges PDFs.  Create one with New; it is safe for
+// concurrent use
+type Builder struct {
+	runner           Runner
+	latexRunner      Runner
+	latexEngine      []string
+	limiter          Limiter
+	nativeImages     bool
+	mergeParallelism int
+	tempDir          string
+	log              zerolog.Logger
+	observeLatexmk   func(output []byte, duration time.Duration)
+	cleanupFailed    func(err error)
+}
+
+// Option Configures a Builder
+type Option func(*Builder)
+
+// New returns a Builder that runs tools directly, without limits, converting
+// images natively where it can
+func New(opts ...Option) *Builder {
+	b := &Builder{
+		runner:       ExecRunner,
+		latexEngine:  LatexEngine,
+		limiter:      unlimited{},
+		nativeImages: true,
+		log:          log.Logger,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.latexRunner == nil {
+		b.latexRunner = b.runner
+	}
+
+	return b
+}
+
+// WithRunner runs every tool with r
+func WithRunner(r Runner) Option {
+	return func(b *Builder) { b.runner = r }
+}
+
+// WithLatexRunner runs latexmk with r, rather than the runner used for other
+// tools, so that untrusted LaTeX can be confined
+func WithLatexRunner(r Runner) Option {
+	return func(b *Builder) { b.latexRunner = r }
+}
+
+// WithLatexEngine sets the program latexmk runs to compile documents and its
+// arguments, which default to LatexEngine
+func WithLatexEngine(engine ...string) Option {
+	return func(b *Builder) { b.latexEngine = engine }
+}
+
+// WithLimiter waits for room from l before starting heavy work
+func WithLimiter(l Limiter) Option {
+	return func(b *Builder) { b.limiter = l }
+}
+
+// WithNativeImages sets whether images are converted without ImageMagick
+// where possible
+func WithNativeImages(enabled bool) Option {
+	return func(b *Builder) { b.nativeImages = enabled }
+}
+
+// WithMergeParallelism limits how many files of a single merge are prepared at
+// once.  Zero uses the number of CPUs
+func WithMergeParallelism(n int) Option {
+	return func(b *Builder) { b.mergeParallelism = n }
+}
+
+// WithTempDir creates working directories beneath dir rather than the default
+// temporary directory
+func WithTempDir(dir string) Option {
+	return func(b *Builder) { b.tempDir = dir }
+}
+
+// WithLogger logs to l rather than the global zerolog logger
+func WithLogger(l zerolog.Logger) Option {
+	return func(b *Builder) { b.log = l }
+}
+
+// WithLatexmkObserver calls observe with the output and duration of each
+// latexmk build, successful or not
+func WithLatexmkObserver(observe func(output []byte, duration time.Duration)) Option {
+	return func(b *Builder) { b.observeLatexmk = observe }
+}
+
+// WithCleanupObserver calls observe whenever a working directory can't be
+// removed
+func WithCleanupObserver(observe func(err error)) Option {
+	return func(b *Builder) { b.cleanupFailed = observe }
+}
+
+// acquire waits for room for work from the limiter
+func (b *Builder) acquire(ctx context.Context, work Work) (func(), error) {
+	release, err := b.limiter.Acquire(ctx, work)
+	if err != nil {
+		return nil, &LimitError{err}
+	}
+	return release, nil
+}
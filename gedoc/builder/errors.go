@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ErrorKind Classifies failures, so that callers can tell problems with their
+// input apart from problems with the tools
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	// KindInvalidInput The request itself is at fault
+	KindInvalidInput
+	// KindUnsupportedFile One of the provided files can't be used
+	KindUnsupportedFile
+	// KindCompile The LaTeX source failed to compile
+	KindCompile
+	// KindToolMissing An external tool couldn't be run
+	KindToolMissing
+	// KindTimeout The context's deadline passed while a tool was running
+	KindTimeout
+	// KindResourceExhausted There isn't room to take on the work
+	KindResourceExhausted
+	// KindSandbox A confined tool broke one of its restrictions
+	KindSandbox
+	// KindPermissionDenied The input referred to something it may not use
+	KindPermissionDenied
+	// KindUnavailable Something the input depends on couldn't be reached
+	KindUnavailable
+	// KindNotFound The input referred to something that doesn't exist
+	KindNotFound
+)
+
+// Error A classified failure, with any details useful to the caller
+type Error struct {
+	Kind ErrorKind
+	Err  error
+	// Field identifies the offending part of the input, such as files[2].data
+	Field string
+	// Diagnostics lists errors reported by TeX
+	Diagnostics []Diagnostic
+	// Output and Log hold latexmk's output and the TeX log of a failed compile
+	Output []byte
+	Log    []byte
+	// Violation names the restriction a confined tool broke
+	Violation string
+}
+
+// Diagnostic An error reported while compiling LaTeX
+type Diagnostic struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// invalidInput returns an error for a problem with the input
+func invalidInput(format string, args ...interface{}) error {
+	return &Error{Kind: KindInvalidInput, Err: fmt.Errorf(format, args...)}
+}
+
+// FileErrorCode Describes why a file couldn't be merged
+type FileErrorCode int
+
+const (
+	CodeNone FileErrorCode = iota
+	CodeUnsupportedType
+	CodeConversionFailed
+	CodeOCRFailed
+	CodeDamaged
+	CodeRepairNotAllowed
+	CodeSanitizeFailed
+	CodePageCountFailed
+	CodePaddingFailed
+	CodeInternal
+)
+
+// FileError An error preparing one of the files to merge
+type FileError struct {
+	Code FileErrorCode
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// fileErrorCode returns the code describing err, which may be a FileError
+func fileErrorCode(err error) FileErrorCode {
+	if fe, ok := err.(*FileError); ok {
+		return fe.Code
+	}
+	return CodeInternal
+}
+
+// fileFailure classifies an error preparing the file at index for merging
+func fileFailure(index int, err error) error {
+	e, ok := fileError(err)
+	if !ok {
+		return err
+	}
+	e.Field = fmt.Sprintf("files[%d].data", index)
+	return e
+}
+
+// fileError classifies an error preparing a file by its FileErrorCode.  It
+// returns false when err is already an Error or a LimitError
+func fileError(err error) (*Error, bool) {
+	var e *Error
+	var le *LimitError
+	if errors.As(err, &e) || errors.As(err, &le) {
+		return nil, false
+	}
+
+	kind := KindInternal
+	switch fileErrorCode(err) {
+	case CodeUnsupportedType, CodeConversionFailed, CodeDamaged, CodeRepairNotAllowed:
+		kind = KindUnsupportedFile
+	}
+
+	return &Error{Kind: kind, Err: err}, true
+}
+
+// commandError classifies the error from running an external tool
+func commandError(ctx context.Context, name string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return &Error{Kind: KindToolMissing, Err: fmt.Errorf("%s is not available: %w", name, err)}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return &Error{Kind: KindTimeout, Err: fmt.Errorf("%s timed out: %w", name, ctx.Err())}
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// Runner Runs the external tools a Builder needs.  Run starts cmd and waits
+// for it to finish, like cmd.Run, and may first adjust cmd to confine it, or
+// observe how it went
+type Runner interface {
+	Run(ctx context.Context, cmd *exec.Cmd) error
+}
+
+// RunnerFunc Adapts a function to a Runner
+type RunnerFunc func(ctx context.Context, cmd *exec.Cmd) error
+
+func (f RunnerFunc) Run(ctx context.Context, cmd *exec.Cmd) error {
+	return f(ctx, cmd)
+}
+
+// ExecRunner runs commands as they are
+var ExecRunner Runner = RunnerFunc(func(_ context.Context, cmd *exec.Cmd) error {
+	return cmd.Run()
+})
+
+// Command returns the command that runs an external tool in dir.  The process
+// is killed if ctx is cancelled before it completes, and is given a fixed date
+// when ctx is reproducible
+func Command(ctx context.Context, dir string, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	if env := ReproducibleEnv(ctx); env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+// Run runs cmd with r, returning its combined output.  Missing tools and
+// timeouts are reported as an Error
+func Run(ctx context.Context, r Runner, cmd *exec.Cmd) ([]byte, error) {
+	name := cmd.Args[0]
+	var output lockedBuffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := r.Run(ctx, cmd); err != nil {
+		return output.Bytes(), commandError(ctx, name, err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// Output runs cmd with r like Run, but returns only its standard output.
+// Standard error is included in any error
+func Output(ctx context.Context, r Runner, cmd *exec.Cmd) ([]byte, error) {
+	name := cmd.Args[0]
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := r.Run(ctx, cmd); err != nil {
+		return stdout.Bytes(), fmt.Errorf("%w: %s", commandError(ctx, name, err), stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// RunTool runs an external tool in dir with the Builder's runner, returning
+// its combined output
+func (b *Builder) RunTool(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	return Run(ctx, b.runner, Command(ctx, dir, name, args...))
+}
+
+// ToolOutput runs an external tool in dir with the Builder's runner,
+// returning its standard output
+func (b *Builder) ToolOutput(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	return Output(ctx, b.runner, Command(ctx, dir, name, args...))
+}
+
+// ExitCode returns the exit code of a command that failed with err, which may
+// be wrapped, or -1 if it didn't exit normally
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// lockedBuffer A buffer that standard output and error may be written to at
+// the same time
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// WorkDir creates a temporary directory, returning it along with a function
+// that removes it again
+func (b *Builder) WorkDir(prefix string) (string, func(), error) {
+	directory, err := ioutil.TempDir(b.tempDir, prefix)
+	if err != nil {
+		return "", nil, err
+	}
+	directoryLogger := b.log.With().Str("directory", directory).Logger()
+	directoryLogger.Info().Msg("temp directory created")
+
+	return directory, func() {
+		directoryLogger.Info().Msg("removing temp directory")
+		err := os.RemoveAll(directory)
+		if err != nil {
+			if b.cleanupFailed != nil {
+				b.cleanupFailed(err)
+			}
+			directoryLogger.Error().Err(err).Msg("temp directory")
+		}
+	}, nil
+}
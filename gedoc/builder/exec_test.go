@@ -0,0 +1,31 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+// TestExitCode Checks the exit code is found through wrapped errors
+func TestExitCode(t *testing.T) {
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	if err == nil {
+		t.Fatal("Expected the command to fail")
+	}
+
+	tests := map[string]struct {
+		err  error
+		code int
+	}{
+		"exit error": {err, 3},
+		"wrapped":    {fmt.Errorf("running tool: %w", err), 3},
+		"other":      {errors.New("not started"), -1},
+	}
+
+	for name, test := range tests {
+		if code := ExitCode(test.err); code != test.code {
+			t.Errorf("%s: expected exit code %d, got %d", name, test.code, code)
+		}
+	}
+}
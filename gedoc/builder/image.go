@@ -0,0 +1,349 @@
+package builder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+
+	"github.com/h2non/filetype"
+)
+
+// A4 page size in points, matching the page produced by ImageMagick
+const (
+	a4Width  = 595.0
+	a4Height = 842.0
+)
+
+// maxImagePixels limits the size of PNGs that are decoded, so that a small,
+// highly compressed file can't exhaust memory.  It allows A4 scans at 600dpi
+const maxImagePixels = 50000000
+
+// errImageTooLarge is returned for images with more than maxImagePixels
+var errImageTooLarge = errors.New("image is too large")
+
+// errNativeUnsupported is returned when an image can't be embedded natively,
+// and should instead be converted by ImageMagick
+var errNativeUnsupported = errors.New("image not supported by native conversion")
+
+// pdfImage is an image ready to be embedded in a PDF as an XObject
+type pdfImage struct {
+	width      int
+	height     int
+	colorSpace string
+	bits       int
+	filter     string
+	data       []byte
+	alpha      []byte // Flate compressed soft mask, if any
+}
+
+// ImageToPDF places a jpg or png image on an A4 page, scaled to fit
+func (b *Builder) ImageToPDF(ctx context.Context, img []byte) ([]byte, error) {
+	kind, err := filetype.Match(img)
+	if err != nil || (kind.Extension != "jpg" && kind.Extension != "png") {
+		return nil, &Error{Kind: KindUnsupportedFile, Err: errors.New("image is not a jpg or png")}
+	}
+
+	release, err := b.acquire(ctx, WorkImage)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return b.convertImage(ctx, img, kind.Extension)
+}
+
+// convertImage converts a jpg or png image to a single page PDF, using the
+// native converter when enabled and falling back to ImageMagick otherwise
+func (b *Builder) convertImage(ctx context.Context, file []byte, extension string) ([]byte, error) {
+	if b.nativeImages {
+		pdf, err := nativeImageToPDF(file, extension)
+		if err == nil {
+			return pdf, nil
+		}
+		// ImageMagick would decode it just the same
+		if errors.Is(err, errImageTooLarge) {
+			return nil, &Error{Kind: KindUnsupportedFile, Err: err}
+		}
+		b.log.Warn().Err(err).Str("extension", extension).Msg("native image conversion failed, falling back to convert")
+	}
+
+	return b.magickImageToPDF(ctx, file)
+}
+
+// magickImageToPDF converts an image to a PDF with ImageMagick
+func (b *Builder) magickImageToPDF(ctx context.Context, file []byte) ([]byte, error) {
+	var pdf []byte
+
+	resultFileName := "img.pdf"
+
+	directory, cleanup, err := b.WorkDir("imageToPDF")
+	if err != nil {
+		return pdf, err
+	}
+	defer cleanup()
+
+	// Save image so we can work with it
+	err = ioutil.WriteFile(directory+"/img", file, os.ModePerm)
+	if err != nil {
+		return pdf, err
+	}
+
+	output, err := b.RunTool(
+		ctx,
+		directory,
+		"convert",
+		"img",
+		"-resize",
+		"595x842",
+		"-background",
+		"white",
+		"-page",
+		"a4",
+		resultFileName,
+	)
+	if err != nil {
+		return pdf, fmt.Errorf("%w: %s", err, output)
+	}
+
+	return ioutil.ReadFile(directory + "/" + resultFileName)
+}
+
+// nativeImageToPDF places a jpg or png image on an A4 page without calling out
+// to ImageMagick.  JPEGs are embedded as is, and PNGs are stored losslessly
+func nativeImageToPDF(file []byte, extension string) ([]byte, error) {
+	var img *pdfImage
+	var err error
+
+	switch extension {
+	case "jpg":
+		img, err = jpegImage(file)
+	case "png":
+		img, err = pngImage(file)
+	default:
+		err = errNativeUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return imagePage(img)
+}
+
+// jpegImage passes the JPEG data straight through as a DCTDecode stream
+func jpegImage(file []byte) (*pdfImage, error) {
+	conf, err := jpeg.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("reading jpeg header: %v", err)
+	}
+
+	img := &pdfImage{
+		width:  conf.Width,
+		height: conf.Height,
+		bits:   8,
+		filter: "/DCTDecode",
+		data:   file,
+	}
+
+	switch conf.ColorModel {
+	case color.YCbCrModel, color.RGBAModel:
+		img.colorSpace = "/DeviceRGB"
+	case color.GrayModel:
+		img.colorSpace = "/DeviceGray"
+	default:
+		// CMYK JPEGs are frequently stored inverted, which ImageMagick handles
+		return nil, errNativeUnsupported
+	}
+
+	return img, nil
+}
+
+// pngImage decodes the PNG and stores its samples as a Flate stream, with any
+// transparency kept as a soft mask
+func pngImage(file []byte) (*pdfImage, error) {
+	conf, err := png.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("reading png header: %v", err)
+	}
+	if int64(conf.Width)*int64(conf.Height) > maxImagePixels {
+		return nil, fmt.Errorf("%w: png is %dx%d, over %d pixels", errImageTooLarge, conf.Width, conf.Height, maxImagePixels)
+	}
+
+	src, err := png.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("decoding png: %v", err)
+	}
+
+	bounds := src.Bounds()
+	img := &pdfImage{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		bits:   8,
+		filter: "/FlateDecode",
+	}
+
+	gray := false
+	switch src.ColorModel() {
+	case color.GrayModel:
+		gray = true
+	case color.Gray16Model:
+		gray = true
+		img.bits = 16
+	case color.RGBA64Model, color.NRGBA64Model:
+		img.bits = 16
+	}
+
+	components := 3
+	img.colorSpace = "/DeviceRGB"
+	if gray {
+		components = 1
+		img.colorSpace = "/DeviceGray"
+	}
+
+	bytesPerSample := img.bits / 8
+	samples := make([]byte, 0, img.width*img.height*components*bytesPerSample)
+	alpha := make([]byte, 0, img.width*img.height*bytesPerSample)
+	opaque := true
+
+	// The common 8 bit layouts are copied straight from their pixels, leaving
+	// the rest to be converted a pixel at a time
+	switch src := src.(type) {
+	case *image.Gray:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := src.PixOffset(bounds.Min.X, y)
+			samples = append(samples, src.Pix[i:i+img.width]...)
+		}
+	case *image.NRGBA:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := src.PixOffset(bounds.Min.X, y)
+			for end := i + 4*img.width; i < end; i += 4 {
+				p := src.Pix[i : i+4]
+				samples = append(samples, p[0], p[1], p[2])
+				alpha = append(alpha, p[3])
+				opaque = opaque && p[3] == 0xff
+			}
+		}
+	case *image.RGBA:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := src.PixOffset(bounds.Min.X, y)
+			for end := i + 4*img.width; i < end; i += 4 {
+				p := src.Pix[i : i+4]
+				// Samples are stored premultiplied, so are divided by alpha
+				r, g, b, a := p[0], p[1], p[2], p[3]
+				if a != 0xff && a != 0 {
+					r = uint8(uint16(r) * 0xff / uint16(a))
+					g = uint8(uint16(g) * 0xff / uint16(a))
+					b = uint8(uint16(b) * 0xff / uint16(a))
+				}
+				samples = append(samples, r, g, b)
+				alpha = append(alpha, a)
+				opaque = opaque && a == 0xff
+			}
+		}
+	default:
+		put := func(buf []byte, v uint16) []byte {
+			if bytesPerSample == 2 {
+				return append(buf, byte(v>>8), byte(v))
+			}
+			return append(buf, byte(v>>8))
+		}
+
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBA64Model.Convert(src.At(x, y)).(color.NRGBA64)
+				if gray {
+					samples = put(samples, c.R)
+				} else {
+					samples = put(samples, c.R)
+					samples = put(samples, c.G)
+					samples = put(samples, c.B)
+				}
+				alpha = put(alpha, c.A)
+				if c.A != 0xffff {
+					opaque = false
+				}
+			}
+		}
+	}
+
+	img.data, err = deflate(samples)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opaque {
+		img.alpha, err = deflate(alpha)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+// imagePage creates a PDF with a single A4 page showing the image, scaled to
+// fit the page and centred on it
+func imagePage(img *pdfImage) ([]byte, error) {
+	if img.width <= 0 || img.height <= 0 {
+		return nil, fmt.Errorf("image has invalid dimensions %dx%d", img.width, img.height)
+	}
+
+	scale := a4Width / float64(img.width)
+	if s := a4Height / float64(img.height); s < scale {
+		scale = s
+	}
+	w := float64(img.width) * scale
+	h := float64(img.height) * scale
+	x := (a4Width - w) / 2
+	y := (a4Height - h) / 2
+
+	pw := newPDFWriter()
+	catalog := pw.reserve()
+	pages := pw.reserve()
+	page := pw.reserve()
+	contents := pw.reserve()
+	xobject := pw.reserve()
+
+	pw.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	pw.object(pages, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", page))
+	pw.object(page, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %g %g] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+		pages, a4Width, a4Height, xobject, contents,
+	))
+	pw.stream(contents, "", []byte(fmt.Sprintf("q %.4f 0 0 %.4f %.4f %.4f cm /Im0 Do Q", w, h, x, y)))
+
+	dict := fmt.Sprintf(
+		"/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent %d /Filter %s",
+		img.width, img.height, img.colorSpace, img.bits, img.filter,
+	)
+	if img.alpha != nil {
+		mask := pw.reserve()
+		pw.stream(mask, fmt.Sprintf(
+			"/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent %d /Filter /FlateDecode",
+			img.width, img.height, img.bits,
+		), img.alpha)
+		dict += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	pw.stream(xobject, dict, img.data)
+
+	return pw.finish(catalog)
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
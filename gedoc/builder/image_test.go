@@ -0,0 +1,178 @@
+package builder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestNativeImageToPDF Converts generated images and checks the PDF structure
+func TestNativeImageToPDF(t *testing.T) {
+	rgba := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			rgba.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	rgba.Set(0, 0, color.NRGBA{A: 0})
+
+	var pngData, jpgData bytes.Buffer
+	if err := png.Encode(&pngData, rgba); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpgData, rgba, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		extension string
+		data      []byte
+		expect    []string
+	}{
+		{"jpg", jpgData.Bytes(), []string{"/DCTDecode", "/DeviceRGB", "/Width 40 /Height 20"}},
+		{"png", pngData.Bytes(), []string{"/FlateDecode", "/SMask", "/Width 40 /Height 20"}},
+	}
+
+	for _, test := range tests {
+		pdf, err := nativeImageToPDF(test.data, test.extension)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.extension, err)
+			continue
+		}
+
+		if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+			t.Errorf("%s: output is not a complete PDF", test.extension)
+		}
+
+		for _, e := range test.expect {
+			if !strings.Contains(string(pdf), e) {
+				t.Errorf("%s: expected output to contain %s", test.extension, e)
+			}
+		}
+
+		// The JPEG must be embedded without re-encoding
+		if test.extension == "jpg" && !bytes.Contains(pdf, test.data) {
+			t.Errorf("jpg: original data not embedded")
+		}
+	}
+}
+
+// TestNativeImageUnsupported Ensures unsupported input is reported so callers can fall back
+func TestNativeImageUnsupported(t *testing.T) {
+	if _, err := nativeImageToPDF([]byte("GIF89a"), "gif"); err != errNativeUnsupported {
+		t.Errorf("Expected errNativeUnsupported, got %v", err)
+	}
+}
+
+// TestPNGSamples Checks each decoded PNG layout is stored with the samples of
+// the original image
+func TestPNGSamples(t *testing.T) {
+	rect := image.Rect(0, 0, 5, 3)
+	gray := image.NewGray(rect)
+	rgba := image.NewRGBA(rect)
+	nrgba := image.NewNRGBA(rect)
+	paletted := image.NewPaletted(rect, color.Palette{color.Black, color.NRGBA{R: 200, G: 100, B: 50, A: 255}})
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 3; y++ {
+			gray.Set(x, y, color.Gray{Y: uint8(40 * x)})
+			rgba.Set(x, y, color.RGBA{R: uint8(50 * x), G: uint8(80 * y), B: 7, A: 255})
+			nrgba.Set(x, y, color.NRGBA{R: uint8(50 * x), G: uint8(80 * y), B: 7, A: uint8(60 * x)})
+			paletted.SetColorIndex(x, y, uint8((x+y)%2))
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		img   image.Image
+		alpha bool
+	}{
+		{"gray", gray, false},
+		{"rgba", rgba, false},
+		{"nrgba", nrgba, true},
+		{"paletted", paletted, false},
+	} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, test.img); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := pngImage(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		var expect, expectAlpha []byte
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				c := color.NRGBAModel.Convert(test.img.At(x, y)).(color.NRGBA)
+				if test.name == "gray" {
+					expect = append(expect, c.R)
+				} else {
+					expect = append(expect, c.R, c.G, c.B)
+				}
+				expectAlpha = append(expectAlpha, c.A)
+			}
+		}
+
+		if samples := inflate(t, img.data); !bytes.Equal(samples, expect) {
+			t.Errorf("%s: expected samples %v, got %v", test.name, expect, samples)
+		}
+		if (img.alpha != nil) != test.alpha {
+			t.Errorf("%s: expected soft mask %v", test.name, test.alpha)
+		} else if test.alpha && !bytes.Equal(inflate(t, img.alpha), expectAlpha) {
+			t.Errorf("%s: soft mask doesn't match the image's alpha", test.name)
+		}
+	}
+}
+
+// TestPNGTooLarge Checks PNGs with too many pixels are rejected from their
+// header, without being decoded or handed to ImageMagick
+func TestPNGTooLarge(t *testing.T) {
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], 100000)
+	binary.BigEndian.PutUint32(header[4:], 100000)
+	header[8], header[9] = 8, 6 // 8 bit RGBA
+
+	chunk := append([]byte("IHDR"), header...)
+	file := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 13)
+	file = append(file, chunk...)
+	file = binary.BigEndian.AppendUint32(file, crc32.ChecksumIEEE(chunk))
+
+	if _, err := pngImage(file); !errors.Is(err, errImageTooLarge) {
+		t.Errorf("Expected errImageTooLarge, got %v", err)
+	}
+
+	b := New(WithRunner(RunnerFunc(func(context.Context, *exec.Cmd) error {
+		t.Error("Expected no tools to run")
+		return nil
+	})))
+	var e *Error
+	if _, err := b.ImageToPDF(context.Background(), file); !errors.As(err, &e) || e.Kind != KindUnsupportedFile {
+		t.Errorf("Expected an unsupported file error, got %v", err)
+	}
+}
+
+// inflate decompresses a Flate stream
+func inflate(t *testing.T, data []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflated, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inflated
+}
@@ -0,0 +1,210 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latexJobName names the files latexmk writes.  It is fixed, rather than
+// unique, because xdvipdfmx derives the trailer ID from the output file name
+const latexJobName = "gedoc-output"
+
+// LatexEngine is the command latexmk runs to compile documents by default.
+// latexmk's own options and the source file follow it
+var LatexEngine = []string{"xelatex", "-no-shell-escape", "-synctex=1", "-interaction=nonstopmode", "-file-line-error"}
+
+// texDiagnosticPattern matches errors in a TeX log written with -file-line-error
+var texDiagnosticPattern = regexp.MustCompile(`(?m)^(\S+?):(\d+): (.+)$`)
+
+// texOutputPattern matches the line TeX logs once the PDF has been written
+var texOutputPattern = regexp.MustCompile(`Output written on .+ \((\d+) pages?`)
+
+// maxTexDiagnostics limits how many errors from the TeX log are reported
+const maxTexDiagnostics = 20
+
+// LatexmkSettings returns the latexmk configuration that compiles with
+// engine, given as a program and its arguments
+func LatexmkSettings(engine []string) []byte {
+	// latexmk runs the command with the shell, so each argument is quoted for
+	// the shell, and the whole command for Perl
+	quoted := make([]string, len(engine))
+	for i, arg := range engine {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	command := strings.Join(append(quoted, "%O", "%S"), " ")
+	command = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(command)
+
+	return []byte(`
+$pdf_mode = 1;
+$pdflatex='` + command + `';
+`)
+}
+
+// LatexPaths returns where BuildLatex writes each of files, relative to the
+// build directory.  Names must be plain file names, and folders relative paths
+// within the build directory.  Files given the same path are rejected, as only
+// one of them could be compiled
+func LatexPaths(files []File) ([]string, error) {
+	paths := make([]string, len(files))
+	seen := map[string]bool{}
+	for i, f := range files {
+		if f.Name == "" || f.Name == "." || f.Name == ".." || strings.ContainsAny(f.Name, `/`+string(filepath.Separator)) {
+			return nil, invalidInput("file name %q must be a plain file name, with any directories given as its folder", f.Name)
+		}
+		if !localPath(f.Folder) {
+			return nil, invalidInput("folder %q of %s must be a relative path within the build directory", f.Folder, f.Name)
+		}
+
+		paths[i] = filepath.Join(f.Folder, f.Name)
+		if seen[paths[i]] {
+			return nil, invalidInput("%s is given more than once", paths[i])
+		}
+		seen[paths[i]] = true
+	}
+	return paths, nil
+}
+
+// localPath reports whether path is relative and stays beneath the directory
+// it's relative to
+func localPath(path string) bool {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildLatex compiles files with latexmk, returning the PDF and its page
+// count.  Compile failures are reported as an Error of KindCompile, with the
+// errors TeX logged
+func (b *Builder) BuildLatex(ctx context.Context, files []File) ([]byte, int, error) {
+	ctx, span := tracer.Start(ctx, "buildLatexPDF")
+	defer span.End()
+
+	var final []byte
+
+	resultFileName := latexJobName + ".pdf"
+
+	directory, cleanup, err := b.WorkDir("buildLatexPDF")
+	if err != nil {
+		return final, 0, err
+	}
+	defer cleanup()
+
+	if len(files) == 0 {
+		return final, 0, invalidInput("must provide one or more files")
+	}
+
+	// Use our predefined settings
+	err = ioutil.WriteFile(directory+"/.latexmkrc", LatexmkSettings(b.latexEngine), os.ModePerm)
+	if err != nil {
+		return final, 0, err
+	}
+
+	paths, err := LatexPaths(files)
+	if err != nil {
+		return final, 0, err
+	}
+
+	// Create the provided files in a unique folder
+	for i, f := range files {
+		where := filepath.Join(directory, paths[i])
+		if err := os.MkdirAll(filepath.Dir(where), os.ModePerm); err != nil {
+			return final, 0, err
+		}
+
+		if err := ioutil.WriteFile(where, f.Data, os.ModePerm); err != nil {
+			return final, 0, err
+		}
+	}
+
+	release, err := b.acquire(ctx, WorkLatex)
+	if err != nil {
+		return final, 0, err
+	}
+	defer release()
+
+	run := func(args ...string) ([]byte, error) {
+		return Run(ctx, b.latexRunner, Command(ctx, directory, "latexmk", args...))
+	}
+
+	// Clean, and then run the build
+	b.log.Info().Msg("cleaning")
+	_, err = run("-C")
+	if err != nil {
+		return final, 0, fmt.Errorf("running latexmk clean: %w", err)
+	}
+
+	b.log.Info().Msg("building")
+	started := time.Now()
+	output, err := run("-jobname=" + latexJobName)
+	if b.observeLatexmk != nil {
+		b.observeLatexmk(output, time.Since(started))
+	}
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return final, 0, err
+		}
+
+		// TeX explains what went wrong in the log rather than its exit status
+		texLog, _ := ioutil.ReadFile(directory + "/" + latexJobName + ".log")
+		return final, 0, &Error{
+			Kind:        KindCompile,
+			Err:         fmt.Errorf("latex compilation failed: %w", err),
+			Diagnostics: texDiagnostics(texLog),
+			Output:      output,
+			Log:         texLog,
+		}
+	}
+
+	// TeX logs the page count of the PDF it wrote
+	texLog, _ := ioutil.ReadFile(directory + "/" + latexJobName + ".log")
+
+	if err := b.fixTrailerID(ctx, directory, resultFileName); err != nil {
+		return final, 0, err
+	}
+
+	// Load the produced PDF to return
+	final, err = ioutil.ReadFile(directory + "/" + resultFileName)
+	return final, texPageCount(texLog), err
+}
+
+// texDiagnostics returns the errors recorded in a TeX log
+func texDiagnostics(texLog []byte) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, m := range texDiagnosticPattern.FindAllSubmatch(texLog, maxTexDiagnostics) {
+		line, _ := strconv.Atoi(string(m[2]))
+		diagnostics = append(diagnostics, Diagnostic{
+			File:    strings.TrimPrefix(string(m[1]), "./"),
+			Line:    line,
+			Message: string(m[3]),
+		})
+	}
+
+	return diagnostics
+}
+
+// texPageCount returns the number of pages TeX logged writing, or zero if the
+// log doesn't say
+func texPageCount(texLog []byte) int {
+	match := texOutputPattern.FindSubmatch(texLog)
+	if match == nil {
+		return 0
+	}
+
+	pages, _ := strconv.Atoi(string(match[1]))
+	return pages
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestTexDiagnostics Parses errors from a TeX log
+func TestTexDiagnostics(t *testing.T) {
+	texLog := []byte("This is XeTeX\n(./main.tex\n./main.tex:12: Undefined control sequence.\nl.12 \\foo\n./main.tex:20: LaTeX Error: File `missing.sty' not found.\n")
+
+	diagnostics := texDiagnostics(texLog)
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d", len(diagnostics))
+	}
+
+	if diagnostics[0] != (Diagnostic{"main.tex", 12, "Undefined control sequence."}) {
+		t.Errorf("Unexpected first diagnostic %v", diagnostics[0])
+	}
+}
+
+// TestTexPageCount Reads the page count from a TeX log
+func TestTexPageCount(t *testing.T) {
+	tests := []struct {
+		log   string
+		pages int
+	}{
+		{"Output written on 1234.pdf (1 page).\n", 1},
+		{"Output written on 1234.pdf (12 pages).\n", 12},
+		{"No pages of output.\n", 0},
+	}
+
+	for _, test := range tests {
+		if pages := texPageCount([]byte(test.log)); pages != test.pages {
+			t.Errorf("Expected %d pages from %q, got %d", test.pages, test.log, pages)
+		}
+	}
+}
+
+// TestLatexmkSettings Checks the engine's arguments reach it unchanged through
+// latexmk's Perl configuration and the shell, even with spaces and quotes
+func TestLatexmkSettings(t *testing.T) {
+	requireTools(t, "perl", "sh")
+
+	engine := []string{"printf", `[%s]`, "/opt/my tex/bin", `it's "quoted" \ $HOME`}
+	script := `do $ARGV[0] or die $@; $pdflatex =~ s/%O/-opt/; $pdflatex =~ s/%S/main.tex/; print $pdflatex`
+
+	dir := t.TempDir()
+	settings := filepath.Join(dir, "latexmkrc")
+	if err := ioutil.WriteFile(settings, LatexmkSettings(engine), 0600); err != nil {
+		t.Fatal(err)
+	}
+	command, err := exec.Command("perl", "-e", script, settings).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("sh", "-c", string(command)).Output()
+	if err != nil {
+		t.Fatalf("%v: %s", err, command)
+	}
+	if expect := `[/opt/my tex/bin][it's "quoted" \ $HOME][-opt][main.tex]`; string(output) != expect {
+		t.Errorf("Expected %s, got %s from %s", expect, output, command)
+	}
+}
+
+// TestLatexPaths Checks files are placed within their folders, and files
+// sharing a path or reaching outside the build directory are rejected
+func TestLatexPaths(t *testing.T) {
+	paths, err := LatexPaths([]File{{Name: "main.tex"}, {Name: "one.tex", Folder: "chapters"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "main.tex" || paths[1] != "chapters/one.tex" {
+		t.Errorf("Unexpected paths %v", paths)
+	}
+
+	var e *Error
+	if _, err := LatexPaths([]File{{Name: "one.tex", Folder: "chapters"}, {Name: "one.tex", Folder: "chapters/"}}); !errors.As(err, &e) || e.Kind != KindInvalidInput {
+		t.Errorf("Expected files sharing a path to be rejected, got %v", err)
+	}
+
+	for _, f := range []File{
+		{Name: "../../x.tex"},
+		{Name: "/abs.tex"},
+		{Name: "a/b.tex"},
+		{Name: ".."},
+		{Name: ""},
+		{Name: "x.tex", Folder: "../up"},
+		{Name: "x.tex", Folder: "a/../../up"},
+		{Name: "x.tex", Folder: "/etc"},
+	} {
+		if _, err := LatexPaths([]File{f}); !errors.As(err, &e) || e.Kind != KindInvalidInput {
+			t.Errorf("Expected name %q in folder %q to be rejected, got %v", f.Name, f.Folder, err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/h2non/filetype"
+)
+
+// MergeOptions Controls how files are prepared and merged
+type MergeOptions struct {
+	// ForceEven pads each file with a blank page so that it has an even page count
+	ForceEven bool
+	// OCR converts images to searchable PDFs, recognising text in OCRLanguage
+	OCR         bool
+	OCRLanguage string
+	// Sanitize neutralises active content in input PDFs
+	Sanitize bool
+	// Strict rejects damaged input PDFs, even if they can be repaired
+	Strict bool
+	// SkipInvalid leaves out files that can't be prepared instead of failing
+	SkipInvalid bool
+}
+
+// FileStatus How far a file got towards being merged
+type FileStatus int
+
+const (
+	StatusUnprocessed FileStatus = iota
+	StatusOK
+	StatusSkipped
+	StatusFailed
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case StatusUnprocessed:
+		return "UNPROCESSED"
+	case StatusOK:
+		return "OK"
+	case StatusSkipped:
+		return "SKIPPED"
+	case StatusFailed:
+		return "FAILED"
+	}
+	return strconv.Itoa(int(s))
+}
+
+// FileResult How one of the files given to Merge was handled
+type FileResult struct {
+	Index     int
+	Name      string
+	MimeType  string
+	PageCount int
+	Status    FileStatus
+	ErrorCode FileErrorCode
+	Message   string
+	// Preflight is set for PDFs once they have been checked
+	Preflight *PreflightResult
+}
+
+// MergeResult The merged PDF, along with details of how each input was handled
+type MergeResult struct {
+	Data      []byte
+	Files     []*FileResult
+	Preflight []*PreflightResult
+	// Pages is the page count of Data
+	Pages int
+}
+
+// Merge merges files, which may be PDFs, jpgs or pngs, into a single PDF.  The
+// returned result is never nil, so that details of the files processed are
+// available even on failure
+func (b *Builder) Merge(ctx context.Context, files []File, opts MergeOptions) (*MergeResult, error) {
+	ctx, span := tracer.Start(ctx, "mergeFiles")
+	defer span.End()
+
+	result := &MergeResult{}
+
+	if len(files) == 0 {
+		return result, invalidInput("must provide one or more files")
+	}
+
+	if _, err := OCRLanguage(opts.OCRLanguage); err != nil {
+		return result, err
+	}
+
+	release, err := b.acquire(ctx, WorkMerge)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+
+	directory, cleanup, err := b.WorkDir("mergeFiles")
+	if err != nil {
+		return result, err
+	}
+	defer cleanup()
+
+	// Store each file as a PDF in a unique folder, and note their names
+	outputFileName := "merged.pdf"
+	var args = append([]string{"--warning-exit-0"}, DeterministicIDArgs(ctx)...)
+	args = append(args,
+		"--empty",
+		outputFileName,
+		"--pages",
+	)
+	var merging int
+
+	names, fileResults, err := b.prepareFiles(ctx, directory, files, opts)
+	result.Files = fileResults
+
+	for i, fileResult := range result.Files {
+		if fileResult.Preflight != nil {
+			result.Preflight = append(result.Preflight, fileResult.Preflight)
+		}
+		if fileResult.Status == StatusOK {
+			args = append(args, names[i]...)
+			merging++
+			result.Pages += fileResult.PageCount
+		}
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	if merging == 0 {
+		return result, invalidInput("none of the %d files could be merged", len(files))
+	}
+
+	args = append(args, "--")
+
+	_, err = b.RunTool(ctx, directory, "qpdf", args...)
+	if err != nil {
+		return result, fmt.Errorf("failed merging pdf files: %w", err)
+	}
+
+	// Load the produced PDF to return
+	result.Data, err = ioutil.ReadFile(directory + "/" + outputFileName)
+
+	if err != nil {
+		return result, fmt.Errorf("failed reading produced PDF: %s", err)
+	}
+
+	return result, nil
+}
+
+// prepareFiles prepares each of files for merging in directory, up to the
+// merge parallelism at once, returning the prepared files' names and a result
+// for each file in their original order.  The first file that can neither be
+// prepared nor skipped stops the rest, which are left UNPROCESSED, and its
+// error is returned
+func (b *Builder) prepareFiles(ctx context.Context, directory string, files []File, opts MergeOptions) ([][]string, []*FileResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallelism := b.mergeParallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	names := make([][]string, len(files))
+	results := make([]*FileResult, len(files))
+	for i, f := range files {
+		results[i] = &FileResult{Index: i, Name: f.Name, Status: StatusUnprocessed}
+	}
+
+	var mu sync.Mutex
+	var failure error
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+
+	for i, f := range files {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, f File) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			fileResult := results[i]
+			prepared, err := b.prepareFile(ctx, directory, i, f, opts, fileResult)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				fileResult.Status = StatusOK
+				names[i] = prepared
+			case failure != nil:
+				// Stopped early because another file failed
+			case opts.SkipInvalid && ctx.Err() == nil && skippable(err):
+				b.log.Warn().Err(err).Str("filename", f.Name).Int("index", i).Msg("skipping invalid file")
+				fileResult.Status = StatusSkipped
+				fileResult.ErrorCode = fileErrorCode(err)
+				fileResult.Message = err.Error()
+			default:
+				fileResult.Status = StatusFailed
+				fileResult.ErrorCode = fileErrorCode(err)
+				fileResult.Message = err.Error()
+				failure = fileFailure(i, err)
+				if ctx.Err() != nil {
+					// The merge was cancelled, which may be all that's wrong
+					// with the file if its tools were killed
+					failure = fmt.Errorf("%w: %v", ctx.Err(), err)
+				}
+				cancel()
+			}
+		}(i, f)
+	}
+
+	wg.Wait()
+
+	return names, results, failure
+}
+
+// skippable reports whether err is a problem with the file itself, which
+// SkipInvalid leaves out.  Internal errors, cancellation and the Limiter's
+// errors say nothing about the file, so always fail the merge
+func skippable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var fe *FileError
+	return errors.As(err, &fe) && fe.Code != CodeNone && fe.Code != CodeInternal
+}
+
+// prepareFile converts the file at index to a PDF ready for merging, and
+// stores it in directory, returning the names of the PDFs that make it up,
+// which includes any padding.  Details are recorded in fileResult as they
+// become known
+func (b *Builder) prepareFile(ctx context.Context, directory string, index int, f File, opts MergeOptions, fileResult *FileResult) ([]string, error) {
+	kind, err := filetype.Match(f.Data)
+	if err != nil {
+		return nil, &FileError{CodeUnsupportedType, fmt.Errorf("file type for %s unsupported: %w", f.Name, err)}
+	}
+	fileResult.MimeType = kind.MIME.Value
+
+	b.log.Info().
+		Str("file_type", kind.MIME.Value).
+		Str("extension", kind.Extension).
+		Str("filename", f.Name).
+		Msg("file info")
+
+	var prepared []byte
+
+	switch kind.Extension {
+	case "pdf":
+		prepared, fileResult.Preflight, err = b.preflightPDF(ctx, index, f, opts)
+		if err != nil {
+			return nil, err
+		}
+	case "jpg", "png":
+		release, err := b.acquire(ctx, WorkImage)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		if opts.OCR {
+			language, _ := OCRLanguage(opts.OCRLanguage)
+			prepared, err = b.ocrImageToPDF(ctx, f.Data, language)
+			if err != nil {
+				return nil, &FileError{CodeOCRFailed, fmt.Errorf("failed to ocr image %s: %w", f.Name, err)}
+			}
+		} else {
+			prepared, err = b.convertImage(ctx, f.Data, kind.Extension)
+			if err != nil {
+				return nil, &FileError{CodeConversionFailed, fmt.Errorf("failed to convert image %s to pdf: %w", f.Name, err)}
+			}
+		}
+	default:
+		return nil, &FileError{CodeUnsupportedType, fmt.Errorf("file type for %s unsupported", f.Name)}
+	}
+
+	where := fmt.Sprintf("%s/%d.pdf", directory, index)
+	pdfFileNames := []string{fmt.Sprintf("%d.pdf", index)}
+
+	b.log.Debug().Int("bytes", len(prepared)).Str("file_location", where).Msg("writing file")
+	if err := ioutil.WriteFile(where, prepared, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	layout, err := b.pageLayout(ctx, prepared)
+	if err != nil {
+		return nil, &FileError{CodePageCountFailed, fmt.Errorf("counting pages of %s: %w", f.Name, err)}
+	}
+	pageCount := layout.pages
+
+	if opts.ForceEven {
+		// If the page count is odd then follow it with a blank page the size
+		// of its last page
+		isOdd := pageCount%2 == 1
+		b.log.Debug().
+			Str("pdf_filename", pdfFileNames[0]).
+			Int("page_count", pageCount).
+			Bool("is_odd", isOdd).
+			Msg("pdf stats")
+		if isOdd {
+			blank, err := blankPagePDF(layout)
+			if err == nil {
+				err = ioutil.WriteFile(fmt.Sprintf("%s/%d-blank.pdf", directory, index), blank, os.ModePerm)
+			}
+			if err != nil {
+				return nil, &FileError{CodePaddingFailed, fmt.Errorf("adding blank to odd numberd pdf %d: %w", index, err)}
+			}
+			pdfFileNames = append(pdfFileNames, fmt.Sprintf("%d-blank.pdf", index))
+			pageCount++
+		}
+	}
+
+	fileResult.PageCount = pageCount
+
+	return pdfFileNames, nil
+}
+
+// pdfPageCount returns the number of pages in the named PDF in directory
+func (b *Builder) pdfPageCount(ctx context.Context, directory string, name string) (int, error) {
+	out, err := b.ToolOutput(ctx, directory, "qpdf", "--show-npages", name)
+	if err != nil {
+		return 0, fmt.Errorf("exec qpdf page count: %w", err)
+	}
+
+	pageCount, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, fmt.Errorf("show-npages output to int: %v", err)
+	}
+
+	return pageCount, nil
+}
@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMergeOrder Prepares files in parallel, checking they are merged in the
+// order given, and that a fatal failure stops the remaining files
+func TestMergeOrder(t *testing.T) {
+	requireTools(t, "qpdf")
+
+	b := New(WithMergeParallelism(2))
+
+	files, err := loadFiles("../../examples/merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, files...)
+
+	result, err := b.Merge(context.Background(), files, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range result.Files {
+		if f.Index != i || f.Name != files[i].Name || f.Status != StatusOK {
+			t.Errorf("Expected file %d to be %s and merged, got %v", i, files[i].Name, f)
+		}
+	}
+
+	invalid := append([]File{{Name: "notes.txt", Data: []byte("not a document")}}, files...)
+	result, err = b.Merge(context.Background(), invalid, MergeOptions{})
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindUnsupportedFile || e.Field != "files[0].data" {
+		t.Errorf("Expected invalid file to fail the merge, got %v", err)
+	}
+	if len(result.Files) != len(invalid) || result.Files[0].Status != StatusFailed {
+		t.Fatalf("Expected a result for every file, with the first failed, got %v", result.Files)
+	}
+	for _, f := range result.Files[1:] {
+		if f.Status == StatusFailed || f.Status == StatusSkipped {
+			t.Errorf("Expected %s to be merged or unprocessed, got %s", f.Name, f.Status)
+		}
+	}
+}
+
+// busyLimiter Refuses to make room for images
+type busyLimiter struct{}
+
+var errBusy = errors.New("busy")
+
+func (busyLimiter) Acquire(_ context.Context, work Work) (func(), error) {
+	if work == WorkImage {
+		return nil, errBusy
+	}
+	return func() {}, nil
+}
+
+// TestMergeLimiter Checks an image that can't be given room fails the merge
+// with the limiter's error, rather than being skipped
+func TestMergeLimiter(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(WithLimiter(busyLimiter{}))
+	result, err := b.Merge(context.Background(), []File{{Name: "logo.png", Data: img.Bytes()}}, MergeOptions{SkipInvalid: true})
+	if !errors.Is(err, errBusy) {
+		t.Errorf("Expected the limiter's error, got %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != StatusFailed {
+		t.Errorf("Expected the image to fail rather than be skipped, got %v", result.Files)
+	}
+
+	if _, err := b.ImageToPDF(context.Background(), img.Bytes()); !errors.Is(err, errBusy) {
+		t.Errorf("Expected the limiter's error converting an image, got %v", err)
+	}
+}
+
+// TestMergeSkipInvalid Checks files with problems of their own are skipped,
+// while internal errors still fail the merge
+func TestMergeSkipInvalid(t *testing.T) {
+	b := New(WithRunner(RunnerFunc(func(context.Context, *exec.Cmd) error {
+		return errors.New("runner failed")
+	})))
+
+	result, err := b.Merge(context.Background(), []File{{Name: "notes.txt", Data: []byte("not a document")}}, MergeOptions{SkipInvalid: true})
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindInvalidInput {
+		t.Errorf("Expected no files to be left to merge, got %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != StatusSkipped || result.Files[0].ErrorCode != CodeUnsupportedType {
+		t.Errorf("Expected the unsupported file to be skipped, got %v", result.Files)
+	}
+
+	// Checking the PDF fails for reasons unrelated to the file
+	result, err = b.Merge(context.Background(), []File{{Name: "a.pdf", Data: []byte("%PDF-1.5")}}, MergeOptions{SkipInvalid: true})
+	if err == nil || !strings.Contains(err.Error(), "runner failed") {
+		t.Errorf("Expected the internal error, got %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != StatusFailed || result.Files[0].ErrorCode != CodeInternal {
+		t.Errorf("Expected the file to fail rather than be skipped, got %v", result.Files)
+	}
+
+	for _, err := range []error{
+		&FileError{CodeConversionFailed, fmt.Errorf("converting: %w", context.Canceled)},
+		&FileError{CodeInternal, errors.New("failed")},
+		&LimitError{errBusy},
+		errors.New("failed"),
+	} {
+		if skippable(err) {
+			t.Errorf("Expected %v not to be skipped", err)
+		}
+	}
+}
+
+// TestMergeCancelled Checks a file whose conversion fails because the merge
+// was cancelled fails the merge with the cancellation, rather than being
+// skipped
+func TestMergeCancelled(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := New(WithNativeImages(false), WithRunner(RunnerFunc(func(context.Context, *exec.Cmd) error {
+		// Cancelled while ImageMagick runs, which is then killed
+		cancel()
+		return errors.New("signal: killed")
+	})))
+
+	result, err := b.Merge(ctx, []File{{Name: "logo.png", Data: img.Bytes()}}, MergeOptions{SkipInvalid: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the merge to be cancelled, got %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != StatusFailed {
+		t.Errorf("Expected the image to fail rather than be skipped, got %v", result.Files)
+	}
+}
+
+// TestRunner Checks tools are started through the configured runner, and that
+// missing tools are classified
+func TestRunner(t *testing.T) {
+	var ran []string
+	b := New(WithRunner(RunnerFunc(func(ctx context.Context, cmd *exec.Cmd) error {
+		ran = append(ran, cmd.Args[0])
+		return &exec.Error{Name: cmd.Args[0], Err: exec.ErrNotFound}
+	})))
+
+	_, err := b.RunTool(context.Background(), "", "qpdf", "--version")
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindToolMissing {
+		t.Errorf("Expected a missing tool, got %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "qpdf" {
+		t.Errorf("Expected qpdf to be run through the runner, got %v", ran)
+	}
+}
+
+// requireTools skips the test when any of the external tools it relies on
+// aren't installed
+func requireTools(t *testing.T, tools ...string) {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available: %v", tool, err)
+		}
+	}
+}
+
+// loadFiles loads all files in the listed folder
+func loadFiles(folder string) ([]File, error) {
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(folder + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: entry.Name(), Data: data})
+	}
+
+	return files, nil
+}
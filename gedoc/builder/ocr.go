@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultOCRLanguage is the Tesseract language used when none is given
+const DefaultOCRLanguage = "eng"
+
+// ocrLanguagePattern matches Tesseract language lists such as eng or deu+eng
+var ocrLanguagePattern = regexp.MustCompile(`^[a-z][a-z_]*(\+[a-z][a-z_]*)*$`)
+
+// OCRLanguage returns the validated Tesseract language for language, which
+// may be empty to use the default
+func OCRLanguage(language string) (string, error) {
+	if language == "" {
+		return DefaultOCRLanguage, nil
+	}
+
+	if !ocrLanguagePattern.MatchString(language) {
+		return "", invalidInput("invalid ocr language %q", language)
+	}
+
+	return language, nil
+}
+
+// ocrImageToPDF converts an image to a searchable PDF, with the recognised text
+// as an invisible layer over the image
+func (b *Builder) ocrImageToPDF(ctx context.Context, img []byte, language string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "ocrImageToPDF")
+	defer span.End()
+
+	directory, cleanup, err := b.WorkDir("ocrImageToPDF")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "img"), img, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	output, err := b.RunTool(ctx, directory, "tesseract", "img", "output", "-l", language, "pdf")
+	if err != nil {
+		return nil, fmt.Errorf("running ocr: %w: %s", err, output)
+	}
+
+	return ioutil.ReadFile(filepath.Join(directory, "output.pdf"))
+}
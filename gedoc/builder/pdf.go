@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// pdfWriter writes a minimal PDF document one object at a time, keeping track
+// of object offsets so the cross-reference table can be produced at the end
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func newPDFWriter() *pdfWriter {
+	w := &pdfWriter{}
+	// The binary comment marks the file as containing binary data
+	w.buf.WriteString("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// reserve allocates an object number so that objects can reference each
+// other before they are written
+func (w *pdfWriter) reserve() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets)
+}
+
+// object writes a non-stream object with the given body
+func (w *pdfWriter) object(n int, body string) {
+	w.offsets[n-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// stream writes a stream object.  dict must not include the /Length entry,
+// which is added here
+func (w *pdfWriter) stream(n int, dict string, data []byte) {
+	w.offsets[n-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", n, dict, len(data))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// finish writes the cross-reference table and trailer, and returns the
+// completed document
+func (w *pdfWriter) finish(root int) ([]byte, error) {
+	for i, o := range w.offsets {
+		if o < 0 {
+			return nil, fmt.Errorf("pdf object %d reserved but never written", i+1)
+		}
+	}
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n", len(w.offsets)+1)
+	w.buf.WriteString("0000000000 65535 f \n")
+	for _, o := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, root, xref)
+
+	return w.buf.Bytes(), nil
+}
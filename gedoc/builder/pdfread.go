@@ -0,0 +1,848 @@
+package builder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// maxPDFStreamBytes limits how large a decoded stream may be, so that a small
+// compressed stream can't exhaust memory
+const maxPDFStreamBytes = 64 << 20
+
+// maxPDFDepth limits how deeply references and page trees are followed, so
+// that loops in damaged files end
+const maxPDFDepth = 64
+
+// errPDFSyntax is returned when a PDF can't be read natively
+var errPDFSyntax = errors.New("pdf syntax not understood")
+
+// PDF objects, as read by pdfLexer.  Numbers are int64 or float64, strings
+// are []byte, booleans are bool and null is nil
+type (
+	pdfName  string
+	pdfDict  map[pdfName]interface{}
+	pdfArray []interface{}
+	pdfRef   struct{ num, gen int }
+)
+
+// pdfStream A stream object, with its data still encoded
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+// pdfLayout The page count of a PDF, and the size and rotation of its last
+// page
+type pdfLayout struct {
+	pages    int
+	mediaBox [4]float64
+	rotate   int64
+}
+
+// a4Layout is assumed for the last page when it can't be read
+var a4Layout = pdfLayout{mediaBox: [4]float64{0, 0, a4Width, a4Height}}
+
+// PageCount returns the number of pages in pdf, reading it natively where it
+// can and with qpdf otherwise
+func (b *Builder) PageCount(ctx context.Context, pdf []byte) (int, error) {
+	layout, err := b.pageLayout(ctx, pdf)
+	return layout.pages, err
+}
+
+// pageLayout returns the layout of pdf, reading it natively.  Files that can't
+// be read natively, such as encrypted ones, are counted by qpdf and their last
+// page is assumed to be A4
+func (b *Builder) pageLayout(ctx context.Context, pdf []byte) (pdfLayout, error) {
+	layout, err := readPDFLayout(pdf)
+	if err == nil {
+		return layout, nil
+	}
+	b.log.Warn().Err(err).Msg("native page count failed, falling back to qpdf")
+
+	directory, cleanup, err := b.WorkDir("pageLayout")
+	if err != nil {
+		return pdfLayout{}, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), pdf, os.ModePerm); err != nil {
+		return pdfLayout{}, err
+	}
+
+	layout = a4Layout
+	layout.pages, err = b.pdfPageCount(ctx, directory, "input.pdf")
+	return layout, err
+}
+
+// readPDFLayout reads the page tree of pdf, following the last kid at each
+// level to find the last page.  The page count is taken from the root of the
+// tree
+func readPDFLayout(pdf []byte) (pdfLayout, error) {
+	r, err := newPDFReader(pdf)
+	if err != nil {
+		return pdfLayout{}, err
+	}
+
+	catalog, err := r.dict(r.trailer["Root"])
+	if err != nil {
+		return pdfLayout{}, fmt.Errorf("reading catalog: %w", err)
+	}
+	node, err := r.dict(catalog["Pages"])
+	if err != nil {
+		return pdfLayout{}, fmt.Errorf("reading page tree: %w", err)
+	}
+
+	layout := a4Layout
+	count, ok := r.resolve(node["Count"]).(int64)
+	if !ok || count < 0 {
+		return pdfLayout{}, fmt.Errorf("%w: page tree has no count", errPDFSyntax)
+	}
+	layout.pages = int(count)
+	if count == 0 {
+		return layout, nil
+	}
+
+	// MediaBox and Rotate are inherited from the nodes above a page
+	for depth := 0; ; depth++ {
+		if depth > maxPDFDepth {
+			return pdfLayout{}, fmt.Errorf("%w: page tree too deep", errPDFSyntax)
+		}
+
+		if box, ok := r.box(node["MediaBox"]); ok {
+			layout.mediaBox = box
+		}
+		if rotate, ok := r.resolve(node["Rotate"]).(int64); ok {
+			layout.rotate = rotate
+		}
+
+		if node["Kids"] == nil {
+			return layout, nil
+		}
+		kids, ok := r.resolve(node["Kids"]).(pdfArray)
+		if !ok || len(kids) == 0 {
+			return pdfLayout{}, fmt.Errorf("%w: page tree node has no kids", errPDFSyntax)
+		}
+		if node, err = r.dict(kids[len(kids)-1]); err != nil {
+			return pdfLayout{}, fmt.Errorf("reading page tree: %w", err)
+		}
+	}
+}
+
+// blankPagePDF returns a single blank page with the size and rotation of the
+// last page in layout
+func blankPagePDF(layout pdfLayout) ([]byte, error) {
+	var box [4]string
+	for i, v := range layout.mediaBox {
+		box[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	pw := newPDFWriter()
+	catalog := pw.reserve()
+	pages := pw.reserve()
+	page := pw.reserve()
+
+	pw.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	pw.object(pages, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", page))
+	pw.object(page, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [%s %s %s %s] /Rotate %d /Resources << >> >>",
+		pages, box[0], box[1], box[2], box[3], layout.rotate,
+	))
+
+	return pw.finish(catalog)
+}
+
+// xrefEntry Where an object is stored: at an offset in the file, or at an
+// index within an object stream
+type xrefEntry struct {
+	offset     int
+	stream     int
+	compressed bool
+}
+
+// objectStream The decoded contents of an object stream, and where each
+// object it holds starts
+type objectStream struct {
+	data    []byte
+	offsets map[int]int
+}
+
+// pdfReader Reads objects from a PDF through its cross-reference table, which
+// may be split over incremental updates and stored as streams
+type pdfReader struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer pdfDict
+	streams map[int]*objectStream
+}
+
+func newPDFReader(data []byte) (*pdfReader, error) {
+	r := &pdfReader{data: data, xref: map[int]xrefEntry{}, streams: map[int]*objectStream{}}
+
+	start := bytes.LastIndex(data, []byte("startxref"))
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no startxref", errPDFSyntax)
+	}
+	l := &pdfLexer{data: data, pos: start + len("startxref")}
+	offset, ok := l.integer()
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid startxref", errPDFSyntax)
+	}
+
+	// Sections are read newest first, so entries already seen take precedence
+	seen := map[int]bool{}
+	for !seen[offset] {
+		seen[offset] = true
+
+		trailer, err := r.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+
+		// Hybrid files list compressed objects in a stream alongside the table
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[int(stm)] {
+			seen[int(stm)] = true
+			if _, err := r.readXref(int(stm)); err != nil {
+				return nil, err
+			}
+		}
+
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = int(prev)
+	}
+
+	return r, nil
+}
+
+// readXref reads the cross-reference section at offset, returning its trailer
+func (r *pdfReader) readXref(offset int) (pdfDict, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, fmt.Errorf("%w: xref offset %d outside the file", errPDFSyntax, offset)
+	}
+
+	l := &pdfLexer{data: r.data, pos: offset}
+	l.skipSpace()
+	if !l.consume("xref") {
+		return r.readXrefStream(offset)
+	}
+
+	for {
+		l.skipSpace()
+		if l.consume("trailer") {
+			trailer, ok := l.object().(pdfDict)
+			if !ok || l.err != nil {
+				return nil, fmt.Errorf("%w: invalid trailer", errPDFSyntax)
+			}
+			return trailer, nil
+		}
+
+		first, ok1 := l.integer()
+		count, ok2 := l.integer()
+		if !ok1 || !ok2 || count < 0 {
+			return nil, fmt.Errorf("%w: invalid xref subsection", errPDFSyntax)
+		}
+		for i := 0; i < count; i++ {
+			entryOffset, ok1 := l.integer()
+			_, ok2 := l.integer()
+			kind := l.keyword()
+			if !ok1 || !ok2 || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("%w: invalid xref entry", errPDFSyntax)
+			}
+			if _, ok := r.xref[first+i]; !ok && kind == "n" {
+				r.xref[first+i] = xrefEntry{offset: entryOffset}
+			}
+		}
+	}
+}
+
+// readXrefStream reads a cross-reference stream, whose dictionary serves as
+// the trailer
+func (r *pdfReader) readXrefStream(offset int) (pdfDict, error) {
+	stream, ok := r.objectAt(offset, -1).(*pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("XRef") {
+		return nil, fmt.Errorf("%w: no xref at offset %d", errPDFSyntax, offset)
+	}
+
+	data, err := r.decode(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	var widths [3]int
+	w, ok := stream.dict["W"].(pdfArray)
+	if !ok || len(w) != 3 {
+		return nil, fmt.Errorf("%w: invalid xref stream widths", errPDFSyntax)
+	}
+	for i := range widths {
+		n, ok := w[i].(int64)
+		if !ok || n < 0 || n > 8 {
+			return nil, fmt.Errorf("%w: invalid xref stream widths", errPDFSyntax)
+		}
+		widths[i] = int(n)
+	}
+	rowSize := widths[0] + widths[1] + widths[2]
+	if rowSize == 0 {
+		return nil, fmt.Errorf("%w: invalid xref stream widths", errPDFSyntax)
+	}
+
+	index, ok := stream.dict["Index"].(pdfArray)
+	if !ok {
+		size, _ := stream.dict["Size"].(int64)
+		index = pdfArray{int64(0), size}
+	}
+
+	field := func(row []byte, i int, fallback int) int {
+		start := 0
+		for _, w := range widths[:i] {
+			start += w
+		}
+		if widths[i] == 0 {
+			return fallback
+		}
+		v := 0
+		for _, b := range row[start : start+widths[i]] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		first, ok1 := index[i].(int64)
+		count, ok2 := index[i+1].(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: invalid xref stream index", errPDFSyntax)
+		}
+		for n := 0; n < int(count); n++ {
+			if pos+rowSize > len(data) {
+				return nil, fmt.Errorf("%w: xref stream too short", errPDFSyntax)
+			}
+			row := data[pos : pos+rowSize]
+			pos += rowSize
+
+			num := int(first) + n
+			if _, ok := r.xref[num]; ok {
+				continue
+			}
+			switch field(row, 0, 1) {
+			case 1:
+				r.xref[num] = xrefEntry{offset: field(row, 1, 0)}
+			case 2:
+				r.xref[num] = xrefEntry{stream: field(row, 1, 0), offset: field(row, 2, 0), compressed: true}
+			}
+		}
+	}
+
+	return stream.dict, nil
+}
+
+// objectAt reads the indirect object at offset, which must be numbered num
+// unless num is negative
+func (r *pdfReader) objectAt(offset int, num int) interface{} {
+	if offset < 0 || offset >= len(r.data) {
+		return nil
+	}
+
+	l := &pdfLexer{data: r.data, pos: offset}
+	n, ok1 := l.integer()
+	_, ok2 := l.integer()
+	if !ok1 || !ok2 || l.keyword() != "obj" || (num >= 0 && n != num) {
+		return nil
+	}
+
+	obj := l.object()
+	dict, isDict := obj.(pdfDict)
+	if l.err != nil || !isDict {
+		return obj
+	}
+
+	l.skipSpace()
+	if !l.consume("stream") {
+		return dict
+	}
+	// The stream keyword is followed by CRLF or LF, never CR alone
+	if !l.consume("\r\n") {
+		l.consume("\n")
+	}
+
+	length, ok := r.resolve(dict["Length"]).(int64)
+	// Compared without adding to the position, which a huge length overflows
+	if !ok || length < 0 || length > int64(len(r.data)-l.pos) {
+		return nil
+	}
+
+	return &pdfStream{dict: dict, data: r.data[l.pos : l.pos+int(length)]}
+}
+
+// resolve follows references until it reaches a direct object.  Missing
+// objects are null
+func (r *pdfReader) resolve(obj interface{}) interface{} {
+	for depth := 0; depth < maxPDFDepth; depth++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+
+		entry, ok := r.xref[ref.num]
+		if !ok {
+			return nil
+		}
+		if entry.compressed {
+			obj = r.compressedObject(entry.stream, entry.offset, ref.num)
+		} else {
+			obj = r.objectAt(entry.offset, ref.num)
+		}
+	}
+	return nil
+}
+
+// compressedObject reads object num, stored at index within an object stream
+func (r *pdfReader) compressedObject(stream int, index int, num int) interface{} {
+	s, ok := r.streams[stream]
+	if !ok {
+		// Failures are remembered, so they aren't retried for every object
+		s = &objectStream{}
+		r.streams[stream] = s
+
+		entry, ok := r.xref[stream]
+		if !ok || entry.compressed {
+			return nil
+		}
+		obj, ok := r.objectAt(entry.offset, stream).(*pdfStream)
+		if !ok {
+			return nil
+		}
+		data, err := r.decode(obj)
+		if err != nil {
+			return nil
+		}
+
+		n, _ := obj.dict["N"].(int64)
+		first, _ := obj.dict["First"].(int64)
+		if first < 0 || int(first) > len(data) {
+			return nil
+		}
+
+		l := &pdfLexer{data: data[:first]}
+		offsets := map[int]int{}
+		for i := 0; i < int(n); i++ {
+			objNum, ok1 := l.integer()
+			offset, ok2 := l.integer()
+			if !ok1 || !ok2 {
+				return nil
+			}
+			offsets[objNum] = int(first) + offset
+		}
+		s.data, s.offsets = data, offsets
+	}
+
+	offset, ok := s.offsets[num]
+	if !ok || offset < 0 || offset >= len(s.data) {
+		return nil
+	}
+
+	l := &pdfLexer{data: s.data, pos: offset}
+	obj := l.object()
+	if l.err != nil {
+		return nil
+	}
+	return obj
+}
+
+// dict resolves obj, which must be a dictionary
+func (r *pdfReader) dict(obj interface{}) (pdfDict, error) {
+	dict, ok := r.resolve(obj).(pdfDict)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a dictionary", errPDFSyntax)
+	}
+	return dict, nil
+}
+
+// box resolves obj as a rectangle of four numbers
+func (r *pdfReader) box(obj interface{}) ([4]float64, bool) {
+	var box [4]float64
+	array, ok := r.resolve(obj).(pdfArray)
+	if !ok || len(array) != 4 {
+		return box, false
+	}
+
+	for i, v := range array {
+		switch n := r.resolve(v).(type) {
+		case int64:
+			box[i] = float64(n)
+		case float64:
+			box[i] = n
+		default:
+			return box, false
+		}
+	}
+
+	return box, true
+}
+
+// decode returns the data of s with its filters removed.  Only Flate, which
+// every PDF writer in use produces for cross-reference and object streams, is
+// supported
+func (r *pdfReader) decode(s *pdfStream) ([]byte, error) {
+	filter := r.resolve(s.dict["Filter"])
+	params, _ := r.resolve(s.dict["DecodeParms"]).(pdfDict)
+	if array, ok := filter.(pdfArray); ok && len(array) == 1 {
+		filter = r.resolve(array[0])
+		if p, ok := r.resolve(s.dict["DecodeParms"]).(pdfArray); ok && len(p) == 1 {
+			params, _ = r.resolve(p[0]).(pdfDict)
+		}
+	}
+
+	switch filter {
+	case nil:
+		return s.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w: unsupported filter %v", errPDFSyntax, filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPDFSyntax, err)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(zr, maxPDFStreamBytes+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("%w: %v", errPDFSyntax, err)
+	}
+	if len(data) > maxPDFStreamBytes {
+		return nil, fmt.Errorf("%w: stream too large", errPDFSyntax)
+	}
+
+	predictor, _ := params["Predictor"].(int64)
+	switch {
+	case predictor <= 1:
+		return data, nil
+	case predictor >= 10:
+		columns, ok := params["Columns"].(int64)
+		if !ok {
+			columns = 1
+		}
+		if colors, ok := params["Colors"].(int64); ok && colors != 1 {
+			return nil, fmt.Errorf("%w: unsupported predictor colors %d", errPDFSyntax, colors)
+		}
+		if bits, ok := params["BitsPerComponent"].(int64); ok && bits != 8 {
+			return nil, fmt.Errorf("%w: unsupported predictor bits %d", errPDFSyntax, bits)
+		}
+		return pngUnpredict(data, int(columns))
+	}
+	return nil, fmt.Errorf("%w: unsupported predictor %d", errPDFSyntax, predictor)
+}
+
+// pngUnpredict reverses PNG prediction of rows of columns bytes, each preceded
+// by its filter type, with one byte per pixel
+func pngUnpredict(data []byte, columns int) ([]byte, error) {
+	if columns <= 0 {
+		return nil, fmt.Errorf("%w: invalid predictor columns", errPDFSyntax)
+	}
+
+	var out []byte
+	prev := make([]byte, columns)
+	for len(data) > 0 {
+		if len(data) < columns+1 {
+			return nil, fmt.Errorf("%w: truncated predicted row", errPDFSyntax)
+		}
+		filter, row := data[0], append([]byte(nil), data[1:columns+1]...)
+		data = data[columns+1:]
+
+		for i := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = row[i-1], prev[i-1]
+			}
+			up := prev[i]
+
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: invalid png filter %d", errPDFSyntax, filter)
+			}
+		}
+
+		out = append(out, row...)
+		prev = row
+	}
+
+	return out, nil
+}
+
+// paeth is the PNG Paeth predictor
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfLexer Reads PDF objects from data.  The first syntax error is kept in
+// err, after which objects read as nil
+type pdfLexer struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// isPDFSpace reports whether c is PDF whitespace
+func isPDFSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// skipSpace moves past whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// consume moves past s if it comes next
+func (l *pdfLexer) consume(s string) bool {
+	if bytes.HasPrefix(l.data[l.pos:], []byte(s)) {
+		l.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// regular reads the run of regular characters that makes up a number, name
+// or keyword
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// keyword reads the next keyword, such as obj or trailer
+func (l *pdfLexer) keyword() string {
+	l.skipSpace()
+	return l.regular()
+}
+
+// integer reads the next token as a non-negative integer
+func (l *pdfLexer) integer() (int, bool) {
+	l.skipSpace()
+	start := l.pos
+	n, err := strconv.Atoi(l.regular())
+	if err != nil || n < 0 {
+		l.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+func (l *pdfLexer) fail(format string, args ...interface{}) interface{} {
+	if l.err == nil {
+		l.err = fmt.Errorf("%w: %s at offset %d", errPDFSyntax, fmt.Sprintf(format, args...), l.pos)
+	}
+	return nil
+}
+
+// object reads the next direct object, or a reference
+func (l *pdfLexer) object() interface{} {
+	l.skipSpace()
+	if l.err != nil || l.pos >= len(l.data) {
+		return l.fail("unexpected end of data")
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return l.name()
+	case l.consume("<<"):
+		return l.dict()
+	case c == '<':
+		l.pos++
+		return l.hexString()
+	case c == '(':
+		l.pos++
+		return l.literalString()
+	case c == '[':
+		l.pos++
+		return l.array()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.numberOrRef()
+	}
+
+	switch word := l.regular(); word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	default:
+		return l.fail("unexpected %q", word)
+	}
+}
+
+// name reads a name, decoding #xx escapes
+func (l *pdfLexer) name() interface{} {
+	raw := l.regular()
+	var name []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if b, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				name = append(name, byte(b))
+				i += 2
+				continue
+			}
+		}
+		name = append(name, raw[i])
+	}
+	return pdfName(name)
+}
+
+func (l *pdfLexer) dict() interface{} {
+	dict := pdfDict{}
+	for {
+		l.skipSpace()
+		if l.consume(">>") {
+			return dict
+		}
+		key, ok := l.object().(pdfName)
+		if !ok {
+			return l.fail("dictionary key is not a name")
+		}
+		dict[key] = l.object()
+		if l.err != nil {
+			return nil
+		}
+	}
+}
+
+func (l *pdfLexer) array() interface{} {
+	array := pdfArray{}
+	for {
+		l.skipSpace()
+		if l.consume("]") {
+			return array
+		}
+		v := l.object()
+		if l.err != nil {
+			return nil
+		}
+		array = append(array, v)
+	}
+}
+
+func (l *pdfLexer) hexString() interface{} {
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		return l.fail("unterminated hex string")
+	}
+	var digits []byte
+	for _, c := range l.data[l.pos : l.pos+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	s := make([]byte, len(digits)/2)
+	for i := range s {
+		b, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return l.fail("invalid hex string")
+		}
+		s[i] = byte(b)
+	}
+	return s
+}
+
+// literalString reads a string in balanced parentheses.  Escapes are kept as
+// they are, since no string's contents are needed
+func (l *pdfLexer) literalString() interface{} {
+	start := l.pos
+	for depth := 1; l.pos < len(l.data); l.pos++ {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				l.pos++
+				return l.data[start : l.pos-1]
+			}
+		}
+	}
+	return l.fail("unterminated string")
+}
+
+// numberOrRef reads a number, or a reference when it is followed by a
+// generation and R
+func (l *pdfLexer) numberOrRef() interface{} {
+	token := l.regular()
+	n, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		f, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return l.fail("invalid number %q", token)
+		}
+		return f
+	}
+
+	after := l.pos
+	if gen, ok := l.integer(); ok && n >= 0 {
+		l.skipSpace()
+		if l.consume("R") && (l.pos == len(l.data) || isPDFDelimiter(l.data[l.pos])) {
+			return pdfRef{num: int(n), gen: gen}
+		}
+	}
+	l.pos = after
+	return n
+}
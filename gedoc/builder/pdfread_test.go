@@ -0,0 +1,150 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestReadPDFLayout Reads the page count and last page of PDFs with classic
+// and stream cross-references, incremental updates and inherited attributes
+func TestReadPDFLayout(t *testing.T) {
+	compressed, err := ioutil.ReadFile("../../examples/merge/1.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classic, err := ioutil.ReadFile("../../examples/merge/2.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blank, err := blankPagePDF(pdfLayout{mediaBox: [4]float64{0, 0, 419.53, 595.28}, rotate: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		pdf    []byte
+		expect pdfLayout
+	}{
+		{"compressed", compressed, pdfLayout{pages: 1, mediaBox: [4]float64{0, 0, 612, 792}}},
+		{"classic", classic, pdfLayout{pages: 1, mediaBox: [4]float64{0, 0, 612, 792}}},
+		{"blank", blank, pdfLayout{pages: 1, mediaBox: [4]float64{0, 0, 419.53, 595.28}, rotate: 90}},
+		{"updated", appendPage(t, blank), pdfLayout{pages: 2, mediaBox: [4]float64{0, 0, 100, 200}}},
+	}
+
+	for _, test := range tests {
+		layout, err := readPDFLayout(test.pdf)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if layout != test.expect {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expect, layout)
+		}
+	}
+
+	for _, pdf := range []string{
+		"",
+		"not a pdf",
+		"%PDF-1.5\nstartxref\n9999\n%%EOF",
+		"%PDF-1.5\nstartxref\n-5\n%%EOF",
+		"%PDF-1.5\nxref\n0 0\ntrailer\n<< /Prev -5 >>\nstartxref\n9\n%%EOF",
+		"%PDF-1.5\nxref\n0 0\ntrailer\n<< /XRefStm -5 >>\nstartxref\n9\n%%EOF",
+		"%PDF-1.5\n1 0 obj\n<< /Type /XRef /Size 2 /W [1 1 1] /Length 9223372036854775807 >>\nstream\nxx\nendstream\nendobj\nstartxref\n9\n%%EOF",
+		string(blank[:len(blank)/2]),
+	} {
+		if _, err := readPDFLayout([]byte(pdf)); !errors.Is(err, errPDFSyntax) {
+			t.Errorf("%q: expected a syntax error, got %v", pdf, err)
+		}
+	}
+}
+
+// FuzzReadPDFLayout Checks damaged PDFs are rejected with an error rather
+// than a panic
+func FuzzReadPDFLayout(f *testing.F) {
+	for _, name := range []string{"../../examples/merge/1.pdf", "../../examples/merge/2.pdf"} {
+		pdf, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(pdf)
+	}
+	blank, err := blankPagePDF(a4Layout)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(blank)
+	f.Add([]byte("%PDF-1.5\nxref\n0 0\ntrailer\n<< /XRefStm -5 >>\nstartxref\n9\n%%EOF"))
+	f.Add([]byte("%PDF-1.5\n1 0 obj\n<< /Type /XRef /Size 2 /W [1 1 1] /Length 9223372036854775807 >>\nstream\nxx\nendstream\nendobj\nstartxref\n9\n%%EOF"))
+
+	f.Fuzz(func(t *testing.T, pdf []byte) {
+		if r, err := newPDFReader(pdf); err == nil {
+			r.resolve(r.trailer["Root"])
+		}
+		readPDFLayout(pdf)
+	})
+}
+
+// appendPage adds a second page to the single page pdf in an incremental
+// update, beneath an intermediate node that sets its media box
+func appendPage(t *testing.T, pdf []byte) []byte {
+	i := bytes.LastIndex(pdf, []byte("startxref"))
+	prev, err := strconv.Atoi(strings.Fields(string(pdf[i+len("startxref"):]))[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pdf)
+	offsets := map[int]int{}
+	for _, obj := range []struct {
+		num  int
+		body string
+	}{
+		{2, "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>"},
+		{4, "<< /Type /Pages /Parent 2 0 R /Kids [5 0 R] /Count 1 /MediaBox [0 0 100 200] >>"},
+		{5, "<< /Type /Page /Parent 4 0 R >>"},
+	} {
+		offsets[obj.num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", obj.num, obj.body)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n2 1\n%010d 00000 n \n4 2\n%010d 00000 n \n%010d 00000 n \n", offsets[2], offsets[4], offsets[5])
+	fmt.Fprintf(&buf, "trailer\n<< /Size 6 /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n", prev, xref)
+
+	return buf.Bytes()
+}
+
+// TestPngUnpredict Reverses each PNG filter type
+func TestPngUnpredict(t *testing.T) {
+	predicted := []byte{
+		0, 1, 2, 3,
+		1, 1, 1, 1,
+		2, 1, 1, 1,
+		3, 2, 2, 2,
+		4, 1, 1, 1,
+	}
+	expect := []byte{
+		1, 2, 3,
+		1, 2, 3,
+		2, 3, 4,
+		3, 5, 6,
+		4, 6, 7,
+	}
+
+	out, err := pngUnpredict(predicted, 3)
+	if err != nil || !bytes.Equal(out, expect) {
+		t.Errorf("Expected %v, got %v, %v", expect, out, err)
+	}
+
+	if _, err := pngUnpredict(predicted[:6], 3); !errors.Is(err, errPDFSyntax) {
+		t.Errorf("Expected truncated rows to fail, got %v", err)
+	}
+}
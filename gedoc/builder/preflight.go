@@ -0,0 +1,271 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qpdf exit codes, as documented in the qpdf manual
+const (
+	qpdfExitErrors   = 2
+	qpdfExitWarnings = 3
+)
+
+// maxPreflightProblems limits how many lines of qpdf output are reported
+const maxPreflightProblems = 10
+
+// activeContentPattern matches PDF names that trigger active content
+var activeContentPattern = regexp.MustCompile(`/(JavaScript|JS|Launch|EmbeddedFiles|EF|XFA|OpenAction|AA)`)
+
+// streamStartPattern matches the start of stream data following its dictionary
+var streamStartPattern = regexp.MustCompile(`>>\s*stream\r?\n`)
+
+// PreflightStatus The outcome of checking an input PDF
+type PreflightStatus int
+
+const (
+	PreflightClean PreflightStatus = iota
+	PreflightRepaired
+	PreflightRejected
+)
+
+func (s PreflightStatus) String() string {
+	switch s {
+	case PreflightClean:
+		return "CLEAN"
+	case PreflightRepaired:
+		return "REPAIRED"
+	case PreflightRejected:
+		return "REJECTED"
+	}
+	return strconv.Itoa(int(s))
+}
+
+// PreflightResult What checking an input PDF found, and any active content
+// removed from it
+type PreflightResult struct {
+	Index    int
+	Name     string
+	Status   PreflightStatus
+	Problems []string
+	Removed  []string
+}
+
+// Preflight checks a PDF as Merge does before merging it, repairing it if
+// needed and, when opts.Sanitize is set, neutralising any active content.  It
+// returns the PDF to use in place of the original and its page count, which
+// is only read once qpdf has accepted the file
+func (b *Builder) Preflight(ctx context.Context, file File, opts MergeOptions) ([]byte, int, *PreflightResult, error) {
+	data, result, err := b.preflightPDF(ctx, 0, file, opts)
+	if err == nil {
+		var pages int
+		if pages, err = b.PageCount(ctx, data); err == nil {
+			return data, pages, result, nil
+		}
+		err = &FileError{CodePageCountFailed, fmt.Errorf("counting pages of %s: %w", file.Name, err)}
+	}
+
+	if e, ok := fileError(err); ok {
+		err = e
+	}
+	return nil, 0, result, err
+}
+
+// preflightPDF checks an input PDF before it is merged, repairing it if needed
+// and, when sanitize is set, neutralising any active content.  The returned
+// PDF should be used in place of the original
+func (b *Builder) preflightPDF(ctx context.Context, index int, file File, opts MergeOptions) ([]byte, *PreflightResult, error) {
+	ctx, span := tracer.Start(ctx, "preflightPDF")
+	defer span.End()
+
+	result := &PreflightResult{
+		Index:  index,
+		Name:   file.Name,
+		Status: PreflightClean,
+	}
+
+	directory, cleanup, err := b.WorkDir("preflightPDF")
+	if err != nil {
+		return nil, result, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), file.Data, os.ModePerm); err != nil {
+		return nil, result, err
+	}
+
+	source := "input.pdf"
+
+	output, err := b.RunTool(ctx, directory, "qpdf", "--check", source)
+	if err != nil {
+		code := ExitCode(err)
+		if code != qpdfExitErrors && code != qpdfExitWarnings {
+			return nil, result, fmt.Errorf("checking file %s (index %d): %w: %s", file.Name, index, err, output)
+		}
+
+		result.Problems = qpdfProblems(output)
+
+		// Rewriting the file makes qpdf reconstruct anything it could recover
+		output, err = b.RunTool(ctx, directory, "qpdf", "input.pdf", "repaired.pdf")
+		if err != nil && ExitCode(err) != qpdfExitWarnings {
+			result.Status = PreflightRejected
+			result.Problems = append(result.Problems, qpdfProblems(output)...)
+			return nil, result, &FileError{CodeDamaged, fmt.Errorf("file %s (index %d) is damaged and could not be repaired", file.Name, index)}
+		}
+
+		result.Status = PreflightRepaired
+		source = "repaired.pdf"
+
+		if opts.Strict {
+			result.Status = PreflightRejected
+			return nil, result, &FileError{CodeRepairNotAllowed, fmt.Errorf("file %s (index %d) is damaged, and repaired files are not accepted in strict mode", file.Name, index)}
+		}
+	}
+
+	if opts.Sanitize {
+		source, err = b.sanitizePDF(ctx, directory, source, result)
+		if err != nil {
+			return nil, result, &FileError{CodeSanitizeFailed, fmt.Errorf("sanitizing file %s (index %d): %w", file.Name, index, err)}
+		}
+	}
+
+	b.log.Info().
+		Str("filename", file.Name).
+		Str("status", result.Status.String()).
+		Strs("removed", result.Removed).
+		Msg("preflight complete")
+
+	if source == "input.pdf" {
+		return file.Data, result, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(directory, source))
+	return data, result, err
+}
+
+// sanitizePDF neutralises active content by rewriting the file uncompressed,
+// so every dictionary is plain text, and changing the case of names that
+// trigger actions.  PDF names are case sensitive, so viewers ignore the
+// altered entries.  Returns the name of the sanitized file
+func (b *Builder) sanitizePDF(ctx context.Context, directory string, source string, result *PreflightResult) (string, error) {
+	output, err := b.RunTool(ctx, directory, "qpdf",
+		"--warning-exit-0",
+		"--decrypt",
+		"--qdf",
+		"--object-streams=disable",
+		source,
+		"expanded.pdf",
+	)
+	if err != nil {
+		return "", fmt.Errorf("expanding pdf: %w: %s", err, output)
+	}
+
+	expanded, err := ioutil.ReadFile(filepath.Join(directory, "expanded.pdf"))
+	if err != nil {
+		return "", err
+	}
+
+	disarmed, removed := disarmActiveContent(expanded)
+	if len(removed) == 0 {
+		return source, nil
+	}
+	result.Removed = removed
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "disarmed.pdf"), disarmed, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	output, err = b.RunTool(ctx, directory, "qpdf", "--warning-exit-0", "disarmed.pdf", "sanitized.pdf")
+	if err != nil {
+		return "", fmt.Errorf("compressing sanitized pdf: %w: %s", err, output)
+	}
+
+	return "sanitized.pdf", nil
+}
+
+// disarmActiveContent lowercases active content names outside of stream data,
+// returning the altered PDF and the sorted names that were found.  It relies
+// on qpdf's QDF output, where literal strings never contain raw newlines, so
+// stream data can be located reliably
+func disarmActiveContent(pdf []byte) ([]byte, []string) {
+	found := make(map[string]bool)
+	result := make([]byte, len(pdf))
+	copy(result, pdf)
+
+	offset := 0
+	for offset < len(result) {
+		// Only objects outside of streams are altered, so that content streams
+		// using similarly named resources are left alone
+		end := len(result)
+		loc := streamStartPattern.FindIndex(result[offset:])
+		if loc != nil {
+			end = offset + loc[1]
+		}
+
+		disarmNames(result[offset:end], found)
+
+		if loc == nil {
+			break
+		}
+
+		streamEnd := bytes.Index(result[end:], []byte("endstream"))
+		if streamEnd < 0 {
+			break
+		}
+		offset = end + streamEnd + len("endstream")
+	}
+
+	var removed []string
+	for name := range found {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	return result, removed
+}
+
+// disarmNames lowercases any complete active content names in data, noting
+// them in found
+func disarmNames(data []byte, found map[string]bool) {
+	for _, m := range activeContentPattern.FindAllSubmatchIndex(data, -1) {
+		// A name ends at whitespace or a delimiter, so longer names such as
+		// /JS0 aren't matched
+		if m[1] < len(data) && !isPDFDelimiter(data[m[1]]) {
+			continue
+		}
+
+		found[string(data[m[2]:m[3]])] = true
+		copy(data[m[2]:m[3]], bytes.ToLower(data[m[2]:m[3]]))
+	}
+}
+
+// isPDFDelimiter reports whether c is PDF whitespace or a delimiter character
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("\x00\t\n\f\r ()<>[]{}/%", c) >= 0
+}
+
+// qpdfProblems returns the meaningful lines of qpdf output, up to a limit
+func qpdfProblems(output []byte) []string {
+	var problems []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "checking ") || strings.HasPrefix(line, "PDF Version") ||
+			strings.HasPrefix(line, "File is not") || strings.HasPrefix(line, "File is linearized") {
+			continue
+		}
+		problems = append(problems, line)
+		if len(problems) == maxPreflightProblems {
+			break
+		}
+	}
+
+	return problems
+}
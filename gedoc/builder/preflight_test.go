@@ -0,0 +1,50 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDisarmActiveContent Checks active content names are neutralised outside of streams only
+func TestDisarmActiveContent(t *testing.T) {
+	pdf := strings.Join([]string{
+		"1 0 obj",
+		"<<",
+		"  /OpenAction 2 0 R",
+		"  /Names << /JavaScript 3 0 R /EmbeddedFiles 4 0 R >>",
+		"  /JSON (kept)",
+		">>",
+		"endobj",
+		"2 0 obj",
+		"<< /S /JavaScript /JS (app.alert\\(1\\)) >>",
+		"endobj",
+		"5 0 obj",
+		"<< /Length 6 0 R >>",
+		"stream",
+		"/JS Do /AA Do",
+		"endstream",
+		"endobj",
+		"6 0 obj",
+		"<< /S/Launch/F (cmd.exe) >>",
+		"endobj",
+	}, "\n")
+
+	disarmed, removed := disarmActiveContent([]byte(pdf))
+	result := string(disarmed)
+
+	expectRemoved := []string{"EmbeddedFiles", "JS", "JavaScript", "Launch", "OpenAction"}
+	if !reflect.DeepEqual(removed, expectRemoved) {
+		t.Errorf("Expected removed %v, got %v", expectRemoved, removed)
+	}
+
+	if len(result) != len(pdf) {
+		t.Errorf("Expected length to be unchanged, was %d now %d", len(pdf), len(result))
+	}
+
+	for _, expect := range []string{"/openaction 2 0 R", "/javascript 3 0 R", "/embeddedfiles 4 0 R", "/JSON (kept)", "/S /javascript /js", "/JS Do /AA Do", "/S/launch/F"} {
+		if !strings.Contains(result, expect) {
+			t.Errorf("Expected output to contain %q", expect)
+		}
+	}
+}
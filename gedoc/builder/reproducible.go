@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// reproducibleKey is the context key for the creation date of reproducible
+// work, in seconds since the Unix epoch
+type reproducibleKey struct{}
+
+// WithSourceDateEpoch marks ctx as reproducible, so that the tools run for it
+// give byte-identical output dated epoch, in seconds since the Unix epoch
+func WithSourceDateEpoch(ctx context.Context, epoch int64) context.Context {
+	return context.WithValue(ctx, reproducibleKey{}, epoch)
+}
+
+// ReproducibleEnv returns the environment that fixes the dates tools record,
+// or nil if ctx isn't reproducible.  FORCE_SOURCE_DATE makes TeX use it for
+// \today and \time as well as the PDF's dates
+func ReproducibleEnv(ctx context.Context) []string {
+	epoch, ok := ctx.Value(reproducibleKey{}).(int64)
+	if !ok {
+		return nil
+	}
+
+	return []string{
+		"SOURCE_DATE_EPOCH=" + strconv.FormatInt(epoch, 10),
+		"FORCE_SOURCE_DATE=1",
+	}
+}
+
+// DeterministicIDArgs returns the qpdf arguments that derive the trailer ID
+// from the output's contents rather than at random, when ctx is reproducible
+func DeterministicIDArgs(ctx context.Context) []string {
+	if ReproducibleEnv(ctx) == nil {
+		return nil
+	}
+	return []string{"--deterministic-id"}
+}
+
+// fixTrailerID rewrites the PDF name in directory with a deterministic trailer
+// ID, when ctx is reproducible
+func (b *Builder) fixTrailerID(ctx context.Context, directory string, name string) error {
+	if ReproducibleEnv(ctx) == nil {
+		return nil
+	}
+
+	output, err := b.RunTool(ctx, directory, "qpdf", "--warning-exit-0", "--deterministic-id", "--replace-input", name)
+	if err != nil {
+		return fmt.Errorf("fixing trailer id of %s: %w: %s", filepath.Base(name), err, output)
+	}
+
+	return nil
+}
@@ -11,9 +11,29 @@ It has these top-level messages:
 	BuildLatexRequest
 	FileReply
 	File
+	PipelineRequest
+	PipelineStep
+	StepResult
+	PipelineReply
+	GetArtifactRequest
+	DeleteArtifactRequest
+	DeleteArtifactReply
 	HealthReply
 	HealthRequest
 	MergeRequest
+	FileResult
+	PreflightResult
+	Optimization
+	RenderOptions
+	RenderRequest
+	RenderReply
+	Image
+	OutputTarget
+	ReproducibleOptions
+	OCROptions
+	ExtractTextRequest
+	PageText
+	ExtractTextReply
 */
 package grpc
 
@@ -37,141 +57,1465 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type StepKind int32
+
+const (
+	StepKind_STEP_UNSPECIFIED StepKind = 0
+	// Executes a text/template with [[ ]] delimiters against JSON data, giving a
+	// LaTeX source.  Takes one input, the template
+	StepKind_RENDER_TEMPLATE StepKind = 1
+	// Compiles its inputs, the LaTeX sources and anything they include
+	StepKind_BUILD_LATEX StepKind = 2
+	// Places a jpg or png image on a page.  Takes one input
+	StepKind_CONVERT_IMAGE StepKind = 3
+	// Converts a word processor, spreadsheet or presentation document with
+	// LibreOffice.  Takes one input
+	StepKind_CONVERT_OFFICE StepKind = 4
+	// Merges its inputs, PDFs or images, in order
+	StepKind_MERGE_PDFS StepKind = 5
+	// Places the first page of the second input over every page of the first
+	StepKind_STAMP StepKind = 6
+	// Encrypts its one input with AES-256
+	StepKind_ENCRYPT StepKind = 7
+	// Optimizes its one input with the given preset
+	StepKind_OPTIMIZE StepKind = 8
+)
+
+var StepKind_name = map[int32]string{
+	0: "STEP_UNSPECIFIED",
+	1: "RENDER_TEMPLATE",
+	2: "BUILD_LATEX",
+	3: "CONVERT_IMAGE",
+	4: "CONVERT_OFFICE",
+	5: "MERGE_PDFS",
+	6: "STAMP",
+	7: "ENCRYPT",
+	8: "OPTIMIZE",
+}
+var StepKind_value = map[string]int32{
+	"STEP_UNSPECIFIED": 0,
+	"RENDER_TEMPLATE":  1,
+	"BUILD_LATEX":      2,
+	"CONVERT_IMAGE":    3,
+	"CONVERT_OFFICE":   4,
+	"MERGE_PDFS":       5,
+	"STAMP":            6,
+	"ENCRYPT":          7,
+	"OPTIMIZE":         8,
+}
+
+func (x StepKind) String() string {
+	return proto.EnumName(StepKind_name, int32(x))
+}
+func (StepKind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type FileStatus int32
+
+const (
+	// The file wasn't reached because an earlier file failed
+	FileStatus_UNPROCESSED FileStatus = 0
+	// The file was prepared for merging
+	FileStatus_OK FileStatus = 1
+	// The file couldn't be prepared, and was left out in skip_invalid mode
+	FileStatus_SKIPPED FileStatus = 2
+	// The file couldn't be prepared, failing the merge
+	FileStatus_FAILED FileStatus = 3
+)
+
+var FileStatus_name = map[int32]string{
+	0: "UNPROCESSED",
+	1: "OK",
+	2: "SKIPPED",
+	3: "FAILED",
+}
+var FileStatus_value = map[string]int32{
+	"UNPROCESSED": 0,
+	"OK":          1,
+	"SKIPPED":     2,
+	"FAILED":      3,
+}
+
+func (x FileStatus) String() string {
+	return proto.EnumName(FileStatus_name, int32(x))
+}
+func (FileStatus) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type FileErrorCode int32
+
+const (
+	FileErrorCode_NO_ERROR          FileErrorCode = 0
+	FileErrorCode_UNSUPPORTED_TYPE  FileErrorCode = 1
+	FileErrorCode_CONVERSION_FAILED FileErrorCode = 2
+	FileErrorCode_OCR_FAILED        FileErrorCode = 3
+	// The PDF is damaged and couldn't be repaired
+	FileErrorCode_DAMAGED FileErrorCode = 4
+	// The PDF was repaired, but strict mode was requested
+	FileErrorCode_REPAIR_NOT_ALLOWED FileErrorCode = 5
+	FileErrorCode_SANITIZE_FAILED    FileErrorCode = 6
+	FileErrorCode_PAGE_COUNT_FAILED  FileErrorCode = 7
+	FileErrorCode_PADDING_FAILED     FileErrorCode = 8
+	FileErrorCode_INTERNAL_ERROR     FileErrorCode = 9
+)
+
+var FileErrorCode_name = map[int32]string{
+	0: "NO_ERROR",
+	1: "UNSUPPORTED_TYPE",
+	2: "CONVERSION_FAILED",
+	3: "OCR_FAILED",
+	4: "DAMAGED",
+	5: "REPAIR_NOT_ALLOWED",
+	6: "SANITIZE_FAILED",
+	7: "PAGE_COUNT_FAILED",
+	8: "PADDING_FAILED",
+	9: "INTERNAL_ERROR",
+}
+var FileErrorCode_value = map[string]int32{
+	"NO_ERROR":           0,
+	"UNSUPPORTED_TYPE":   1,
+	"CONVERSION_FAILED":  2,
+	"OCR_FAILED":         3,
+	"DAMAGED":            4,
+	"REPAIR_NOT_ALLOWED": 5,
+	"SANITIZE_FAILED":    6,
+	"PAGE_COUNT_FAILED":  7,
+	"PADDING_FAILED":     8,
+	"INTERNAL_ERROR":     9,
+}
+
+func (x FileErrorCode) String() string {
+	return proto.EnumName(FileErrorCode_name, int32(x))
+}
+func (FileErrorCode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type PreflightStatus int32
+
+const (
+	// The file passed all checks
+	PreflightStatus_CLEAN PreflightStatus = 0
+	// The file was damaged, and has been repaired
+	PreflightStatus_REPAIRED PreflightStatus = 1
+	// The file was damaged and couldn't be repaired, or was repaired in strict mode
+	PreflightStatus_REJECTED PreflightStatus = 2
+)
+
+var PreflightStatus_name = map[int32]string{
+	0: "CLEAN",
+	1: "REPAIRED",
+	2: "REJECTED",
+}
+var PreflightStatus_value = map[string]int32{
+	"CLEAN":    0,
+	"REPAIRED": 1,
+	"REJECTED": 2,
+}
+
+func (x PreflightStatus) String() string {
+	return proto.EnumName(PreflightStatus_name, int32(x))
+}
+func (PreflightStatus) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+// OptimizePreset Trades output size against quality.  All presets other than
+// NONE compress object streams and linearize the output for fast web view
+type OptimizePreset int32
+
+const (
+	OptimizePreset_NONE OptimizePreset = 0
+	// 72 dpi images
+	OptimizePreset_SCREEN OptimizePreset = 1
+	// 150 dpi images
+	OptimizePreset_EBOOK OptimizePreset = 2
+	// 300 dpi images
+	OptimizePreset_PRINT OptimizePreset = 3
+	// Recompresses streams without altering content
+	OptimizePreset_LOSSLESS OptimizePreset = 4
+)
+
+var OptimizePreset_name = map[int32]string{
+	0: "NONE",
+	1: "SCREEN",
+	2: "EBOOK",
+	3: "PRINT",
+	4: "LOSSLESS",
+}
+var OptimizePreset_value = map[string]int32{
+	"NONE":     0,
+	"SCREEN":   1,
+	"EBOOK":    2,
+	"PRINT":    3,
+	"LOSSLESS": 4,
+}
+
+func (x OptimizePreset) String() string {
+	return proto.EnumName(OptimizePreset_name, int32(x))
+}
+func (OptimizePreset) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type ImageFormat int32
+
+const (
+	ImageFormat_PNG  ImageFormat = 0
+	ImageFormat_JPEG ImageFormat = 1
+)
+
+var ImageFormat_name = map[int32]string{
+	0: "PNG",
+	1: "JPEG",
+}
+var ImageFormat_value = map[string]int32{
+	"PNG":  0,
+	"JPEG": 1,
+}
+
+func (x ImageFormat) String() string {
+	return proto.EnumName(ImageFormat_name, int32(x))
+}
+func (ImageFormat) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type StorageBackend int32
+
+const (
+	// Returns the PDF in the reply
+	StorageBackend_INLINE StorageBackend = 0
+	// Writes the PDF beneath the client's directory in the server's OUTPUT_DIR
+	StorageBackend_FILESYSTEM StorageBackend = 1
+	// Uploads the PDF to an allowed bucket on the server's S3-compatible endpoint
+	StorageBackend_S3 StorageBackend = 2
+)
+
+var StorageBackend_name = map[int32]string{
+	0: "INLINE",
+	1: "FILESYSTEM",
+	2: "S3",
+}
+var StorageBackend_value = map[string]int32{
+	"INLINE":     0,
+	"FILESYSTEM": 1,
+	"S3":         2,
+}
+
+func (x StorageBackend) String() string {
+	return proto.EnumName(StorageBackend_name, int32(x))
+}
+func (StorageBackend) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
 type BuildLatexRequest struct {
 	Files []*File `protobuf:"bytes,1,rep,name=files" json:"files,omitempty"`
+	// When set, a preview of the first page is attached to the reply
+	Preview  *RenderOptions `protobuf:"bytes,2,opt,name=preview" json:"preview,omitempty"`
+	Optimize OptimizePreset `protobuf:"varint,3,opt,name=optimize,enum=builder.OptimizePreset" json:"optimize,omitempty"`
+	// Compiles the files even when the build cache holds their PDF
+	BypassCache bool `protobuf:"varint,4,opt,name=bypass_cache,json=bypassCache" json:"bypass_cache,omitempty"`
+	// When enabled, equal inputs always give byte-identical PDFs
+	Reproducible *ReproducibleOptions `protobuf:"bytes,5,opt,name=reproducible" json:"reproducible,omitempty"`
+	// Where to store the PDF.  It is returned in data by default
+	Output *OutputTarget `protobuf:"bytes,6,opt,name=output" json:"output,omitempty"`
+}
+
+func (m *BuildLatexRequest) Reset()                    { *m = BuildLatexRequest{} }
+func (m *BuildLatexRequest) String() string            { return proto.CompactTextString(m) }
+func (*BuildLatexRequest) ProtoMessage()               {}
+func (*BuildLatexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *BuildLatexRequest) GetFiles() []*File {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *BuildLatexRequest) GetPreview() *RenderOptions {
+	if m != nil {
+		return m.Preview
+	}
+	return nil
+}
+
+func (m *BuildLatexRequest) GetOptimize() OptimizePreset {
+	if m != nil {
+		return m.Optimize
+	}
+	return OptimizePreset_NONE
+}
+
+func (m *BuildLatexRequest) GetBypassCache() bool {
+	if m != nil {
+		return m.BypassCache
+	}
+	return false
+}
+
+func (m *BuildLatexRequest) GetReproducible() *ReproducibleOptions {
+	if m != nil {
+		return m.Reproducible
+	}
+	return nil
+}
+
+func (m *BuildLatexRequest) GetOutput() *OutputTarget {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+type FileReply struct {
+	Data    []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Success bool   `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
+	Note    string `protobuf:"bytes,4,opt,name=note" json:"note,omitempty"`
+	Preview *Image `protobuf:"bytes,5,opt,name=preview" json:"preview,omitempty"`
+	// Set when the output was optimized
+	Optimization *Optimization `protobuf:"bytes,6,opt,name=optimization" json:"optimization,omitempty"`
+	// The outcome of checking each input PDF when merging
+	Preflight []*PreflightResult `protobuf:"bytes,7,rep,name=preflight" json:"preflight,omitempty"`
+	// The outcome for each input file when merging, in request order
+	Files []*FileResult `protobuf:"bytes,8,rep,name=files" json:"files,omitempty"`
+	// Set when the PDF was served from the build cache
+	CacheHit bool `protobuf:"varint,9,opt,name=cache_hit,json=cacheHit" json:"cache_hit,omitempty"`
+	// Details of data, set when the request succeeds.  sha256 is hex encoded
+	Sha256    string `protobuf:"bytes,10,opt,name=sha256" json:"sha256,omitempty"`
+	SizeBytes int64  `protobuf:"varint,11,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	PageCount int32  `protobuf:"varint,12,opt,name=page_count,json=pageCount" json:"page_count,omitempty"`
+	// The version from the PDF header, such as 1.5
+	PdfVersion string `protobuf:"bytes,13,opt,name=pdf_version,json=pdfVersion" json:"pdf_version,omitempty"`
+	// Time taken to produce data, in milliseconds
+	DurationMs int64 `protobuf:"varint,14,opt,name=duration_ms,json=durationMs" json:"duration_ms,omitempty"`
+	// Where the PDF was stored, such as s3://bucket/key, when it wasn't
+	// returned in data
+	Location string `protobuf:"bytes,15,opt,name=location" json:"location,omitempty"`
+	// Identifies the PDF in the artifact store, so later requests can use it
+	// as an input.  Unset when the store is disabled
+	ArtifactId string `protobuf:"bytes,16,opt,name=artifact_id,json=artifactId" json:"artifact_id,omitempty"`
+}
+
+func (m *FileReply) Reset()                    { *m = FileReply{} }
+func (m *FileReply) String() string            { return proto.CompactTextString(m) }
+func (*FileReply) ProtoMessage()               {}
+func (*FileReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *FileReply) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *FileReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *FileReply) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
+func (m *FileReply) GetPreview() *Image {
+	if m != nil {
+		return m.Preview
+	}
+	return nil
+}
+
+func (m *FileReply) GetOptimization() *Optimization {
+	if m != nil {
+		return m.Optimization
+	}
+	return nil
+}
+
+func (m *FileReply) GetPreflight() []*PreflightResult {
+	if m != nil {
+		return m.Preflight
+	}
+	return nil
+}
+
+func (m *FileReply) GetFiles() []*FileResult {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *FileReply) GetCacheHit() bool {
+	if m != nil {
+		return m.CacheHit
+	}
+	return false
+}
+
+func (m *FileReply) GetSha256() string {
+	if m != nil {
+		return m.Sha256
+	}
+	return ""
+}
+
+func (m *FileReply) GetSizeBytes() int64 {
+	if m != nil {
+		return m.SizeBytes
+	}
+	return 0
+}
+
+func (m *FileReply) GetPageCount() int32 {
+	if m != nil {
+		return m.PageCount
+	}
+	return 0
+}
+
+func (m *FileReply) GetPdfVersion() string {
+	if m != nil {
+		return m.PdfVersion
+	}
+	return ""
+}
+
+func (m *FileReply) GetDurationMs() int64 {
+	if m != nil {
+		return m.DurationMs
+	}
+	return 0
+}
+
+func (m *FileReply) GetLocation() string {
+	if m != nil {
+		return m.Location
+	}
+	return ""
+}
+
+func (m *FileReply) GetArtifactId() string {
+	if m != nil {
+		return m.ArtifactId
+	}
+	return ""
+}
+
+type File struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// When building LaTeX, the directory the file is written to beneath the
+	// build directory.  It may not be absolute or contain "..", and name may
+	// not contain slashes.  No two files may share a folder and name
+	Folder string `protobuf:"bytes,3,opt,name=folder" json:"folder,omitempty"`
+	// Fetched by the server in place of data.  file://, s3:// and http(s)://
+	// URIs are accepted where the server allows them
+	SourceUri string `protobuf:"bytes,4,opt,name=source_uri,json=sourceUri" json:"source_uri,omitempty"`
+	// When set, the fetched file must have this hex encoded SHA-256
+	Sha256 string `protobuf:"bytes,5,opt,name=sha256" json:"sha256,omitempty"`
+	// Fetching fails beyond this many bytes.  The server's FETCH_MAX_BYTES is
+	// used when unset, and can't be exceeded
+	MaxBytes int64 `protobuf:"varint,6,opt,name=max_bytes,json=maxBytes" json:"max_bytes,omitempty"`
+	// Uses a PDF kept in the artifact store in place of data
+	ArtifactId string `protobuf:"bytes,7,opt,name=artifact_id,json=artifactId" json:"artifact_id,omitempty"`
+}
+
+func (m *File) Reset()                    { *m = File{} }
+func (m *File) String() string            { return proto.CompactTextString(m) }
+func (*File) ProtoMessage()               {}
+func (*File) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *File) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *File) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *File) GetFolder() string {
+	if m != nil {
+		return m.Folder
+	}
+	return ""
+}
+
+func (m *File) GetSourceUri() string {
+	if m != nil {
+		return m.SourceUri
+	}
+	return ""
+}
+
+func (m *File) GetSha256() string {
+	if m != nil {
+		return m.Sha256
+	}
+	return ""
+}
+
+func (m *File) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+func (m *File) GetArtifactId() string {
+	if m != nil {
+		return m.ArtifactId
+	}
+	return ""
+}
+
+type PipelineRequest struct {
+	Steps []*PipelineStep `protobuf:"bytes,1,rep,name=steps" json:"steps,omitempty"`
+	// Names the step whose output is returned.  Defaults to the last step
+	Output string `protobuf:"bytes,2,opt,name=output" json:"output,omitempty"`
+	// Where to store the returned PDF.  It is returned in data by default
+	Target *OutputTarget `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+	// When enabled, equal inputs always give byte-identical PDFs
+	Reproducible *ReproducibleOptions `protobuf:"bytes,4,opt,name=reproducible" json:"reproducible,omitempty"`
+}
+
+func (m *PipelineRequest) Reset()                    { *m = PipelineRequest{} }
+func (m *PipelineRequest) String() string            { return proto.CompactTextString(m) }
+func (*PipelineRequest) ProtoMessage()               {}
+func (*PipelineRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *PipelineRequest) GetSteps() []*PipelineStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+func (m *PipelineRequest) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+func (m *PipelineRequest) GetTarget() *OutputTarget {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+func (m *PipelineRequest) GetReproducible() *ReproducibleOptions {
+	if m != nil {
+		return m.Reproducible
+	}
+	return nil
+}
+
+type PipelineStep struct {
+	// Identifies the step, so later steps can use its output.  Letters, digits,
+	// dashes and underscores only
+	Name string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Kind StepKind `protobuf:"varint,2,opt,name=kind,enum=builder.StepKind" json:"kind,omitempty"`
+	// Names of the steps whose outputs this step uses, in order
+	Inputs []string `protobuf:"bytes,3,rep,name=inputs" json:"inputs,omitempty"`
+	// Files used by the step after the outputs of inputs
+	Files []*File `protobuf:"bytes,4,rep,name=files" json:"files,omitempty"`
+	// The file name other steps see the output as.  Defaults to the step's name,
+	// with .tex for templates and .pdf otherwise
+	OutputName string `protobuf:"bytes,5,opt,name=output_name,json=outputName" json:"output_name,omitempty"`
+	// For RENDER_TEMPLATE, the JSON data the template is executed with
+	TemplateData string `protobuf:"bytes,6,opt,name=template_data,json=templateData" json:"template_data,omitempty"`
+	// For MERGE_PDFS, pads each input with a blank page to an even page count
+	ForceEven bool `protobuf:"varint,7,opt,name=force_even,json=forceEven" json:"force_even,omitempty"`
+	// For STAMP, places the stamp beneath the content instead of over it
+	Underlay bool `protobuf:"varint,8,opt,name=underlay" json:"underlay,omitempty"`
+	// For ENCRYPT, the password needed to open the PDF, and the password
+	// needed to change it, which defaults to the user password
+	UserPassword  string `protobuf:"bytes,9,opt,name=user_password,json=userPassword" json:"user_password,omitempty"`
+	OwnerPassword string `protobuf:"bytes,10,opt,name=owner_password,json=ownerPassword" json:"owner_password,omitempty"`
+	// For OPTIMIZE, the preset to apply
+	Optimize OptimizePreset `protobuf:"varint,11,opt,name=optimize,enum=builder.OptimizePreset" json:"optimize,omitempty"`
+}
+
+func (m *PipelineStep) Reset()                    { *m = PipelineStep{} }
+func (m *PipelineStep) String() string            { return proto.CompactTextString(m) }
+func (*PipelineStep) ProtoMessage()               {}
+func (*PipelineStep) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *PipelineStep) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *PipelineStep) GetKind() StepKind {
+	if m != nil {
+		return m.Kind
+	}
+	return StepKind_STEP_UNSPECIFIED
+}
+
+func (m *PipelineStep) GetInputs() []string {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *PipelineStep) GetFiles() []*File {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *PipelineStep) GetOutputName() string {
+	if m != nil {
+		return m.OutputName
+	}
+	return ""
+}
+
+func (m *PipelineStep) GetTemplateData() string {
+	if m != nil {
+		return m.TemplateData
+	}
+	return ""
+}
+
+func (m *PipelineStep) GetForceEven() bool {
+	if m != nil {
+		return m.ForceEven
+	}
+	return false
+}
+
+func (m *PipelineStep) GetUnderlay() bool {
+	if m != nil {
+		return m.Underlay
+	}
+	return false
+}
+
+func (m *PipelineStep) GetUserPassword() string {
+	if m != nil {
+		return m.UserPassword
+	}
+	return ""
+}
+
+func (m *PipelineStep) GetOwnerPassword() string {
+	if m != nil {
+		return m.OwnerPassword
+	}
+	return ""
+}
+
+func (m *PipelineStep) GetOptimize() OptimizePreset {
+	if m != nil {
+		return m.Optimize
+	}
+	return OptimizePreset_NONE
+}
+
+type StepResult struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// UNPROCESSED when the step didn't run because an earlier step failed
+	Status FileStatus `protobuf:"varint,2,opt,name=status,enum=builder.FileStatus" json:"status,omitempty"`
+	// When the step started, in milliseconds since the pipeline started
+	StartedMs  int64  `protobuf:"varint,3,opt,name=started_ms,json=startedMs" json:"started_ms,omitempty"`
+	DurationMs int64  `protobuf:"varint,4,opt,name=duration_ms,json=durationMs" json:"duration_ms,omitempty"`
+	Message    string `protobuf:"bytes,5,opt,name=message" json:"message,omitempty"`
+	SizeBytes  int64  `protobuf:"varint,6,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+}
+
+func (m *StepResult) Reset()                    { *m = StepResult{} }
+func (m *StepResult) String() string            { return proto.CompactTextString(m) }
+func (*StepResult) ProtoMessage()               {}
+func (*StepResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *StepResult) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *StepResult) GetStatus() FileStatus {
+	if m != nil {
+		return m.Status
+	}
+	return FileStatus_UNPROCESSED
+}
+
+func (m *StepResult) GetStartedMs() int64 {
+	if m != nil {
+		return m.StartedMs
+	}
+	return 0
+}
+
+func (m *StepResult) GetDurationMs() int64 {
+	if m != nil {
+		return m.DurationMs
+	}
+	return 0
+}
+
+func (m *StepResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *StepResult) GetSizeBytes() int64 {
+	if m != nil {
+		return m.SizeBytes
+	}
+	return 0
+}
+
+type PipelineReply struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Note    string `protobuf:"bytes,2,opt,name=note" json:"note,omitempty"`
+	// The output of the final step
+	Output *FileReply `protobuf:"bytes,3,opt,name=output" json:"output,omitempty"`
+	// The outcome of each step, in request order
+	Steps []*StepResult `protobuf:"bytes,4,rep,name=steps" json:"steps,omitempty"`
+}
+
+func (m *PipelineReply) Reset()                    { *m = PipelineReply{} }
+func (m *PipelineReply) String() string            { return proto.CompactTextString(m) }
+func (*PipelineReply) ProtoMessage()               {}
+func (*PipelineReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *PipelineReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *PipelineReply) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
+func (m *PipelineReply) GetOutput() *FileReply {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+func (m *PipelineReply) GetSteps() []*StepResult {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+type GetArtifactRequest struct {
+	ArtifactId string `protobuf:"bytes,1,opt,name=artifact_id,json=artifactId" json:"artifact_id,omitempty"`
+}
+
+func (m *GetArtifactRequest) Reset()                    { *m = GetArtifactRequest{} }
+func (m *GetArtifactRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetArtifactRequest) ProtoMessage()               {}
+func (*GetArtifactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *GetArtifactRequest) GetArtifactId() string {
+	if m != nil {
+		return m.ArtifactId
+	}
+	return ""
+}
+
+type DeleteArtifactRequest struct {
+	ArtifactId string `protobuf:"bytes,1,opt,name=artifact_id,json=artifactId" json:"artifact_id,omitempty"`
+}
+
+func (m *DeleteArtifactRequest) Reset()                    { *m = DeleteArtifactRequest{} }
+func (m *DeleteArtifactRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteArtifactRequest) ProtoMessage()               {}
+func (*DeleteArtifactRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *DeleteArtifactRequest) GetArtifactId() string {
+	if m != nil {
+		return m.ArtifactId
+	}
+	return ""
+}
+
+type DeleteArtifactReply struct {
+	Success bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	Note    string `protobuf:"bytes,2,opt,name=note" json:"note,omitempty"`
+}
+
+func (m *DeleteArtifactReply) Reset()                    { *m = DeleteArtifactReply{} }
+func (m *DeleteArtifactReply) String() string            { return proto.CompactTextString(m) }
+func (*DeleteArtifactReply) ProtoMessage()               {}
+func (*DeleteArtifactReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *DeleteArtifactReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *DeleteArtifactReply) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
+type HealthReply struct {
+	Healthy bool `protobuf:"varint,1,opt,name=healthy" json:"healthy,omitempty"`
+}
+
+func (m *HealthReply) Reset()                    { *m = HealthReply{} }
+func (m *HealthReply) String() string            { return proto.CompactTextString(m) }
+func (*HealthReply) ProtoMessage()               {}
+func (*HealthReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *HealthReply) GetHealthy() bool {
+	if m != nil {
+		return m.Healthy
+	}
+	return false
+}
+
+type HealthRequest struct {
+}
+
+func (m *HealthRequest) Reset()                    { *m = HealthRequest{} }
+func (m *HealthRequest) String() string            { return proto.CompactTextString(m) }
+func (*HealthRequest) ProtoMessage()               {}
+func (*HealthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type MergeRequest struct {
+	Files     []*File `protobuf:"bytes,1,rep,name=files" json:"files,omitempty"`
+	ForceEven bool    `protobuf:"varint,2,opt,name=force_even,json=forceEven" json:"force_even,omitempty"`
+	// When set, a preview of the first page is attached to the reply
+	Preview *RenderOptions `protobuf:"bytes,3,opt,name=preview" json:"preview,omitempty"`
+	// When enabled, images are converted to searchable PDFs with a text layer
+	Ocr      *OCROptions    `protobuf:"bytes,4,opt,name=ocr" json:"ocr,omitempty"`
+	Optimize OptimizePreset `protobuf:"varint,5,opt,name=optimize,enum=builder.OptimizePreset" json:"optimize,omitempty"`
+	// Neutralises JavaScript, launch actions, embedded files and XFA forms in input PDFs
+	Sanitize bool `protobuf:"varint,6,opt,name=sanitize" json:"sanitize,omitempty"`
+	// Rejects damaged input PDFs, even when they can be repaired
+	Strict bool `protobuf:"varint,7,opt,name=strict" json:"strict,omitempty"`
+	// Merges every file that could be prepared, skipping those that can't be
+	// used, rather than failing.  Internal errors and cancellation still fail
+	SkipInvalid bool `protobuf:"varint,8,opt,name=skip_invalid,json=skipInvalid" json:"skip_invalid,omitempty"`
+	// When enabled, equal inputs always give byte-identical PDFs
+	Reproducible *ReproducibleOptions `protobuf:"bytes,9,opt,name=reproducible" json:"reproducible,omitempty"`
+	// Where to store the PDF.  It is returned in data by default
+	Output *OutputTarget `protobuf:"bytes,10,opt,name=output" json:"output,omitempty"`
+}
+
+func (m *MergeRequest) Reset()                    { *m = MergeRequest{} }
+func (m *MergeRequest) String() string            { return proto.CompactTextString(m) }
+func (*MergeRequest) ProtoMessage()               {}
+func (*MergeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *MergeRequest) GetFiles() []*File {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *MergeRequest) GetForceEven() bool {
+	if m != nil {
+		return m.ForceEven
+	}
+	return false
+}
+
+func (m *MergeRequest) GetPreview() *RenderOptions {
+	if m != nil {
+		return m.Preview
+	}
+	return nil
+}
+
+func (m *MergeRequest) GetOcr() *OCROptions {
+	if m != nil {
+		return m.Ocr
+	}
+	return nil
+}
+
+func (m *MergeRequest) GetOptimize() OptimizePreset {
+	if m != nil {
+		return m.Optimize
+	}
+	return OptimizePreset_NONE
+}
+
+func (m *MergeRequest) GetSanitize() bool {
+	if m != nil {
+		return m.Sanitize
+	}
+	return false
+}
+
+func (m *MergeRequest) GetStrict() bool {
+	if m != nil {
+		return m.Strict
+	}
+	return false
+}
+
+func (m *MergeRequest) GetSkipInvalid() bool {
+	if m != nil {
+		return m.SkipInvalid
+	}
+	return false
+}
+
+func (m *MergeRequest) GetReproducible() *ReproducibleOptions {
+	if m != nil {
+		return m.Reproducible
+	}
+	return nil
+}
+
+func (m *MergeRequest) GetOutput() *OutputTarget {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+type FileResult struct {
+	// Position of the file in the request
+	Index int32  `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Name  string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	// Detected type of the file, such as application/pdf
+	MimeType string `protobuf:"bytes,3,opt,name=mime_type,json=mimeType" json:"mime_type,omitempty"`
+	// Pages the file contributes to the output, including any blank page added by force_even
+	PageCount int32         `protobuf:"varint,4,opt,name=page_count,json=pageCount" json:"page_count,omitempty"`
+	Status    FileStatus    `protobuf:"varint,5,opt,name=status,enum=builder.FileStatus" json:"status,omitempty"`
+	ErrorCode FileErrorCode `protobuf:"varint,6,opt,name=error_code,json=errorCode,enum=builder.FileErrorCode" json:"error_code,omitempty"`
+	Message   string        `protobuf:"bytes,7,opt,name=message" json:"message,omitempty"`
+	// Set for PDF inputs that were checked
+	Preflight *PreflightResult `protobuf:"bytes,8,opt,name=preflight" json:"preflight,omitempty"`
+}
+
+func (m *FileResult) Reset()                    { *m = FileResult{} }
+func (m *FileResult) String() string            { return proto.CompactTextString(m) }
+func (*FileResult) ProtoMessage()               {}
+func (*FileResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *FileResult) GetIndex() int32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *FileResult) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *FileResult) GetMimeType() string {
+	if m != nil {
+		return m.MimeType
+	}
+	return ""
+}
+
+func (m *FileResult) GetPageCount() int32 {
+	if m != nil {
+		return m.PageCount
+	}
+	return 0
+}
+
+func (m *FileResult) GetStatus() FileStatus {
+	if m != nil {
+		return m.Status
+	}
+	return FileStatus_UNPROCESSED
+}
+
+func (m *FileResult) GetErrorCode() FileErrorCode {
+	if m != nil {
+		return m.ErrorCode
+	}
+	return FileErrorCode_NO_ERROR
+}
+
+func (m *FileResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *FileResult) GetPreflight() *PreflightResult {
+	if m != nil {
+		return m.Preflight
+	}
+	return nil
+}
+
+type PreflightResult struct {
+	// Position of the file in the request
+	Index  int32           `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Name   string          `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Status PreflightStatus `protobuf:"varint,3,opt,name=status,enum=builder.PreflightStatus" json:"status,omitempty"`
+	// Problems reported when checking the file
+	Problems []string `protobuf:"bytes,4,rep,name=problems" json:"problems,omitempty"`
+	// Active content neutralised when sanitizing, such as JavaScript or EmbeddedFiles
+	Removed []string `protobuf:"bytes,5,rep,name=removed" json:"removed,omitempty"`
+}
+
+func (m *PreflightResult) Reset()                    { *m = PreflightResult{} }
+func (m *PreflightResult) String() string            { return proto.CompactTextString(m) }
+func (*PreflightResult) ProtoMessage()               {}
+func (*PreflightResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *PreflightResult) GetIndex() int32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *PreflightResult) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *PreflightResult) GetStatus() PreflightStatus {
+	if m != nil {
+		return m.Status
+	}
+	return PreflightStatus_CLEAN
+}
+
+func (m *PreflightResult) GetProblems() []string {
+	if m != nil {
+		return m.Problems
+	}
+	return nil
+}
+
+func (m *PreflightResult) GetRemoved() []string {
+	if m != nil {
+		return m.Removed
+	}
+	return nil
+}
+
+type Optimization struct {
+	Preset        OptimizePreset `protobuf:"varint,1,opt,name=preset,enum=builder.OptimizePreset" json:"preset,omitempty"`
+	OriginalSize  int64          `protobuf:"varint,2,opt,name=original_size,json=originalSize" json:"original_size,omitempty"`
+	OptimizedSize int64          `protobuf:"varint,3,opt,name=optimized_size,json=optimizedSize" json:"optimized_size,omitempty"`
+}
+
+func (m *Optimization) Reset()                    { *m = Optimization{} }
+func (m *Optimization) String() string            { return proto.CompactTextString(m) }
+func (*Optimization) ProtoMessage()               {}
+func (*Optimization) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *Optimization) GetPreset() OptimizePreset {
+	if m != nil {
+		return m.Preset
+	}
+	return OptimizePreset_NONE
+}
+
+func (m *Optimization) GetOriginalSize() int64 {
+	if m != nil {
+		return m.OriginalSize
+	}
+	return 0
+}
+
+func (m *Optimization) GetOptimizedSize() int64 {
+	if m != nil {
+		return m.OptimizedSize
+	}
+	return 0
+}
+
+type RenderOptions struct {
+	// Resolution to render at.  Defaults to 150
+	Dpi int32 `protobuf:"varint,1,opt,name=dpi" json:"dpi,omitempty"`
+	// When set, images are scaled down to fit within this many pixels on each side
+	MaxDimension int32       `protobuf:"varint,2,opt,name=max_dimension,json=maxDimension" json:"max_dimension,omitempty"`
+	Format       ImageFormat `protobuf:"varint,3,opt,name=format,enum=builder.ImageFormat" json:"format,omitempty"`
+}
+
+func (m *RenderOptions) Reset()                    { *m = RenderOptions{} }
+func (m *RenderOptions) String() string            { return proto.CompactTextString(m) }
+func (*RenderOptions) ProtoMessage()               {}
+func (*RenderOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *RenderOptions) GetDpi() int32 {
+	if m != nil {
+		return m.Dpi
+	}
+	return 0
 }
 
-func (m *BuildLatexRequest) Reset()                    { *m = BuildLatexRequest{} }
-func (m *BuildLatexRequest) String() string            { return proto.CompactTextString(m) }
-func (*BuildLatexRequest) ProtoMessage()               {}
-func (*BuildLatexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (m *RenderOptions) GetMaxDimension() int32 {
+	if m != nil {
+		return m.MaxDimension
+	}
+	return 0
+}
 
-func (m *BuildLatexRequest) GetFiles() []*File {
+func (m *RenderOptions) GetFormat() ImageFormat {
 	if m != nil {
-		return m.Files
+		return m.Format
+	}
+	return ImageFormat_PNG
+}
+
+type RenderRequest struct {
+	// PDF to render.  Deprecated in favour of file
+	Pdf []byte `protobuf:"bytes,1,opt,name=pdf,proto3" json:"pdf,omitempty"`
+	// Build this document and render the result, instead of using pdf
+	Build *BuildLatexRequest `protobuf:"bytes,2,opt,name=build" json:"build,omitempty"`
+	// Page numbers to render, starting at 1.  All pages are rendered when empty
+	Pages   []int32        `protobuf:"varint,3,rep,packed,name=pages" json:"pages,omitempty"`
+	Options *RenderOptions `protobuf:"bytes,4,opt,name=options" json:"options,omitempty"`
+	// PDF to render, which may instead give a source uri or an artifact id
+	File *File `protobuf:"bytes,5,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *RenderRequest) Reset()                    { *m = RenderRequest{} }
+func (m *RenderRequest) String() string            { return proto.CompactTextString(m) }
+func (*RenderRequest) ProtoMessage()               {}
+func (*RenderRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *RenderRequest) GetPdf() []byte {
+	if m != nil {
+		return m.Pdf
 	}
 	return nil
 }
 
-type FileReply struct {
-	Data    []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	Success bool   `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
-	Note    string `protobuf:"bytes,4,opt,name=note" json:"note,omitempty"`
+func (m *RenderRequest) GetBuild() *BuildLatexRequest {
+	if m != nil {
+		return m.Build
+	}
+	return nil
 }
 
-func (m *FileReply) Reset()                    { *m = FileReply{} }
-func (m *FileReply) String() string            { return proto.CompactTextString(m) }
-func (*FileReply) ProtoMessage()               {}
-func (*FileReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (m *RenderRequest) GetPages() []int32 {
+	if m != nil {
+		return m.Pages
+	}
+	return nil
+}
 
-func (m *FileReply) GetData() []byte {
+func (m *RenderRequest) GetOptions() *RenderOptions {
 	if m != nil {
-		return m.Data
+		return m.Options
 	}
 	return nil
 }
 
-func (m *FileReply) GetSuccess() bool {
+func (m *RenderRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type RenderReply struct {
+	Images  []*Image `protobuf:"bytes,1,rep,name=images" json:"images,omitempty"`
+	Success bool     `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	Note    string   `protobuf:"bytes,3,opt,name=note" json:"note,omitempty"`
+}
+
+func (m *RenderReply) Reset()                    { *m = RenderReply{} }
+func (m *RenderReply) String() string            { return proto.CompactTextString(m) }
+func (*RenderReply) ProtoMessage()               {}
+func (*RenderReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *RenderReply) GetImages() []*Image {
+	if m != nil {
+		return m.Images
+	}
+	return nil
+}
+
+func (m *RenderReply) GetSuccess() bool {
 	if m != nil {
 		return m.Success
 	}
 	return false
 }
 
-func (m *FileReply) GetNote() string {
+func (m *RenderReply) GetNote() string {
 	if m != nil {
 		return m.Note
 	}
 	return ""
 }
 
-type File struct {
-	Name   string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
-	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
-	Folder string `protobuf:"bytes,3,opt,name=folder" json:"folder,omitempty"`
+type Image struct {
+	Page     int32  `protobuf:"varint,1,opt,name=page" json:"page,omitempty"`
+	Data     []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	MimeType string `protobuf:"bytes,3,opt,name=mime_type,json=mimeType" json:"mime_type,omitempty"`
 }
 
-func (m *File) Reset()                    { *m = File{} }
-func (m *File) String() string            { return proto.CompactTextString(m) }
-func (*File) ProtoMessage()               {}
-func (*File) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (m *Image) Reset()                    { *m = Image{} }
+func (m *Image) String() string            { return proto.CompactTextString(m) }
+func (*Image) ProtoMessage()               {}
+func (*Image) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
-func (m *File) GetName() string {
+func (m *Image) GetPage() int32 {
 	if m != nil {
-		return m.Name
+		return m.Page
 	}
-	return ""
+	return 0
 }
 
-func (m *File) GetData() []byte {
+func (m *Image) GetData() []byte {
 	if m != nil {
 		return m.Data
 	}
 	return nil
 }
 
-func (m *File) GetFolder() string {
+func (m *Image) GetMimeType() string {
 	if m != nil {
-		return m.Folder
+		return m.MimeType
 	}
 	return ""
 }
 
-type HealthReply struct {
-	Healthy bool `protobuf:"varint,1,opt,name=healthy" json:"healthy,omitempty"`
+type OutputTarget struct {
+	Backend StorageBackend `protobuf:"varint,1,opt,name=backend,enum=builder.StorageBackend" json:"backend,omitempty"`
+	// For FILESYSTEM, the path to write, relative to the client's directory
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	// For S3, the bucket and key to write.  The key is placed beneath a prefix
+	// naming the client
+	Bucket string `protobuf:"bytes,3,opt,name=bucket" json:"bucket,omitempty"`
+	Key    string `protobuf:"bytes,4,opt,name=key" json:"key,omitempty"`
 }
 
-func (m *HealthReply) Reset()                    { *m = HealthReply{} }
-func (m *HealthReply) String() string            { return proto.CompactTextString(m) }
-func (*HealthReply) ProtoMessage()               {}
-func (*HealthReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *OutputTarget) Reset()                    { *m = OutputTarget{} }
+func (m *OutputTarget) String() string            { return proto.CompactTextString(m) }
+func (*OutputTarget) ProtoMessage()               {}
+func (*OutputTarget) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
-func (m *HealthReply) GetHealthy() bool {
+func (m *OutputTarget) GetBackend() StorageBackend {
 	if m != nil {
-		return m.Healthy
+		return m.Backend
+	}
+	return StorageBackend_INLINE
+}
+
+func (m *OutputTarget) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *OutputTarget) GetBucket() string {
+	if m != nil {
+		return m.Bucket
+	}
+	return ""
+}
+
+func (m *OutputTarget) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type ReproducibleOptions struct {
+	Enabled bool `protobuf:"varint,1,opt,name=enabled" json:"enabled,omitempty"`
+	// Seconds since the Unix epoch recorded as the creation date, and used for
+	// \today.  Defaults to the server's REPRODUCIBLE_SOURCE_DATE_EPOCH
+	SourceDateEpoch int64 `protobuf:"varint,2,opt,name=source_date_epoch,json=sourceDateEpoch" json:"source_date_epoch,omitempty"`
+}
+
+func (m *ReproducibleOptions) Reset()                    { *m = ReproducibleOptions{} }
+func (m *ReproducibleOptions) String() string            { return proto.CompactTextString(m) }
+func (*ReproducibleOptions) ProtoMessage()               {}
+func (*ReproducibleOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *ReproducibleOptions) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
 	}
 	return false
 }
 
-type HealthRequest struct {
+func (m *ReproducibleOptions) GetSourceDateEpoch() int64 {
+	if m != nil {
+		return m.SourceDateEpoch
+	}
+	return 0
 }
 
-func (m *HealthRequest) Reset()                    { *m = HealthRequest{} }
-func (m *HealthRequest) String() string            { return proto.CompactTextString(m) }
-func (*HealthRequest) ProtoMessage()               {}
-func (*HealthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+type OCROptions struct {
+	Enabled bool `protobuf:"varint,1,opt,name=enabled" json:"enabled,omitempty"`
+	// Tesseract languages, such as eng or deu+eng.  Defaults to eng
+	Language string `protobuf:"bytes,2,opt,name=language" json:"language,omitempty"`
+}
 
-type MergeRequest struct {
-	Files     []*File `protobuf:"bytes,1,rep,name=files" json:"files,omitempty"`
-	ForceEven bool    `protobuf:"varint,2,opt,name=force_even,json=forceEven" json:"force_even,omitempty"`
+func (m *OCROptions) Reset()                    { *m = OCROptions{} }
+func (m *OCROptions) String() string            { return proto.CompactTextString(m) }
+func (*OCROptions) ProtoMessage()               {}
+func (*OCROptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *OCROptions) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
+	}
+	return false
 }
 
-func (m *MergeRequest) Reset()                    { *m = MergeRequest{} }
-func (m *MergeRequest) String() string            { return proto.CompactTextString(m) }
-func (*MergeRequest) ProtoMessage()               {}
-func (*MergeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (m *OCROptions) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
 
-func (m *MergeRequest) GetFiles() []*File {
+type ExtractTextRequest struct {
+	// A PDF, or a jpg or png image when OCR is enabled
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// When enabled, images and PDF pages without any text are run through OCR
+	Ocr *OCROptions `protobuf:"bytes,2,opt,name=ocr" json:"ocr,omitempty"`
+}
+
+func (m *ExtractTextRequest) Reset()                    { *m = ExtractTextRequest{} }
+func (m *ExtractTextRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExtractTextRequest) ProtoMessage()               {}
+func (*ExtractTextRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ExtractTextRequest) GetFile() *File {
 	if m != nil {
-		return m.Files
+		return m.File
 	}
 	return nil
 }
 
-func (m *MergeRequest) GetForceEven() bool {
+func (m *ExtractTextRequest) GetOcr() *OCROptions {
 	if m != nil {
-		return m.ForceEven
+		return m.Ocr
+	}
+	return nil
+}
+
+type PageText struct {
+	Page int32  `protobuf:"varint,1,opt,name=page" json:"page,omitempty"`
+	Text string `protobuf:"bytes,2,opt,name=text" json:"text,omitempty"`
+}
+
+func (m *PageText) Reset()                    { *m = PageText{} }
+func (m *PageText) String() string            { return proto.CompactTextString(m) }
+func (*PageText) ProtoMessage()               {}
+func (*PageText) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *PageText) GetPage() int32 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *PageText) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
+type ExtractTextReply struct {
+	Pages   []*PageText `protobuf:"bytes,1,rep,name=pages" json:"pages,omitempty"`
+	Success bool        `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	Note    string      `protobuf:"bytes,3,opt,name=note" json:"note,omitempty"`
+}
+
+func (m *ExtractTextReply) Reset()                    { *m = ExtractTextReply{} }
+func (m *ExtractTextReply) String() string            { return proto.CompactTextString(m) }
+func (*ExtractTextReply) ProtoMessage()               {}
+func (*ExtractTextReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *ExtractTextReply) GetPages() []*PageText {
+	if m != nil {
+		return m.Pages
+	}
+	return nil
+}
+
+func (m *ExtractTextReply) GetSuccess() bool {
+	if m != nil {
+		return m.Success
 	}
 	return false
 }
 
+func (m *ExtractTextReply) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*BuildLatexRequest)(nil), "builder.BuildLatexRequest")
 	proto.RegisterType((*FileReply)(nil), "builder.FileReply")
 	proto.RegisterType((*File)(nil), "builder.File")
+	proto.RegisterType((*PipelineRequest)(nil), "builder.PipelineRequest")
+	proto.RegisterType((*PipelineStep)(nil), "builder.PipelineStep")
+	proto.RegisterType((*StepResult)(nil), "builder.StepResult")
+	proto.RegisterType((*PipelineReply)(nil), "builder.PipelineReply")
+	proto.RegisterType((*GetArtifactRequest)(nil), "builder.GetArtifactRequest")
+	proto.RegisterType((*DeleteArtifactRequest)(nil), "builder.DeleteArtifactRequest")
+	proto.RegisterType((*DeleteArtifactReply)(nil), "builder.DeleteArtifactReply")
 	proto.RegisterType((*HealthReply)(nil), "builder.HealthReply")
 	proto.RegisterType((*HealthRequest)(nil), "builder.HealthRequest")
 	proto.RegisterType((*MergeRequest)(nil), "builder.MergeRequest")
+	proto.RegisterType((*FileResult)(nil), "builder.FileResult")
+	proto.RegisterType((*PreflightResult)(nil), "builder.PreflightResult")
+	proto.RegisterType((*Optimization)(nil), "builder.Optimization")
+	proto.RegisterType((*RenderOptions)(nil), "builder.RenderOptions")
+	proto.RegisterType((*RenderRequest)(nil), "builder.RenderRequest")
+	proto.RegisterType((*RenderReply)(nil), "builder.RenderReply")
+	proto.RegisterType((*Image)(nil), "builder.Image")
+	proto.RegisterType((*OutputTarget)(nil), "builder.OutputTarget")
+	proto.RegisterType((*ReproducibleOptions)(nil), "builder.ReproducibleOptions")
+	proto.RegisterType((*OCROptions)(nil), "builder.OCROptions")
+	proto.RegisterType((*ExtractTextRequest)(nil), "builder.ExtractTextRequest")
+	proto.RegisterType((*PageText)(nil), "builder.PageText")
+	proto.RegisterType((*ExtractTextReply)(nil), "builder.ExtractTextReply")
+	proto.RegisterEnum("builder.StepKind", StepKind_name, StepKind_value)
+	proto.RegisterEnum("builder.FileStatus", FileStatus_name, FileStatus_value)
+	proto.RegisterEnum("builder.FileErrorCode", FileErrorCode_name, FileErrorCode_value)
+	proto.RegisterEnum("builder.PreflightStatus", PreflightStatus_name, PreflightStatus_value)
+	proto.RegisterEnum("builder.OptimizePreset", OptimizePreset_name, OptimizePreset_value)
+	proto.RegisterEnum("builder.ImageFormat", ImageFormat_name, ImageFormat_value)
+	proto.RegisterEnum("builder.StorageBackend", StorageBackend_name, StorageBackend_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -189,6 +1533,17 @@ type BuilderClient interface {
 	BuildLatex(ctx context.Context, in *BuildLatexRequest, opts ...grpc1.CallOption) (*FileReply, error)
 	Merge(ctx context.Context, in *MergeRequest, opts ...grpc1.CallOption) (*FileReply, error)
 	Health(ctx context.Context, in *HealthRequest, opts ...grpc1.CallOption) (*HealthReply, error)
+	// Render Rasterises pages of a PDF to images
+	Render(ctx context.Context, in *RenderRequest, opts ...grpc1.CallOption) (*RenderReply, error)
+	// ExtractText Returns the text of each page of a PDF or image
+	ExtractText(ctx context.Context, in *ExtractTextRequest, opts ...grpc1.CallOption) (*ExtractTextReply, error)
+	// GetArtifact Returns a PDF kept from an earlier request
+	GetArtifact(ctx context.Context, in *GetArtifactRequest, opts ...grpc1.CallOption) (*FileReply, error)
+	// DeleteArtifact Removes a PDF kept from an earlier request before it expires
+	DeleteArtifact(ctx context.Context, in *DeleteArtifactRequest, opts ...grpc1.CallOption) (*DeleteArtifactReply, error)
+	// RunPipeline Runs a graph of steps, passing outputs between them, and
+	// returns the output of the final step
+	RunPipeline(ctx context.Context, in *PipelineRequest, opts ...grpc1.CallOption) (*PipelineReply, error)
 }
 
 type builderClient struct {
@@ -226,6 +1581,51 @@ func (c *builderClient) Health(ctx context.Context, in *HealthRequest, opts ...g
 	return out, nil
 }
 
+func (c *builderClient) Render(ctx context.Context, in *RenderRequest, opts ...grpc1.CallOption) (*RenderReply, error) {
+	out := new(RenderReply)
+	err := grpc1.Invoke(ctx, "/builder.Builder/Render", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) ExtractText(ctx context.Context, in *ExtractTextRequest, opts ...grpc1.CallOption) (*ExtractTextReply, error) {
+	out := new(ExtractTextReply)
+	err := grpc1.Invoke(ctx, "/builder.Builder/ExtractText", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) GetArtifact(ctx context.Context, in *GetArtifactRequest, opts ...grpc1.CallOption) (*FileReply, error) {
+	out := new(FileReply)
+	err := grpc1.Invoke(ctx, "/builder.Builder/GetArtifact", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) DeleteArtifact(ctx context.Context, in *DeleteArtifactRequest, opts ...grpc1.CallOption) (*DeleteArtifactReply, error) {
+	out := new(DeleteArtifactReply)
+	err := grpc1.Invoke(ctx, "/builder.Builder/DeleteArtifact", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) RunPipeline(ctx context.Context, in *PipelineRequest, opts ...grpc1.CallOption) (*PipelineReply, error) {
+	out := new(PipelineReply)
+	err := grpc1.Invoke(ctx, "/builder.Builder/RunPipeline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Builder service
 
 type BuilderServer interface {
@@ -233,6 +1633,17 @@ type BuilderServer interface {
 	BuildLatex(context.Context, *BuildLatexRequest) (*FileReply, error)
 	Merge(context.Context, *MergeRequest) (*FileReply, error)
 	Health(context.Context, *HealthRequest) (*HealthReply, error)
+	// Render Rasterises pages of a PDF to images
+	Render(context.Context, *RenderRequest) (*RenderReply, error)
+	// ExtractText Returns the text of each page of a PDF or image
+	ExtractText(context.Context, *ExtractTextRequest) (*ExtractTextReply, error)
+	// GetArtifact Returns a PDF kept from an earlier request
+	GetArtifact(context.Context, *GetArtifactRequest) (*FileReply, error)
+	// DeleteArtifact Removes a PDF kept from an earlier request before it expires
+	DeleteArtifact(context.Context, *DeleteArtifactRequest) (*DeleteArtifactReply, error)
+	// RunPipeline Runs a graph of steps, passing outputs between them, and
+	// returns the output of the final step
+	RunPipeline(context.Context, *PipelineRequest) (*PipelineReply, error)
 }
 
 func RegisterBuilderServer(s *grpc1.Server, srv BuilderServer) {
@@ -293,6 +1704,96 @@ func _Builder_Health_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Builder_Render_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Render(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/builder.Builder/Render",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Render(ctx, req.(*RenderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_ExtractText_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractTextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).ExtractText(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/builder.Builder/ExtractText",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).ExtractText(ctx, req.(*ExtractTextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_GetArtifact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetArtifactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).GetArtifact(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/builder.Builder/GetArtifact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).GetArtifact(ctx, req.(*GetArtifactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_DeleteArtifact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteArtifactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).DeleteArtifact(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/builder.Builder/DeleteArtifact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).DeleteArtifact(ctx, req.(*DeleteArtifactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_RunPipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).RunPipeline(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/builder.Builder/RunPipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).RunPipeline(ctx, req.(*PipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Builder_serviceDesc = grpc1.ServiceDesc{
 	ServiceName: "builder.Builder",
 	HandlerType: (*BuilderServer)(nil),
@@ -309,6 +1810,26 @@ var _Builder_serviceDesc = grpc1.ServiceDesc{
 			MethodName: "Health",
 			Handler:    _Builder_Health_Handler,
 		},
+		{
+			MethodName: "Render",
+			Handler:    _Builder_Render_Handler,
+		},
+		{
+			MethodName: "ExtractText",
+			Handler:    _Builder_ExtractText_Handler,
+		},
+		{
+			MethodName: "GetArtifact",
+			Handler:    _Builder_GetArtifact_Handler,
+		},
+		{
+			MethodName: "DeleteArtifact",
+			Handler:    _Builder_DeleteArtifact_Handler,
+		},
+		{
+			MethodName: "RunPipeline",
+			Handler:    _Builder_RunPipeline_Handler,
+		},
 	},
 	Streams:  []grpc1.StreamDesc{},
 	Metadata: "builder.proto",
@@ -317,25 +1838,141 @@ var _Builder_serviceDesc = grpc1.ServiceDesc{
 func init() { proto.RegisterFile("builder.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 309 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x52, 0x4d, 0x4f, 0xc2, 0x40,
-	0x10, 0x75, 0xe5, 0xa3, 0x74, 0x80, 0x18, 0x27, 0x4a, 0x36, 0x24, 0x26, 0xcd, 0x7a, 0xb0, 0x27,
-	0x0e, 0xe8, 0x81, 0x93, 0x07, 0x12, 0x89, 0x07, 0xb9, 0xec, 0x1f, 0x30, 0x0b, 0x0c, 0x42, 0x52,
-	0x5a, 0x6c, 0xb7, 0x44, 0x7e, 0x9a, 0xff, 0xce, 0xec, 0x94, 0x56, 0x08, 0xf1, 0xe0, 0x6d, 0xde,
-	0xbe, 0x79, 0xaf, 0xef, 0x4d, 0x0a, 0xdd, 0x59, 0xbe, 0x8e, 0x16, 0x94, 0x0e, 0xb6, 0x69, 0x62,
-	0x13, 0xf4, 0x0e, 0x50, 0x8d, 0xe0, 0x7a, 0xec, 0xc6, 0x37, 0x63, 0xe9, 0x4b, 0xd3, 0x67, 0x4e,
-	0x99, 0xc5, 0x7b, 0x68, 0x2c, 0xd7, 0x11, 0x65, 0x52, 0x04, 0xb5, 0xb0, 0x3d, 0xec, 0x0e, 0x4a,
-	0xf1, 0x64, 0x1d, 0x91, 0x2e, 0x38, 0x35, 0x05, 0x9f, 0x21, 0x6d, 0xa3, 0x3d, 0x22, 0xd4, 0x17,
-	0xc6, 0x1a, 0x29, 0x02, 0x11, 0x76, 0x34, 0xcf, 0x28, 0xc1, 0xcb, 0xf2, 0xf9, 0x9c, 0xb2, 0x4c,
-	0xd6, 0x02, 0x11, 0xb6, 0x74, 0x09, 0xdd, 0x76, 0x9c, 0x58, 0x92, 0xf5, 0x40, 0x84, 0xbe, 0xe6,
-	0x59, 0x4d, 0xa0, 0xee, 0xec, 0x98, 0x33, 0x1b, 0x62, 0x27, 0xc7, 0x99, 0x0d, 0x55, 0xee, 0x97,
-	0x47, 0xee, 0x3d, 0x68, 0x2e, 0x13, 0x17, 0x8a, 0xcd, 0x7d, 0x7d, 0x40, 0xea, 0x01, 0xda, 0xaf,
-	0x64, 0x22, 0xbb, 0x2a, 0x82, 0x49, 0xf0, 0x56, 0x0c, 0xf7, 0xec, 0xd8, 0xd2, 0x25, 0x54, 0x57,
-	0xd0, 0x2d, 0x17, 0xb9, 0xb5, 0xd2, 0xd0, 0x99, 0x52, 0xfa, 0x41, 0xff, 0xb9, 0x02, 0xde, 0x01,
-	0x2c, 0x93, 0x74, 0x4e, 0xef, 0xb4, 0xa3, 0x98, 0x03, 0xb6, 0xb4, 0xcf, 0x2f, 0x2f, 0x3b, 0x8a,
-	0x87, 0xdf, 0x02, 0xbc, 0x71, 0x21, 0xc3, 0x67, 0x80, 0xdf, 0x53, 0x63, 0xbf, 0xb2, 0x3b, 0xbb,
-	0x7f, 0x1f, 0x4f, 0x3f, 0xe5, 0x8a, 0xa8, 0x0b, 0x7c, 0x82, 0x06, 0xe7, 0xc3, 0xdb, 0x8a, 0x3e,
-	0xce, 0xfb, 0x87, 0x6a, 0x04, 0xcd, 0xa2, 0x26, 0xf6, 0x2a, 0xfe, 0xa4, 0x77, 0xff, 0xe6, 0xec,
-	0x9d, 0x95, 0xb3, 0x26, 0xff, 0x2a, 0x8f, 0x3f, 0x01, 0x00, 0x00, 0xff, 0xff, 0x79, 0xd0, 0x4f,
-	0x00, 0x3b, 0x02, 0x00, 0x00,
+	// 2169 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0xcd, 0x72, 0xe3, 0xc6,
+	0xf1, 0x17, 0xf8, 0xcd, 0x26, 0x45, 0x61, 0x67, 0xd7, 0xfa, 0xe3, 0xaf, 0xb5, 0x63, 0x19, 0xae,
+	0x8d, 0x65, 0x39, 0x71, 0x36, 0x5a, 0xaf, 0x6b, 0x73, 0x71, 0x99, 0x22, 0x21, 0x99, 0xbb, 0x14,
+	0x89, 0x1a, 0x52, 0x4e, 0xd6, 0xa9, 0x0a, 0x0a, 0x22, 0x46, 0x14, 0x6a, 0x49, 0x02, 0x01, 0x40,
+	0xad, 0xe4, 0x4b, 0x8e, 0x49, 0x55, 0x8e, 0xc9, 0x1b, 0xf8, 0xe2, 0x7b, 0x8e, 0xa9, 0xca, 0x31,
+	0x79, 0x80, 0xbc, 0x50, 0xaa, 0xe7, 0x03, 0x04, 0x49, 0x49, 0x59, 0x55, 0x6e, 0xe8, 0x8f, 0xe9,
+	0xe9, 0xee, 0xe9, 0xfe, 0x4d, 0x0f, 0x60, 0xf3, 0x6c, 0xee, 0x4f, 0x3c, 0x16, 0x7d, 0x1e, 0x46,
+	0x41, 0x12, 0x90, 0xb2, 0x24, 0xcd, 0xbf, 0xe5, 0xe0, 0xc1, 0x21, 0x7e, 0x77, 0xdd, 0x84, 0x5d,
+	0x51, 0xf6, 0xfb, 0x39, 0x8b, 0x13, 0xf2, 0x31, 0x14, 0xcf, 0xfd, 0x09, 0x8b, 0x0d, 0x6d, 0x37,
+	0xbf, 0x57, 0x3b, 0xd8, 0xfc, 0x5c, 0xad, 0x3e, 0xf2, 0x27, 0x8c, 0x0a, 0x19, 0x79, 0x0a, 0xe5,
+	0x30, 0x62, 0x97, 0x3e, 0x7b, 0x6b, 0xe4, 0x76, 0xb5, 0xbd, 0xda, 0xc1, 0x76, 0xaa, 0x46, 0xd9,
+	0xcc, 0x63, 0x51, 0x3f, 0x4c, 0xfc, 0x60, 0x16, 0x53, 0xa5, 0x46, 0x9e, 0x41, 0x25, 0x08, 0x13,
+	0x7f, 0xea, 0x7f, 0xcf, 0x8c, 0xfc, 0xae, 0xb6, 0xd7, 0x38, 0xf8, 0xbf, 0x74, 0x49, 0x5f, 0x0a,
+	0xec, 0x88, 0xc5, 0x2c, 0xa1, 0xa9, 0x22, 0xf9, 0x08, 0xea, 0x67, 0xd7, 0xa1, 0x1b, 0xc7, 0xce,
+	0xc8, 0x1d, 0x5d, 0x30, 0xa3, 0xb0, 0xab, 0xed, 0x55, 0x68, 0x4d, 0xf0, 0x5a, 0xc8, 0x22, 0x5f,
+	0x43, 0x3d, 0x62, 0x61, 0x14, 0x78, 0xf3, 0x91, 0x7f, 0x36, 0x61, 0x46, 0x91, 0xbb, 0xf3, 0x7e,
+	0xc6, 0x9d, 0x85, 0x50, 0x39, 0xb5, 0xb4, 0x82, 0xfc, 0x1c, 0x4a, 0xc1, 0x3c, 0x09, 0xe7, 0x89,
+	0x51, 0xe2, 0x6b, 0xdf, 0x5b, 0xf8, 0xc5, 0xd9, 0x43, 0x37, 0x1a, 0xb3, 0x84, 0x4a, 0x25, 0xf3,
+	0x8f, 0x05, 0xa8, 0xf2, 0x54, 0xb0, 0x70, 0x72, 0x4d, 0x08, 0x14, 0x3c, 0x37, 0x71, 0x0d, 0x6d,
+	0x57, 0xdb, 0xab, 0x53, 0xfe, 0x4d, 0x0c, 0x28, 0xc7, 0xf3, 0xd1, 0x88, 0xc5, 0x31, 0x8f, 0xb4,
+	0x42, 0x15, 0x89, 0xda, 0xb3, 0x20, 0x11, 0x71, 0x54, 0x29, 0xff, 0x26, 0x7b, 0x8b, 0x54, 0x0a,
+	0xdf, 0x1b, 0xe9, 0xfe, 0x9d, 0xa9, 0x3b, 0x66, 0x8b, 0x14, 0xfe, 0x0a, 0xea, 0x32, 0x33, 0x2e,
+	0xc6, 0xb1, 0xee, 0x6e, 0x46, 0x48, 0x97, 0x54, 0xc9, 0x97, 0x50, 0x0d, 0x23, 0x76, 0x3e, 0xf1,
+	0xc7, 0x17, 0x89, 0x51, 0xe6, 0x07, 0x6b, 0xa4, 0xeb, 0x6c, 0x25, 0xa1, 0x2c, 0x9e, 0x4f, 0x12,
+	0xba, 0x50, 0x25, 0x9f, 0xaa, 0x62, 0xa8, 0xf0, 0x35, 0x0f, 0x97, 0x8b, 0x41, 0xa8, 0xcb, 0x92,
+	0x78, 0x0c, 0x55, 0x7e, 0x48, 0xce, 0x85, 0x9f, 0x18, 0x55, 0x1e, 0x77, 0x85, 0x33, 0xbe, 0xf1,
+	0x13, 0xb2, 0x0d, 0xa5, 0xf8, 0xc2, 0x3d, 0x78, 0xfe, 0xa5, 0x01, 0x3c, 0x74, 0x49, 0x91, 0x0f,
+	0x00, 0x62, 0xff, 0x7b, 0xe6, 0x9c, 0x5d, 0x27, 0x2c, 0x36, 0x6a, 0xbb, 0xda, 0x5e, 0x9e, 0x56,
+	0x91, 0x73, 0x88, 0x0c, 0x14, 0x87, 0xee, 0x98, 0x39, 0xa3, 0x60, 0x3e, 0x4b, 0x8c, 0xfa, 0xae,
+	0xb6, 0x57, 0xa4, 0x55, 0xe4, 0xb4, 0x90, 0x41, 0x3e, 0x84, 0x5a, 0xe8, 0x9d, 0x3b, 0x97, 0x2c,
+	0x8a, 0x31, 0x1f, 0x9b, 0xdc, 0x34, 0x84, 0xde, 0xf9, 0xb7, 0x82, 0x83, 0x0a, 0xde, 0x3c, 0xe2,
+	0x29, 0x70, 0xa6, 0xb1, 0xd1, 0xe0, 0xf6, 0x41, 0xb1, 0x4e, 0x62, 0xb2, 0x03, 0x95, 0x49, 0x30,
+	0xe2, 0x94, 0xb1, 0xc5, 0x97, 0xa7, 0x34, 0x2e, 0x76, 0xa3, 0xc4, 0x3f, 0x77, 0x47, 0x89, 0xe3,
+	0x7b, 0x86, 0x2e, 0xac, 0x2b, 0x56, 0xc7, 0x33, 0xff, 0xae, 0x41, 0x01, 0xf3, 0xc0, 0x8f, 0xd5,
+	0x9d, 0x32, 0x43, 0x93, 0xc7, 0xea, 0x4e, 0x59, 0x5a, 0x18, 0xb9, 0x4c, 0x61, 0x6c, 0x43, 0xe9,
+	0x3c, 0xc0, 0xf4, 0xf1, 0xba, 0xa8, 0x52, 0x49, 0xf1, 0x2c, 0x04, 0xf3, 0x68, 0xc4, 0x9c, 0x79,
+	0xe4, 0xcb, 0xe2, 0xa8, 0x0a, 0xce, 0x69, 0xe4, 0x67, 0x92, 0x57, 0x5c, 0x4a, 0xde, 0x63, 0xa8,
+	0x4e, 0xdd, 0x2b, 0x99, 0xbb, 0x12, 0x8f, 0xad, 0x32, 0x75, 0xaf, 0x44, 0xea, 0x56, 0xbc, 0x2f,
+	0xaf, 0x79, 0xff, 0x2f, 0x0d, 0xb6, 0x6c, 0x3f, 0x64, 0x13, 0x7f, 0xc6, 0x54, 0xef, 0x7f, 0x06,
+	0xc5, 0x38, 0x61, 0xa1, 0xea, 0xfd, 0x45, 0x69, 0x29, 0xc5, 0x41, 0xc2, 0x42, 0x2a, 0x74, 0xd0,
+	0x2d, 0xd9, 0x37, 0x39, 0xe1, 0x96, 0xa0, 0xb0, 0x9f, 0x12, 0xde, 0x32, 0x3c, 0xca, 0xdb, 0xfb,
+	0x49, 0x28, 0xad, 0x35, 0x70, 0xe1, 0xbe, 0x0d, 0x6c, 0xfe, 0x29, 0x0f, 0xf5, 0xac, 0x83, 0x37,
+	0x9e, 0xc7, 0x13, 0x28, 0xbc, 0xf1, 0x67, 0x1e, 0xf7, 0xb5, 0x71, 0xf0, 0x20, 0x35, 0x8f, 0x0b,
+	0x5e, 0xf9, 0x33, 0x8f, 0x72, 0x31, 0x06, 0xe5, 0xcf, 0xc2, 0x79, 0x82, 0xad, 0x9b, 0xc7, 0xa0,
+	0x04, 0xb5, 0x40, 0xc5, 0xc2, 0x1d, 0xa8, 0xf8, 0x21, 0xd4, 0x44, 0x0e, 0x1c, 0xbe, 0xbd, 0x38,
+	0x2d, 0x10, 0xac, 0x1e, 0x3a, 0xf1, 0x31, 0x6c, 0x26, 0x6c, 0x1a, 0x4e, 0xdc, 0x84, 0x39, 0xbc,
+	0x3a, 0x4a, 0x5c, 0xa5, 0xae, 0x98, 0x6d, 0xac, 0x92, 0x0f, 0x00, 0xce, 0x03, 0x2c, 0x06, 0x76,
+	0xc9, 0x66, 0xfc, 0xe0, 0x2a, 0xb4, 0xca, 0x39, 0xd6, 0x25, 0x9b, 0x61, 0xc9, 0xce, 0x11, 0x61,
+	0x27, 0xee, 0xb5, 0x51, 0x11, 0x6d, 0xa6, 0x68, 0xb4, 0x3f, 0x8f, 0x59, 0xe4, 0x20, 0x3c, 0xbe,
+	0x0d, 0x22, 0x8f, 0xf7, 0x61, 0x95, 0xd6, 0x91, 0x69, 0x4b, 0x1e, 0x79, 0x02, 0x8d, 0xe0, 0xed,
+	0x2c, 0xab, 0x25, 0x7a, 0x72, 0x93, 0x73, 0x53, 0xb5, 0x2c, 0x60, 0xd7, 0xde, 0x11, 0xb0, 0xcd,
+	0x7f, 0x6a, 0x00, 0xbc, 0x46, 0x38, 0x34, 0xdc, 0x78, 0x10, 0x9f, 0x41, 0x29, 0x4e, 0xdc, 0x64,
+	0x1e, 0xcb, 0xa3, 0x58, 0xc6, 0x94, 0x01, 0x17, 0x51, 0xa9, 0xc2, 0x3b, 0x23, 0x71, 0xa3, 0x84,
+	0x79, 0xd8, 0xbf, 0x79, 0x89, 0x0f, 0x82, 0x73, 0x12, 0xaf, 0xf6, 0x77, 0x61, 0xad, 0xbf, 0x0d,
+	0x28, 0x4f, 0x59, 0x1c, 0xbb, 0x63, 0x75, 0x1a, 0x8a, 0x5c, 0x41, 0x9e, 0xd2, 0x0a, 0xf2, 0x98,
+	0x7f, 0xd5, 0x60, 0x73, 0xd1, 0x1d, 0x88, 0xf4, 0x19, 0x54, 0xd7, 0x6e, 0x46, 0xf5, 0x5c, 0x06,
+	0xd5, 0xf7, 0xd3, 0xe6, 0x10, 0x4d, 0x40, 0x56, 0x90, 0x33, 0x9c, 0x5c, 0xa7, 0x0d, 0xf3, 0xa9,
+	0xea, 0xba, 0xc2, 0x0a, 0xc8, 0x2e, 0x32, 0x29, 0x7b, 0xce, 0x7c, 0x0e, 0xe4, 0x98, 0x25, 0x4d,
+	0xd9, 0xc5, 0xaa, 0x6d, 0x57, 0x7a, 0x5d, 0x5b, 0xeb, 0xf5, 0x17, 0xf0, 0x5e, 0x9b, 0x4d, 0x58,
+	0xc2, 0xee, 0xbd, 0xb2, 0x05, 0x0f, 0x57, 0x57, 0xde, 0x3b, 0x19, 0xe6, 0x27, 0x50, 0xfb, 0x86,
+	0xb9, 0x93, 0xe4, 0x22, 0x5d, 0x7c, 0xc1, 0xc9, 0x6b, 0xb5, 0x58, 0x92, 0xe6, 0x16, 0x6c, 0x2a,
+	0x45, 0xee, 0x9f, 0xf9, 0x63, 0x1e, 0xea, 0x27, 0x2c, 0x1a, 0xb3, 0x7b, 0x4d, 0x27, 0xcb, 0x1d,
+	0x94, 0x5b, 0xed, 0xa0, 0xcc, 0xf0, 0x92, 0x7f, 0xb7, 0xe1, 0xe5, 0x09, 0xe4, 0x83, 0x51, 0x24,
+	0xa1, 0x69, 0x71, 0x3e, 0xfd, 0x16, 0x55, 0xaa, 0x28, 0x5f, 0x6a, 0x99, 0xe2, 0xbb, 0xce, 0x38,
+	0x3b, 0x50, 0x89, 0xdd, 0x99, 0x9f, 0xe0, 0xa2, 0x92, 0xe8, 0x67, 0x45, 0x73, 0xe4, 0x4f, 0x22,
+	0x7f, 0x94, 0x48, 0x18, 0x90, 0x14, 0xce, 0x45, 0xf1, 0x1b, 0x3f, 0x74, 0xfc, 0xd9, 0xa5, 0x3b,
+	0xf1, 0x3d, 0x89, 0x03, 0x35, 0xe4, 0x75, 0x04, 0x6b, 0x0d, 0x56, 0xab, 0xff, 0xc3, 0x5c, 0x04,
+	0xef, 0x32, 0x17, 0xfd, 0x98, 0x03, 0x58, 0x4c, 0x05, 0xe4, 0x11, 0x14, 0xfd, 0x99, 0xc7, 0xae,
+	0xf8, 0x11, 0x17, 0xa9, 0x20, 0x52, 0x40, 0xc8, 0x65, 0x00, 0x01, 0xaf, 0x31, 0x7f, 0xca, 0x9c,
+	0xe4, 0x3a, 0x64, 0xf2, 0x62, 0xac, 0x20, 0x63, 0x78, 0x1d, 0xb2, 0x95, 0x09, 0xa0, 0xb0, 0x3a,
+	0x01, 0x2c, 0xc0, 0xa4, 0xf8, 0xdf, 0xc1, 0xe4, 0x39, 0x00, 0x8b, 0xa2, 0x20, 0x72, 0x46, 0x81,
+	0x27, 0x72, 0xdd, 0x38, 0xd8, 0x5e, 0x5a, 0x60, 0xa1, 0xb8, 0x15, 0x78, 0x8c, 0x56, 0x99, 0xfa,
+	0xcc, 0x62, 0x48, 0x79, 0x19, 0x43, 0x96, 0xa6, 0xaa, 0xca, 0xae, 0xf6, 0x8e, 0x53, 0x95, 0xf9,
+	0x03, 0x5e, 0xbd, 0xcb, 0xe2, 0x7b, 0xe4, 0xeb, 0x69, 0x1a, 0xb3, 0x98, 0xa3, 0x6f, 0xd8, 0x72,
+	0x25, 0xf0, 0x1d, 0xa8, 0x84, 0x51, 0x70, 0x36, 0x61, 0x53, 0x81, 0x31, 0x55, 0x9a, 0xd2, 0x18,
+	0x5d, 0xc4, 0xa6, 0xc1, 0x25, 0xf3, 0x8c, 0x22, 0x17, 0x29, 0xd2, 0xfc, 0xb3, 0x06, 0xf5, 0xec,
+	0x48, 0x49, 0x7e, 0x01, 0xa5, 0x90, 0x57, 0xaf, 0xa1, 0xdd, 0x5d, 0xdc, 0x52, 0x0d, 0xaf, 0xa3,
+	0x20, 0xf2, 0xc7, 0xfe, 0xcc, 0x9d, 0x38, 0x08, 0xad, 0x3c, 0x8c, 0x3c, 0xad, 0x2b, 0xe6, 0x00,
+	0x6b, 0x1c, 0xaf, 0x23, 0xb9, 0xdc, 0x13, 0x5a, 0x02, 0xe6, 0x37, 0x53, 0x2e, 0xaa, 0x99, 0x09,
+	0x6c, 0x2e, 0x35, 0x27, 0xd1, 0x21, 0xef, 0x85, 0xbe, 0x4c, 0x17, 0x7e, 0xe2, 0x76, 0x38, 0x0f,
+	0x79, 0xfe, 0x94, 0xcd, 0xf8, 0x40, 0x98, 0xe3, 0xb2, 0xfa, 0xd4, 0xbd, 0x6a, 0x2b, 0x1e, 0xf9,
+	0x19, 0xce, 0x60, 0xd1, 0xd4, 0x4d, 0x64, 0xf6, 0x1e, 0x2d, 0x4f, 0xdb, 0x47, 0x5c, 0x46, 0xa5,
+	0x8e, 0xf9, 0x0f, 0x4d, 0x6d, 0xab, 0x00, 0x48, 0x87, 0x7c, 0xe8, 0x9d, 0xcb, 0x79, 0x1f, 0x3f,
+	0xc9, 0x53, 0x28, 0x72, 0x13, 0xf2, 0x25, 0xb4, 0x93, 0x1a, 0x5c, 0x7b, 0x5b, 0x51, 0xa1, 0x88,
+	0x67, 0x8d, 0x25, 0x2c, 0x66, 0x8c, 0x22, 0x15, 0x04, 0xc2, 0x52, 0x20, 0x62, 0x93, 0x40, 0x73,
+	0x2b, 0x2c, 0x49, 0x35, 0xf2, 0x11, 0x14, 0x10, 0xf0, 0xe4, 0xbb, 0x61, 0x05, 0x0b, 0xb9, 0xc8,
+	0x1c, 0x41, 0x4d, 0xf9, 0x8f, 0xd0, 0xfb, 0x53, 0x28, 0xf9, 0x53, 0x77, 0x9c, 0xe2, 0xe7, 0xea,
+	0x5b, 0x43, 0x4a, 0xb3, 0xf8, 0x9e, 0xbb, 0x19, 0xdf, 0xf3, 0x19, 0x7c, 0xef, 0x42, 0x91, 0x2f,
+	0x47, 0x21, 0xc6, 0x22, 0x0f, 0xa5, 0x10, 0x4a, 0xde, 0xda, 0x20, 0x7c, 0x57, 0xcb, 0x9b, 0x7f,
+	0x80, 0x7a, 0x16, 0x60, 0xc8, 0x2f, 0xa1, 0x7c, 0xe6, 0x8e, 0xde, 0xb0, 0x99, 0xb7, 0x56, 0x77,
+	0x83, 0x24, 0x88, 0xdc, 0x31, 0x3b, 0x14, 0x62, 0xaa, 0xf4, 0x84, 0x1f, 0xc9, 0x85, 0x6a, 0x1b,
+	0xfc, 0x46, 0x2c, 0x3d, 0x9b, 0x8f, 0xde, 0xc8, 0xb1, 0xb4, 0x4a, 0x25, 0x85, 0x07, 0xfa, 0x86,
+	0x5d, 0xcb, 0xa9, 0x1b, 0x3f, 0xcd, 0xdf, 0xc2, 0xc3, 0x1b, 0xd0, 0x11, 0x73, 0xc2, 0x66, 0xee,
+	0xd9, 0x84, 0x79, 0xea, 0xda, 0x92, 0x24, 0xd9, 0x87, 0x07, 0x72, 0x7e, 0xf7, 0x70, 0xb2, 0x63,
+	0x61, 0x30, 0xba, 0x90, 0xb5, 0xbe, 0x25, 0x04, 0x6d, 0x37, 0x61, 0x16, 0xb2, 0xcd, 0x43, 0x80,
+	0xc5, 0xb5, 0x71, 0x87, 0x4d, 0x7c, 0x99, 0xb8, 0xb3, 0xf1, 0x1c, 0xd3, 0x99, 0x93, 0x2f, 0x13,
+	0x49, 0x9b, 0xbf, 0x03, 0x62, 0x5d, 0x25, 0x91, 0x3b, 0x4a, 0x86, 0xec, 0x2a, 0xbd, 0xcb, 0x55,
+	0x35, 0x68, 0xb7, 0x56, 0x83, 0xba, 0xc7, 0x72, 0x77, 0xdf, 0x63, 0xe6, 0x01, 0x54, 0x6c, 0x77,
+	0xcc, 0xd0, 0xf8, 0x6d, 0x47, 0x9a, 0xb0, 0x2b, 0x35, 0xf7, 0xf3, 0x6f, 0xd3, 0x07, 0x7d, 0xc9,
+	0x27, 0xac, 0xb6, 0x4f, 0x54, 0x9d, 0x8b, 0x62, 0x5b, 0x0c, 0xdd, 0xca, 0xba, 0x2a, 0xfd, 0x7b,
+	0x95, 0xdb, 0xfe, 0x0f, 0x1a, 0x54, 0xd4, 0xd8, 0x4e, 0x1e, 0x81, 0x3e, 0x18, 0x5a, 0xb6, 0x73,
+	0xda, 0x1b, 0xd8, 0x56, 0xab, 0x73, 0xd4, 0xb1, 0xda, 0xfa, 0x06, 0x79, 0x08, 0x5b, 0xd4, 0xea,
+	0xb5, 0x2d, 0xea, 0x0c, 0xad, 0x13, 0xbb, 0xdb, 0x1c, 0x5a, 0xba, 0x46, 0xb6, 0xa0, 0x76, 0x78,
+	0xda, 0xe9, 0xb6, 0x1d, 0xa4, 0x7f, 0xa3, 0xe7, 0xc8, 0x03, 0xd8, 0x6c, 0xf5, 0x7b, 0xdf, 0x5a,
+	0x74, 0xe8, 0x74, 0x4e, 0x9a, 0xc7, 0x96, 0x9e, 0x27, 0x04, 0x1a, 0x8a, 0xd5, 0x3f, 0x3a, 0xea,
+	0xb4, 0x2c, 0xbd, 0x40, 0x1a, 0x00, 0x27, 0x16, 0x3d, 0xb6, 0x1c, 0xbb, 0x7d, 0x34, 0xd0, 0x8b,
+	0xa4, 0x0a, 0xc5, 0xc1, 0xb0, 0x79, 0x62, 0xeb, 0x25, 0x52, 0x83, 0xb2, 0xd5, 0x6b, 0xd1, 0xd7,
+	0xf6, 0x50, 0x2f, 0x93, 0x3a, 0x54, 0xfa, 0xf6, 0xb0, 0x73, 0xd2, 0xf9, 0xce, 0xd2, 0x2b, 0xfb,
+	0x5f, 0x89, 0xeb, 0x50, 0x40, 0x31, 0xee, 0x7d, 0xda, 0xb3, 0x69, 0xbf, 0x65, 0x0d, 0x06, 0xdc,
+	0xc3, 0x12, 0xe4, 0xfa, 0xaf, 0x74, 0x0d, 0x2d, 0x0c, 0x5e, 0x75, 0x6c, 0xdb, 0x6a, 0xeb, 0x39,
+	0x02, 0x50, 0x3a, 0x6a, 0x76, 0xba, 0x56, 0x5b, 0xcf, 0xef, 0xff, 0x5b, 0x83, 0xcd, 0xa5, 0x3b,
+	0x09, 0xed, 0xf7, 0xfa, 0x8e, 0x45, 0x69, 0x9f, 0xea, 0x1b, 0x18, 0xf8, 0x69, 0x6f, 0x70, 0x6a,
+	0xdb, 0x7d, 0x3a, 0xb4, 0xda, 0xce, 0xf0, 0xb5, 0x8d, 0x31, 0xbe, 0x07, 0x0f, 0x84, 0xff, 0x83,
+	0x4e, 0xbf, 0xe7, 0x48, 0x63, 0x39, 0x0c, 0xa1, 0xdf, 0xa2, 0x8a, 0xce, 0xe3, 0xae, 0xed, 0x26,
+	0x86, 0xdc, 0xd6, 0x0b, 0x64, 0x1b, 0x08, 0xb5, 0xec, 0x66, 0x87, 0x3a, 0xbd, 0xfe, 0xd0, 0x69,
+	0x76, 0xbb, 0xfd, 0x5f, 0x5b, 0x6d, 0xbd, 0x88, 0x49, 0x1c, 0x34, 0x7b, 0x9d, 0x61, 0xe7, 0x3b,
+	0x4b, 0xad, 0x2c, 0xe1, 0x06, 0x76, 0xf3, 0xd8, 0x72, 0x5a, 0xfd, 0xd3, 0xde, 0x50, 0xb1, 0xcb,
+	0x98, 0x37, 0xbb, 0xd9, 0x6e, 0x77, 0x7a, 0xc7, 0x8a, 0x57, 0x41, 0x5e, 0xa7, 0x37, 0xb4, 0x68,
+	0xaf, 0xd9, 0x95, 0x5e, 0x57, 0xf7, 0x5f, 0x64, 0x6e, 0x3e, 0x99, 0x9a, 0x2a, 0x14, 0x5b, 0x5d,
+	0xab, 0xd9, 0xd3, 0x37, 0x30, 0x42, 0xe1, 0x89, 0xd5, 0xd6, 0x35, 0x41, 0xbd, 0xb4, 0x5a, 0x43,
+	0x0c, 0x61, 0xff, 0x25, 0x34, 0x96, 0xaf, 0x19, 0x52, 0x81, 0x42, 0xaf, 0xdf, 0xb3, 0xf4, 0x0d,
+	0xcc, 0xdb, 0xa0, 0x45, 0x2d, 0xab, 0xa7, 0x6b, 0x68, 0xce, 0x3a, 0xec, 0xf7, 0x5f, 0xe9, 0x39,
+	0xfc, 0xb4, 0x69, 0xa7, 0x37, 0xd4, 0xf3, 0x68, 0xab, 0xdb, 0x1f, 0x0c, 0xba, 0xd6, 0x60, 0xa0,
+	0x17, 0xf6, 0x77, 0xa1, 0x96, 0x41, 0x7b, 0x52, 0x86, 0xbc, 0xdd, 0x3b, 0xd6, 0x37, 0xd0, 0xe2,
+	0x4b, 0xdb, 0x3a, 0xd6, 0xb5, 0xfd, 0x2f, 0xa0, 0xb1, 0x0c, 0x2e, 0xb8, 0x47, 0xa7, 0xd7, 0xed,
+	0xf0, 0xfd, 0x1a, 0x00, 0x47, 0x9d, 0xae, 0x35, 0x78, 0x3d, 0x18, 0x5a, 0x27, 0xba, 0x86, 0x87,
+	0x39, 0x78, 0xa6, 0xe7, 0x0e, 0xfe, 0x52, 0x80, 0xf2, 0xa1, 0xa8, 0x71, 0xf2, 0x15, 0xc0, 0xe2,
+	0x02, 0x20, 0x77, 0xdc, 0x0a, 0x3b, 0x37, 0xbc, 0x0d, 0xcc, 0x0d, 0xf2, 0x05, 0x14, 0xf9, 0xe4,
+	0x4b, 0x16, 0x73, 0x57, 0x76, 0x12, 0xbe, 0x65, 0xd5, 0x0b, 0x28, 0x89, 0x09, 0x9a, 0x2c, 0x6e,
+	0x8f, 0xa5, 0x91, 0x7a, 0xe7, 0xd1, 0x1a, 0x3f, 0x5d, 0x29, 0x6e, 0x0a, 0xb2, 0x7a, 0xef, 0xac,
+	0xaf, 0xcc, 0x5c, 0x29, 0xe6, 0x06, 0x39, 0x86, 0x5a, 0xa6, 0xf5, 0xc9, 0xe3, 0x54, 0x6d, 0x1d,
+	0xa4, 0x76, 0xfe, 0xff, 0x66, 0xa1, 0x30, 0xf4, 0x35, 0xd4, 0x32, 0xaf, 0x9b, 0x8c, 0xa1, 0xf5,
+	0x37, 0xcf, 0x2d, 0xe1, 0xdb, 0xd0, 0x58, 0x7e, 0xae, 0x90, 0x9f, 0xa4, 0x7a, 0x37, 0xbe, 0x80,
+	0x76, 0xde, 0xbf, 0x55, 0x2e, 0x2c, 0x36, 0xa1, 0x46, 0xe7, 0x33, 0xf5, 0x14, 0x24, 0xc6, 0xda,
+	0x2f, 0x11, 0x65, 0x68, 0xfb, 0x06, 0x09, 0x37, 0x71, 0x56, 0xe2, 0xff, 0x5d, 0x9f, 0xfd, 0x67,
+	0x00, 0x36, 0xe5, 0x91, 0xf3, 0x88, 0x15, 0x00, 0x00,
 }
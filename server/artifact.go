@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// artifacts keeps the output of every request, so later requests can use it
+// without it passing through the client.  It is nil when the store is disabled
+var artifacts *diskCache
+
+// artifactIDPattern matches the IDs handed out by newArtifactID
+var artifactIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newArtifactID returns a random, unguessable artifact ID
+func newArtifactID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// artifactKey returns where the artifact id belonging to client is stored.
+// Artifacts are private to the client that made them, so another client
+// looking up the same ID finds nothing
+func artifactKey(client string, id string) string {
+	sum := sha256.Sum256([]byte(client + "\x00" + id))
+	return hex.EncodeToString(sum[:])
+}
+
+// saveArtifact keeps the PDF in a successful reply in the artifact store, with
+// its page count, and records its ID in the reply.  Failing to keep it doesn't
+// fail the request
+func saveArtifact(ctx context.Context, reply *pb.FileReply) {
+	if artifacts == nil {
+		return
+	}
+
+	id, err := newArtifactID()
+	if err == nil {
+		err = artifacts.put(artifactKey(clientFromContext(ctx), id), encodePDFEntry(reply.Data, int(reply.PageCount)))
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("saving artifact")
+		return
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("gedoc.artifact_id", id))
+	reply.ArtifactId = id
+}
+
+// loadArtifact returns the PDF stored as id by the calling client, and its page
+// count
+func loadArtifact(ctx context.Context, id string) ([]byte, int, error) {
+	if artifacts == nil {
+		return nil, 0, invalidInput("the artifact store is not enabled")
+	}
+	if !artifactIDPattern.MatchString(id) {
+		return nil, 0, invalidInput("invalid artifact id %q", id)
+	}
+
+	entry, ok := artifacts.get(artifactKey(clientFromContext(ctx), id))
+	if !ok || len(entry) < pdfEntryHeader {
+		cacheRequests.WithLabelValues(artifacts.name, cacheMiss).Inc()
+		return nil, 0, &builder.Error{Kind: builder.KindNotFound, Err: fmt.Errorf("artifact %s not found, or expired", id)}
+	}
+	cacheRequests.WithLabelValues(artifacts.name, cacheHit).Inc()
+
+	pdf, pages := decodePDFEntry(entry)
+	return pdf, pages, nil
+}
+
+// deleteArtifact removes the artifact id stored by the calling client
+func deleteArtifact(ctx context.Context, id string) error {
+	if artifacts == nil {
+		return invalidInput("the artifact store is not enabled")
+	}
+	if !artifactIDPattern.MatchString(id) {
+		return invalidInput("invalid artifact id %q", id)
+	}
+
+	if !artifacts.delete(artifactKey(clientFromContext(ctx), id)) {
+		return &builder.Error{Kind: builder.KindNotFound, Err: fmt.Errorf("artifact %s not found, or expired", id)}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/grpc/codes"
+)
+
+// TestArtifacts Checks artifacts can be used by the client that made them
+// until deleted, and by no other client
+func TestArtifacts(t *testing.T) {
+	c, _, cleanup := testCache(t, 1000, 0)
+	defer cleanup()
+
+	artifacts = c
+	defer func() { artifacts = nil }()
+
+	billing := context.WithValue(context.Background(), clientKey{}, "billing")
+	reports := context.WithValue(context.Background(), clientKey{}, "reports")
+
+	reply := &pb.FileReply{Data: []byte("%PDF-1.5"), PageCount: 2}
+	saveArtifact(billing, reply)
+	id := reply.ArtifactId
+	if !artifactIDPattern.MatchString(id) {
+		t.Fatalf("Expected an artifact id, got %q", id)
+	}
+
+	data, pages, err := loadArtifact(billing, id)
+	if err != nil || string(data) != "%PDF-1.5" || pages != 2 {
+		t.Errorf("Expected 2 page artifact, got %q with %d pages, %v", data, pages, err)
+	}
+
+	files := []*pb.File{{Name: "cover.pdf", ArtifactId: id}}
+	if err := fetchInputs(billing, files); err != nil || string(files[0].Data) != "%PDF-1.5" {
+		t.Errorf("Expected artifact input to be resolved, got %q, %v", files[0].Data, err)
+	}
+
+	if _, _, err := loadArtifact(reports, id); errorCode(err) != codes.NotFound {
+		t.Errorf("Expected another client's artifact not to be found, got %v", err)
+	}
+	if err := deleteArtifact(reports, id); errorCode(err) != codes.NotFound {
+		t.Errorf("Expected another client not to delete the artifact, got %v", err)
+	}
+
+	if err := deleteArtifact(billing, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadArtifact(billing, id); errorCode(err) != codes.NotFound {
+		t.Errorf("Expected deleted artifact not to be found, got %v", err)
+	}
+
+	if _, _, err := loadArtifact(billing, "../cache"); errorCode(err) != codes.InvalidArgument {
+		t.Errorf("Expected invalid id to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// anonymousClient identifies callers when authentication isn't required
+const anonymousClient = "anonymous"
+
+// apiKey An entry in the API keys file.  Only the SHA-256 of each key is
+// stored, so the file doesn't hold usable secrets
+type apiKey struct {
+	Client    string `json:"client"`
+	KeySHA256 string `json:"key_sha256"`
+}
+
+// clientKey is the context key for the authenticated client's identity
+type clientKey struct{}
+
+// apiKeys maps the hex SHA-256 of each accepted key to its client.  When
+// empty, API keys aren't required
+var apiKeys map[string]string
+
+// loadAPIKeys reads the keys file at path, which holds a JSON list of apiKey
+func loadAPIKeys(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []apiKey
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	keys := make(map[string]string, len(entries))
+	for i, e := range entries {
+		hash := strings.ToLower(e.KeySHA256)
+		if e.Client == "" {
+			return nil, fmt.Errorf("key %d has no client", i)
+		}
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("key %d for %s is not a hex sha256", i, e.Client)
+		}
+		keys[hash] = e.Client
+	}
+
+	return keys, nil
+}
+
+// serverCredentials returns the TLS credentials for the gRPC listener, or nil
+// if TLS isn't configured.  Setting a client CA requires every client to
+// present a certificate signed by it
+func serverCredentials() (credentials.TransportCredentials, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("client certificates require TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// authenticate identifies the caller of every RPC other than health checks,
+// rejecting it when API keys are required and it doesn't present a known one
+func authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := identify(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// authenticateStream applies authenticate to streaming RPCs, such as reflection
+func authenticateStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	ctx, err := identify(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream A ServerStream whose handler sees ctx in place of the
+// stream's own context, as interceptors of unary RPCs pass on theirs
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// identify returns ctx carrying the caller's identity.  A presented API key
+// takes precedence over a verified client certificate
+func identify(ctx context.Context, method string) (context.Context, error) {
+	client := anonymousClient
+
+	if cn := certificateClient(ctx); cn != "" {
+		client = cn
+	}
+
+	if len(apiKeys) > 0 {
+		key := presentedKey(ctx)
+		if key == "" {
+			authFailures.WithLabelValues("missing_key").Inc()
+			return ctx, status.Error(codes.Unauthenticated, "an api key is required")
+		}
+
+		sum := sha256.Sum256([]byte(key))
+		known, ok := apiKeys[hex.EncodeToString(sum[:])]
+		if !ok {
+			authFailures.WithLabelValues("unknown_key").Inc()
+			log.Warn().Str("method", method).Msg("rejected unknown api key")
+			return ctx, status.Error(codes.Unauthenticated, "unknown api key")
+		}
+		client = known
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("gedoc.client", client))
+	log.Info().Str("client", client).Str("method", method).Msg("request authenticated")
+
+	return context.WithValue(ctx, clientKey{}, client), nil
+}
+
+// presentedKey returns the key sent as a bearer token in the authorization
+// header, or in x-api-key
+func presentedKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	for _, v := range md.Get("authorization") {
+		if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			return strings.TrimSpace(v[7:])
+		}
+	}
+
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return strings.TrimSpace(v[0])
+	}
+
+	return ""
+}
+
+// certificateClient returns the common name of the verified client
+// certificate, if one was presented
+func certificateClient(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// clientFromContext returns the identity of the client making the request
+func clientFromContext(ctx context.Context) string {
+	if client, ok := ctx.Value(clientKey{}).(string); ok {
+		return client
+	}
+	return anonymousClient
+}
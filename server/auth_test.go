@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TestLoadAPIKeys Checks keys are loaded by hash, and bad entries rejected
+func TestLoadAPIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sum := sha256.Sum256([]byte("secret"))
+	path := filepath.Join(dir, "keys.json")
+	valid := `[{"client": "billing", "key_sha256": "` + hex.EncodeToString(sum[:]) + `"}]`
+	if err := ioutil.WriteFile(path, []byte(valid), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadAPIKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[hex.EncodeToString(sum[:])] != "billing" {
+		t.Errorf("Expected key for billing, got %v", keys)
+	}
+
+	for _, invalid := range []string{
+		`[{"client": "billing", "key_sha256": "secret"}]`,
+		`[{"key_sha256": "` + hex.EncodeToString(sum[:]) + `"}]`,
+		`{"client": "billing"}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadAPIKeys(path); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+// TestAuthenticate Checks API keys are required when configured, and that the
+// client identity reaches the handler
+func TestAuthenticate(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	apiKeys = map[string]string{hex.EncodeToString(sum[:]): "billing"}
+	defer func() { apiKeys = nil }()
+
+	var client string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		client = clientFromContext(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/builder.Builder/BuildLatex"}
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		code   codes.Code
+		client string
+	}{
+		{"bearer", metadata.Pairs("authorization", "Bearer secret"), codes.OK, "billing"},
+		{"header", metadata.Pairs("x-api-key", "secret"), codes.OK, "billing"},
+		{"missing", metadata.MD{}, codes.Unauthenticated, ""},
+		{"unknown", metadata.Pairs("authorization", "Bearer guess"), codes.Unauthenticated, ""},
+	}
+
+	for _, test := range tests {
+		client = ""
+		ctx := metadata.NewIncomingContext(context.Background(), test.md)
+		_, err := authenticate(ctx, nil, info, handler)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%s: expected code %s, got %s", test.name, test.code, code)
+		}
+		if client != test.client {
+			t.Errorf("%s: expected client %q, got %q", test.name, test.client, client)
+		}
+	}
+
+	health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := authenticate(context.Background(), nil, health, handler); err != nil {
+		t.Errorf("Expected health checks to need no key, got %v", err)
+	}
+}
+
+// TestCertificateClient Checks a verified client certificate identifies the
+// caller when no API keys are configured
+func TestCertificateClient(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "reports"}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+
+	var client string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		client = clientFromContext(ctx)
+		return nil, nil
+	}
+
+	if _, err := authenticate(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/builder.Builder/Merge"}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if client != "reports" {
+		t.Errorf("Expected client reports, got %q", client)
+	}
+}
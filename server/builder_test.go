@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net"
 	"os"
+	"os/exec"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/episub/gedoc/gedoc/builder"
 	pb "github.com/episub/gedoc/gedoc/lib"
 )
 
@@ -41,6 +46,8 @@ func init() {
 
 // TestBuildLatex Test building pdf from latex
 func TestBuildLatex(t *testing.T) {
+	requireTools(t, "latexmk", "xelatex")
+
 	conn, err := grpc.Dial(testAddress, grpc.WithInsecure())
 	if err != nil {
 		t.Error(err)
@@ -61,7 +68,7 @@ func TestBuildLatex(t *testing.T) {
 	})
 
 	if err != nil {
-		t.Error(fmt.Sprintf("Build failed with error %s and note %s", err.Error(), r.Note))
+		t.Errorf("Build failed with error %s", err.Error())
 		return
 	}
 
@@ -73,10 +80,14 @@ func TestBuildLatex(t *testing.T) {
 	if len(r.Data) < 10000 {
 		t.Errorf("With length of %d, payload is smaller than expected", len(r.Data))
 	}
+
+	checkOutputDetails(t, r)
 }
 
 // TestMergeFiles Send some files, and test merging them
 func TestMergeFiles(t *testing.T) {
+	requireTools(t, "qpdf")
+
 	conn, err := grpc.Dial(testAddress, grpc.WithInsecure())
 	if err != nil {
 		t.Error(err)
@@ -108,6 +119,147 @@ func TestMergeFiles(t *testing.T) {
 	if len(r.Data) < 10000 {
 		t.Errorf("With length of %d, payload is smaller than expected", len(r.Data))
 	}
+
+	checkOutputDetails(t, r)
+}
+
+// TestMergeSkipInvalid Merge with an unsupported file, expecting it to be skipped
+func TestMergeSkipInvalid(t *testing.T) {
+	requireTools(t, "qpdf")
+
+	conn, err := grpc.Dial(testAddress, grpc.WithInsecure())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	c := pb.NewBuilderClient(conn)
+
+	img, err := ioutil.ReadFile("../examples/merge/3.png")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	r, err := c.Merge(context.Background(), &pb.MergeRequest{
+		Files: []*pb.File{
+			{Name: "notes.txt", Data: []byte("not a document")},
+			{Name: "3.png", Data: img},
+		},
+		SkipInvalid: true,
+	})
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !r.Success {
+		t.Errorf("Expected success, but failed with note %s", r.Note)
+		return
+	}
+
+	if len(r.Files) != 2 {
+		t.Errorf("Expected 2 file results, got %d", len(r.Files))
+		return
+	}
+
+	if r.Files[0].Status != pb.FileStatus_SKIPPED || r.Files[0].ErrorCode != pb.FileErrorCode_UNSUPPORTED_TYPE {
+		t.Errorf("Expected first file to be skipped as unsupported, got %v", r.Files[0])
+	}
+
+	if r.Files[1].Status != pb.FileStatus_OK || r.Files[1].PageCount != 1 || r.Files[1].MimeType != "image/png" {
+		t.Errorf("Expected second file to be merged, got %v", r.Files[1])
+	}
+}
+
+// TestRender Render a PDF page to a thumbnail
+func TestRender(t *testing.T) {
+	requireTools(t, "gs", "convert")
+
+	conn, err := grpc.Dial(testAddress, grpc.WithInsecure())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	c := pb.NewBuilderClient(conn)
+
+	img, err := ioutil.ReadFile("../examples/merge/3.png")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pdf, err := builder.New().ImageToPDF(context.Background(), img)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	r, err := c.Render(context.Background(), &pb.RenderRequest{
+		Pdf:     pdf,
+		Options: &pb.RenderOptions{MaxDimension: 200, Format: pb.ImageFormat_JPEG},
+	})
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !r.Success {
+		t.Errorf("Expected success, but failed with note %s", r.Note)
+		return
+	}
+
+	if len(r.Images) != 1 || r.Images[0].Page != 1 || r.Images[0].MimeType != "image/jpeg" {
+		t.Errorf("Expected a single jpeg for page 1, got %v", r.Images)
+	}
+}
+
+// checkOutputDetails checks the details in a successful reply match its data
+func checkOutputDetails(t *testing.T, r *pb.FileReply) {
+	t.Helper()
+
+	sum := sha256.Sum256(r.Data)
+	if r.Sha256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected sha256 of data, got %s", r.Sha256)
+	}
+	if r.SizeBytes != int64(len(r.Data)) {
+		t.Errorf("Expected size %d, got %d", len(r.Data), r.SizeBytes)
+	}
+	if r.PageCount < 1 || r.PdfVersion == "" {
+		t.Errorf("Expected page count and pdf version, got %d and %q", r.PageCount, r.PdfVersion)
+	}
+}
+
+// TestPdfVersion Checks the version is read from the PDF header
+func TestPdfVersion(t *testing.T) {
+	tests := []struct {
+		pdf     string
+		version string
+	}{
+		{"%PDF-1.5\n%\xe2\xe3\xcf\xd3\n", "1.5"},
+		{"\xef\xbb\xbf%PDF-2.0\n", "2.0"},
+		{"not a pdf", ""},
+	}
+
+	for _, test := range tests {
+		if version := pdfVersion([]byte(test.pdf)); version != test.version {
+			t.Errorf("%q: expected version %q, got %q", test.pdf, test.version, version)
+		}
+	}
+}
+
+// requireTools skips the test when any of the external tools it relies on
+// aren't installed
+func requireTools(t *testing.T, tools ...string) {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available: %v", tool, err)
+		}
+	}
 }
 
 // loadFiles loads all files in the listed folder, returning their bytes in an array
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Results of looking up the cache, used as metric labels
+const (
+	cacheHit    = "hit"
+	cacheMiss   = "miss"
+	cacheBypass = "bypass"
+)
+
+// Reasons for evicting a cache entry, used as metric labels
+const (
+	evictSize    = "size"
+	evictExpired = "expired"
+	evictDeleted = "deleted"
+)
+
+// cacheTempPrefix marks entries still being written, which are cleared out
+// when the cache is opened
+const cacheTempPrefix = ".tmp-"
+
+// diskCache A content-addressed store on disk.  The least recently used
+// entries are evicted once it outgrows its size limit, and entries expire once
+// older than its TTL
+type diskCache struct {
+	name     string
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// lru holds a *cacheEntry for each entry, most recently used first
+	lru *list.List
+}
+
+// cacheEntry An entry stored in a diskCache
+type cacheEntry struct {
+	key     string
+	size    int64
+	created time.Time
+}
+
+// latexCache holds compiled PDFs.  It is nil when the build cache is disabled
+var latexCache *diskCache
+
+// openDiskCache opens the cache stored in dir, creating it if needed.  Entries
+// left by a previous run are kept, oldest first in line for eviction
+func openDiskCache(name string, dir string, maxBytes int64, ttl time.Duration) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+
+	c := &diskCache{
+		name:     name,
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), cacheTempPrefix) {
+			os.Remove(filepath.Join(dir, info.Name()))
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		c.entries[info.Name()] = c.lru.PushBack(&cacheEntry{key: info.Name(), size: info.Size(), created: info.ModTime()})
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+
+	log.Info().Str("cache", name).Int("entries", c.lru.Len()).Int64("bytes", c.size).Msg("cache opened")
+
+	return c, nil
+}
+
+// get returns the entry stored under key, if there is one that hasn't expired
+func (c *diskCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e.Value.(*cacheEntry)) {
+		c.removeLocked(e, evictExpired)
+		ok = false
+	}
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	// The entry may be evicted while it's read, which makes it a miss
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// put stores data under key, replacing any existing entry.  Data larger than
+// the whole cache isn't stored
+func (c *diskCache) put(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return nil
+	}
+
+	// Write to a temporary file first, so readers never see part of an entry
+	tmp, err := ioutil.TempFile(c.dir, cacheTempPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size, created: c.now()})
+	c.size += size
+	c.evictLocked()
+
+	return nil
+}
+
+// delete removes the entry stored under key, reporting whether there was one
+func (c *diskCache) delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+
+	c.removeLocked(e, evictDeleted)
+	return true
+}
+
+// evictLocked removes expired entries from the back of the list, then the
+// least recently used entries until the cache is within its size limit
+func (c *diskCache) evictLocked() {
+	for e := c.lru.Back(); e != nil; {
+		prev := e.Prev()
+		if c.expired(e.Value.(*cacheEntry)) {
+			c.removeLocked(e, evictExpired)
+		}
+		e = prev
+	}
+
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back(), evictSize)
+	}
+
+	cacheBytes.WithLabelValues(c.name).Set(float64(c.size))
+}
+
+// removeLocked deletes an entry and its file
+func (c *diskCache) removeLocked(e *list.Element, reason string) {
+	entry := e.Value.(*cacheEntry)
+	c.lru.Remove(e)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+
+	if err := os.Remove(c.path(entry.key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Error().Err(err).Str("cache", c.name).Str("key", entry.key).Msg("removing cache entry")
+	}
+	cacheEvictions.WithLabelValues(c.name, reason).Inc()
+	cacheBytes.WithLabelValues(c.name).Set(float64(c.size))
+}
+
+func (c *diskCache) expired(entry *cacheEntry) bool {
+	return c.ttl > 0 && c.now().Sub(entry.created) > c.ttl
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// toolchain caches the version of the TeX toolchain once it has been found
+var toolchain struct {
+	sync.Mutex
+	version string
+}
+
+// toolchainVersion returns the versions reported by latexmk and the TeX
+// engine, so that upgrading either invalidates cached PDFs
+func toolchainVersion(ctx context.Context) (string, error) {
+	toolchain.Lock()
+	defer toolchain.Unlock()
+
+	if toolchain.version != "" {
+		return toolchain.version, nil
+	}
+
+	var versions []string
+	for _, tool := range [][]string{{"latexmk", "-v"}, {"xelatex", "--version"}} {
+		output, err := newBuilder().ToolOutput(ctx, "", tool[0], tool[1:]...)
+		if err != nil {
+			return "", fmt.Errorf("finding %s version: %w", tool[0], err)
+		}
+		versions = append(versions, firstLine(output))
+	}
+
+	toolchain.version = strings.Join(versions, "\n")
+	return toolchain.version, nil
+}
+
+// firstLine returns the first line of output that isn't blank
+func firstLine(output []byte) string {
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return string(line)
+		}
+	}
+	return ""
+}
+
+// buildCacheKey hashes everything that affects the PDF built from files: the
+// files themselves, ordered by the path they are written to, along with the
+// latexmk settings of the engine in use, toolchain version and any
+// environment that changes the output.  Files sharing a path are rejected, as
+// they would be by the build
+func buildCacheKey(files []*pb.File, toolchain string, env []string) (string, error) {
+	paths, err := builder.LatexPaths(builderFiles(files))
+	if err != nil {
+		return "", err
+	}
+
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return paths[order[i]] < paths[order[j]] })
+
+	h := sha256.New()
+	// Each field is prefixed with its length, so fields can't run together
+	write := func(b []byte) {
+		binary.Write(h, binary.BigEndian, uint64(len(b)))
+		h.Write(b)
+	}
+
+	write(builder.LatexmkSettings(latexEngine()))
+	write([]byte(toolchain))
+	write([]byte(strings.Join(env, "\n")))
+	for _, i := range order {
+		write([]byte(paths[i]))
+		write(files[i].Data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedLatexPDF compiles files like buildLatexPDF, returning the PDF from the
+// build cache instead when it holds one.  It also reports whether the PDF came
+// from the cache.  Only successful builds are cached
+func cachedLatexPDF(ctx context.Context, files []*pb.File, bypass bool) ([]byte, int, bool, error) {
+	if latexCache == nil {
+		pdf, pages, err := buildLatexPDF(ctx, files)
+		return pdf, pages, false, err
+	}
+
+	span := trace.SpanFromContext(ctx)
+
+	version, err := toolchainVersion(ctx)
+	if err != nil {
+		// Without a version, a cached PDF might come from an older toolchain
+		log.Warn().Err(err).Msg("skipping build cache")
+		pdf, pages, err := buildLatexPDF(ctx, files)
+		return pdf, pages, false, err
+	}
+	key, err := buildCacheKey(files, version, builder.ReproducibleEnv(ctx))
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if bypass {
+		cacheRequests.WithLabelValues(latexCache.name, cacheBypass).Inc()
+	} else if entry, ok := latexCache.get(key); ok && len(entry) >= pdfEntryHeader {
+		cacheRequests.WithLabelValues(latexCache.name, cacheHit).Inc()
+		span.SetAttributes(attribute.Bool("gedoc.cache_hit", true))
+		log.Info().Str("key", key).Msg("build cache hit")
+		pdf, pages := decodePDFEntry(entry)
+		return pdf, pages, true, nil
+	} else {
+		cacheRequests.WithLabelValues(latexCache.name, cacheMiss).Inc()
+	}
+	span.SetAttributes(attribute.Bool("gedoc.cache_hit", false))
+
+	pdf, pages, err := buildLatexPDF(ctx, files)
+	if err != nil {
+		return pdf, pages, false, err
+	}
+
+	if err := latexCache.put(key, encodePDFEntry(pdf, pages)); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("storing build in cache")
+	}
+
+	return pdf, pages, false, nil
+}
+
+// pdfEntryHeader is the size of the page count stored ahead of a PDF in a
+// cache entry
+const pdfEntryHeader = 4
+
+// encodePDFEntry returns a cache entry holding pdf and its page count
+func encodePDFEntry(pdf []byte, pages int) []byte {
+	entry := make([]byte, pdfEntryHeader+len(pdf))
+	binary.BigEndian.PutUint32(entry, uint32(pages))
+	copy(entry[pdfEntryHeader:], pdf)
+	return entry
+}
+
+// decodePDFEntry returns the PDF and page count held in a cache entry
+func decodePDFEntry(entry []byte) ([]byte, int) {
+	return entry[pdfEntryHeader:], int(binary.BigEndian.Uint32(entry))
+}
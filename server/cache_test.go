@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/grpc/codes"
+)
+
+// testCache opens a cache in a fresh directory, with a clock the test controls
+func testCache(t *testing.T, maxBytes int64, ttl time.Duration) (*diskCache, *time.Time, func()) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := openDiskCache("test", dir, maxBytes, ttl)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	return c, &now, func() { os.RemoveAll(dir) }
+}
+
+// TestCacheEviction Checks the least recently used entries are evicted once
+// the cache is full
+func TestCacheEviction(t *testing.T) {
+	c, _, cleanup := testCache(t, 10, 0)
+	defer cleanup()
+
+	for _, key := range []string{"a", "b"} {
+		if err := c.put(key, []byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Using a makes b the least recently used
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	if err := c.put("c", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, cached := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.get(key); ok != cached {
+			t.Errorf("Expected %s cached to be %t", key, cached)
+		}
+	}
+	if c.size != 8 {
+		t.Errorf("Expected size 8, got %d", c.size)
+	}
+
+	if err := c.put("big", make([]byte, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("big"); ok {
+		t.Error("Expected an entry larger than the cache not to be stored")
+	}
+}
+
+// TestCacheExpiry Checks entries aren't served once older than the TTL
+func TestCacheExpiry(t *testing.T) {
+	c, now, cleanup := testCache(t, 100, time.Hour)
+	defer cleanup()
+
+	if err := c.put("a", []byte("pdf")); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(59 * time.Minute)
+	if _, ok := c.get("a"); !ok {
+		t.Error("Expected entry within its TTL to be served")
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected expired entry not to be served")
+	}
+	if _, err := os.Stat(c.path("a")); !os.IsNotExist(err) {
+		t.Errorf("Expected expired entry to be removed, got %v", err)
+	}
+}
+
+// TestCacheReopen Checks entries survive the cache being reopened, and
+// partly written entries don't
+func TestCacheReopen(t *testing.T) {
+	c, _, cleanup := testCache(t, 100, 0)
+	defer cleanup()
+
+	if err := c.put("a", []byte("pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.path(cacheTempPrefix+"partial"), []byte("p"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := openDiskCache("test", c.dir, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := reopened.get("a"); !ok || string(data) != "pdf" {
+		t.Errorf("Expected entry to survive reopening, got %q", data)
+	}
+	if reopened.size != 3 {
+		t.Errorf("Expected size 3, got %d", reopened.size)
+	}
+	if _, err := os.Stat(c.path(cacheTempPrefix + "partial")); !os.IsNotExist(err) {
+		t.Errorf("Expected partial entry to be removed, got %v", err)
+	}
+}
+
+// TestBuildCacheKey Checks the key ignores file order, but not file contents,
+// location, the toolchain, the engine or reproducible dates, and that files
+// sharing a path are rejected
+func TestBuildCacheKey(t *testing.T) {
+	cacheKey := func(files []*pb.File, toolchain string, env []string) string {
+		key, err := buildCacheKey(files, toolchain, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	files := []*pb.File{
+		{Name: "main.tex", Data: []byte(`\input{ch1}`)},
+		{Name: "ch1.tex", Data: []byte("one")},
+	}
+	key := cacheKey(files, "v1", nil)
+
+	tests := []struct {
+		name      string
+		files     []*pb.File
+		toolchain string
+		env       []string
+		same      bool
+	}{
+		{"reordered", []*pb.File{files[1], files[0]}, "v1", nil, true},
+		{"contents", []*pb.File{files[0], {Name: "ch1.tex", Data: []byte("two")}}, "v1", nil, false},
+		{"folder", []*pb.File{files[0], {Name: "ch1.tex", Folder: "chapters", Data: []byte("one")}}, "v1", nil, false},
+		{"folder in name", []*pb.File{files[0], {Name: "ch1.tex", Folder: ".", Data: []byte("one")}}, "v1", nil, true},
+		{"split", []*pb.File{files[0], {Name: "ch1.te", Data: []byte("xone")}}, "v1", nil, false},
+		{"toolchain", files, "v2", nil, false},
+		{"reproducible", files, "v1", []string{"SOURCE_DATE_EPOCH=0"}, false},
+	}
+
+	for _, test := range tests {
+		if same := cacheKey(test.files, test.toolchain, test.env) == key; same != test.same {
+			t.Errorf("%s: expected same key to be %t", test.name, test.same)
+		}
+	}
+
+	// Only one of the files sharing a path could be compiled
+	twins := []*pb.File{{Name: "a.tex", Folder: "ch", Data: []byte("one")}, {Name: "a.tex", Folder: "ch/", Data: []byte("two")}}
+	if _, err := buildCacheKey(twins, "v1", nil); errorCode(err) != codes.InvalidArgument {
+		t.Errorf("Expected files sharing a path to be rejected, got %v", err)
+	}
+
+	previous := cfg
+	defer func() { cfg = previous }()
+	cfg.Sandbox = true
+	if cacheKey(files, "v1", nil) == key {
+		t.Errorf("Expected the sandboxed engine to change the key")
+	}
+}
+
+// TestCachedLatexPDF Checks cached builds are returned with their page count
+func TestCachedLatexPDF(t *testing.T) {
+	c, _, cleanup := testCache(t, 1000, 0)
+	defer cleanup()
+
+	latexCache = c
+	toolchain.version = "test"
+	defer func() {
+		latexCache = nil
+		toolchain.version = ""
+	}()
+
+	files := []*pb.File{{Name: "main.tex", Data: []byte("cached")}}
+	entry := append(make([]byte, 4), "%PDF-1.5"...)
+	binary.BigEndian.PutUint32(entry, 3)
+	key, err := buildCacheKey(files, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.put(key, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf, pages, hit, err := cachedLatexPDF(context.Background(), files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit || pages != 3 || !bytes.Equal(pdf, []byte("%PDF-1.5")) {
+		t.Errorf("Expected cached 3 page PDF, got hit %t, %d pages, %q", hit, pages, pdf)
+	}
+}
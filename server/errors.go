@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"github.com/golang/protobuf/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// invalidInput returns an error for a problem with the request
+func invalidInput(format string, args ...interface{}) error {
+	return &builder.Error{Kind: builder.KindInvalidInput, Err: fmt.Errorf(format, args...)}
+}
+
+// recoverPanic returns Internal in place of a panic in a unary handler, so
+// that one request can't stop the server
+func recoverPanic(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (reply interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// recoverStreamPanic returns Internal in place of a panic in a stream handler
+func recoverStreamPanic(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(info.FullMethod, r)
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+// panicError logs the panic r in method, with its stack, and returns the
+// error the client receives
+func panicError(method string, r interface{}) error {
+	log.Error().
+		Str("method", method).
+		Str("panic", fmt.Sprint(r)).
+		Bytes("stack", debug.Stack()).
+		Msg("recovered from panic")
+	return status.Error(codes.Internal, "internal error")
+}
+
+// errorCode returns the gRPC status code for err
+func errorCode(err error) codes.Code {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return codes.DeadlineExceeded
+	}
+	if errors.Is(err, context.Canceled) {
+		return codes.Canceled
+	}
+
+	var be *builder.Error
+	if !errors.As(err, &be) {
+		return codes.Internal
+	}
+
+	switch be.Kind {
+	case builder.KindInvalidInput, builder.KindUnsupportedFile:
+		return codes.InvalidArgument
+	case builder.KindCompile:
+		return codes.FailedPrecondition
+	case builder.KindToolMissing, builder.KindUnavailable:
+		return codes.Unavailable
+	case builder.KindPermissionDenied:
+		return codes.PermissionDenied
+	case builder.KindNotFound:
+		return codes.NotFound
+	case builder.KindTimeout:
+		return codes.DeadlineExceeded
+	case builder.KindResourceExhausted:
+		return codes.ResourceExhausted
+	case builder.KindSandbox:
+		if be.Violation == violationFileAccess {
+			return codes.PermissionDenied
+		}
+		return codes.ResourceExhausted
+	}
+
+	return codes.Internal
+}
+
+// errorStatus converts err to a gRPC status, with structured details where
+// available.  extra details, such as per-file results, are appended
+func errorStatus(err error, extra ...proto.Message) *status.Status {
+	st := status.New(errorCode(err), err.Error())
+
+	var details []proto.Message
+	var be *builder.Error
+	if errors.As(err, &be) {
+		if be.Field != "" {
+			details = append(details, &errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+					Field:       be.Field,
+					Description: be.Err.Error(),
+				}},
+			})
+		}
+
+		if len(be.Diagnostics) > 0 {
+			failure := &errdetails.PreconditionFailure{}
+			for _, d := range be.Diagnostics {
+				failure.Violations = append(failure.Violations, &errdetails.PreconditionFailure_Violation{
+					Type:        "LATEX",
+					Subject:     fmt.Sprintf("%s:%d", d.File, d.Line),
+					Description: d.Message,
+				})
+			}
+			details = append(details, failure)
+		}
+
+		if be.Violation != "" {
+			details = append(details, &errdetails.ErrorInfo{
+				Reason: "SANDBOX_" + strings.ToUpper(be.Violation),
+				Domain: "gedoc",
+			})
+		}
+	}
+	details = append(details, extra...)
+
+	if len(details) == 0 {
+		return st
+	}
+
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		log.Error().Err(detailErr).Msg("adding error details")
+		return st
+	}
+
+	return withDetails
+}
+
+// replyError returns the error an RPC should return for err.  In legacy mode
+// failures are reported only through the success and note reply fields
+func replyError(err error, extra ...proto.Message) error {
+	if err == nil || cfg.LegacyErrors {
+		return nil
+	}
+
+	return errorStatus(err, extra...).Err()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestErrorStatus Checks errors map to the expected status codes and details
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		details int
+	}{
+		{"unclassified", errors.New("failed"), codes.Internal, 0},
+		{"invalid input", invalidInput("must provide one or more files"), codes.InvalidArgument, 0},
+		{"unsupported file", &builder.Error{Kind: builder.KindUnsupportedFile, Err: errors.New("file type unsupported"), Field: "files[2].data"}, codes.InvalidArgument, 1},
+		{"tool missing", fmt.Errorf("wrapped: %w", &builder.Error{Kind: builder.KindToolMissing, Err: exec.ErrNotFound}), codes.Unavailable, 0},
+		{"compile", &builder.Error{Kind: builder.KindCompile, Err: errors.New("failed"), Diagnostics: []builder.Diagnostic{{File: "main.tex", Line: 3, Message: "Undefined control sequence."}}}, codes.FailedPrecondition, 1},
+		{"wrapped compile", fmt.Errorf("wrapped: %w", &builder.Error{Kind: builder.KindCompile, Err: errors.New("failed"), Diagnostics: []builder.Diagnostic{{File: "main.tex", Line: 3, Message: "Undefined control sequence."}}}), codes.FailedPrecondition, 1},
+		{"unwrapped tool missing", &builder.Error{Kind: builder.KindToolMissing, Err: exec.ErrNotFound}, codes.Unavailable, 0},
+		{"timeout", &builder.Error{Kind: builder.KindTimeout, Err: errors.New("timed out")}, codes.DeadlineExceeded, 0},
+	}
+
+	for _, test := range tests {
+		st := errorStatus(test.err)
+		if st.Code() != test.code {
+			t.Errorf("%s: expected code %s, got %s", test.name, test.code, st.Code())
+		}
+		if len(st.Details()) != test.details {
+			t.Errorf("%s: expected %d details, got %d", test.name, test.details, len(st.Details()))
+		}
+	}
+
+	st := errorStatus(&builder.Error{Kind: builder.KindUnsupportedFile, Err: errors.New("damaged"), Field: "files[2].data"})
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || badRequest.FieldViolations[0].Field != "files[2].data" {
+		t.Errorf("Expected bad request details for files[2].data, got %v", st.Details())
+	}
+}
+
+// TestRecoverPanic Checks panicking handlers fail their request with Internal
+func TestRecoverPanic(t *testing.T) {
+	_, err := recoverPanic(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/builder.Builder/Merge"},
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") })
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal from unary handler, got %v", err)
+	}
+
+	err = recoverStreamPanic(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/builder.Builder/Stream"},
+		func(interface{}, grpc.ServerStream) error { panic("boom") })
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal from stream handler, got %v", err)
+	}
+}
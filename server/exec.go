@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// toolRunner Runs tools for the server and the builder, tracing, timing and
+// logging each one.  prepare, if set, adjusts the command before it starts,
+// and may return a function to call once it has exited
+type toolRunner struct {
+	prepare func(*exec.Cmd) func()
+}
+
+func (r toolRunner) Run(ctx context.Context, cmd *exec.Cmd) error {
+	name, args := cmd.Args[0], cmd.Args[1:]
+	ctx, span := startCommandSpan(ctx, name, args)
+	if r.prepare != nil {
+		if cleanup := r.prepare(cmd); cleanup != nil {
+			defer cleanup()
+		}
+	}
+	// Standard error is also kept apart, so that its tail can be traced
+	var stderr bytes.Buffer
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	} else {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	}
+	start := time.Now()
+	err := cmd.Run()
+	observeTool(name, start, err)
+	chargeTexCPU(ctx, name, cmd.ProcessState)
+	endCommandSpan(span, start, stderr.Bytes(), err)
+	log.Info().
+		Str("cmd", commandLine(name, args)).
+		Str("stderr", stderr.String()).
+		Msgf("ran %s", name)
+
+	return err
+}
+
+// commandLine returns the command for logging, with any secrets redacted
+func commandLine(name string, args []string) string {
+	return strings.Join(append([]string{name}, redactArgs(args)...), " ")
+}
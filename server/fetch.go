@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// inputFetcher Fetches inputs referenced by URI, for one or more schemes.
+// Fetchers only fetch what their allowlist permits, so that clients can't use
+// the server to reach anything else
+type inputFetcher interface {
+	// fetch returns the contents of u, failing once more than maxBytes have
+	// been read
+	fetch(ctx context.Context, u *url.URL, maxBytes int64) ([]byte, error)
+}
+
+// inputFetchers holds the fetcher for each scheme that is enabled
+var inputFetchers = map[string]inputFetcher{}
+
+// errTooLarge is returned by fetchers when an input is over its size limit
+var errTooLarge = errors.New("input is larger than its size limit")
+
+// configureFetchers sets up fetching for each scheme with an allowlist in the
+// config.  s3 is nil when no S3 endpoint is configured
+func configureFetchers(s3 *minio.Client) {
+	if len(cfg.FetchFileRoots) > 0 {
+		inputFetchers["file"] = &fileFetcher{roots: cfg.FetchFileRoots}
+	}
+
+	if len(cfg.FetchS3Buckets) > 0 {
+		if s3 == nil {
+			log.Warn().Msg("FETCH_S3_BUCKETS is set without S3_ENDPOINT, so s3 inputs are refused")
+		} else {
+			inputFetchers["s3"] = &s3Fetcher{client: s3, buckets: allowlist(cfg.FetchS3Buckets)}
+		}
+	}
+
+	if len(cfg.FetchHTTPHosts) > 0 {
+		f := newHTTPFetcher(allowlist(cfg.FetchHTTPHosts))
+		inputFetchers["http"] = f
+		inputFetchers["https"] = f
+	}
+}
+
+// allowlist returns a set of the non-empty entries
+func allowlist(entries []string) map[string]bool {
+	allowed := map[string]bool{}
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e != "" {
+			allowed[e] = true
+		}
+	}
+	return allowed
+}
+
+// fetchInputs replaces the data of files that reference a source URI or an
+// artifact with their contents, checking each against its expected checksum
+func fetchInputs(ctx context.Context, files []*pb.File) error {
+	for i, f := range files {
+		var data []byte
+		var err error
+		field := "source_uri"
+
+		switch {
+		case f.SourceUri == "" && f.ArtifactId == "":
+			continue
+		case len(f.Data) > 0 || (f.SourceUri != "" && f.ArtifactId != ""):
+			err = invalidInput("%s must have only one of data, a source uri and an artifact id", f.Name)
+		case f.ArtifactId != "":
+			field = "artifact_id"
+			data, _, err = loadArtifact(ctx, f.ArtifactId)
+		default:
+			data, err = fetchInput(ctx, f)
+			chargeInputBytes(ctx, int64(len(data)))
+		}
+
+		if err == nil && f.Sha256 != "" {
+			sum := sha256.Sum256(data)
+			if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, f.Sha256) {
+				err = invalidInput("%s has sha256 %s, expected %s", f.Name, actual, f.Sha256)
+			}
+		}
+
+		if err != nil {
+			var be *builder.Error
+			if !errors.As(err, &be) {
+				be = &builder.Error{Kind: builder.KindUnavailable, Err: err}
+				err = be
+			}
+			be.Field = fmt.Sprintf("files[%d].%s", i, field)
+			return err
+		}
+
+		f.Data = data
+	}
+
+	return nil
+}
+
+// fetchFile fetches a single file like fetchInputs, for requests that name it
+// field rather than files[n]
+func fetchFile(ctx context.Context, f *pb.File, field string) error {
+	if f == nil {
+		return nil
+	}
+
+	err := fetchInputs(ctx, []*pb.File{f})
+	var be *builder.Error
+	if errors.As(err, &be) {
+		be.Field = field + strings.TrimPrefix(be.Field, "files[0]")
+	}
+	return err
+}
+
+// fetchInput fetches the source of a single file
+func fetchInput(ctx context.Context, f *pb.File) ([]byte, error) {
+	u, err := url.Parse(f.SourceUri)
+	if err != nil {
+		return nil, invalidInput("invalid source uri for %s: %v", f.Name, err)
+	}
+
+	fetcher, ok := inputFetchers[u.Scheme]
+	if !ok {
+		return nil, &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("fetching %s uris is not allowed", u.Scheme)}
+	}
+
+	maxBytes := cfg.FetchMaxBytes
+	if f.MaxBytes > 0 && f.MaxBytes < maxBytes {
+		maxBytes = f.MaxBytes
+	}
+
+	ctx, span := tracer.Start(ctx, "fetchInput")
+	defer span.End()
+	span.SetAttributes(attribute.String("gedoc.source_uri", redactURI(u)))
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.FetchTimeout)
+	defer cancel()
+
+	started := time.Now()
+	data, err := fetcher.fetch(ctx, u, maxBytes)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	fetchDuration.WithLabelValues(u.Scheme, outcome).Observe(time.Since(started).Seconds())
+
+	switch {
+	case errors.Is(err, errTooLarge):
+		return nil, invalidInput("%s is larger than %d bytes", redactURI(u), maxBytes)
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, &builder.Error{Kind: builder.KindTimeout, Err: fmt.Errorf("fetching %s timed out: %w", redactURI(u), ctx.Err())}
+	case err != nil:
+		return nil, err
+	}
+
+	log.Info().Str("source_uri", redactURI(u)).Int("bytes", len(data)).Msg("fetched input")
+
+	return data, nil
+}
+
+// redactURI returns u for logging, without credentials or a query string that
+// may hold a signature
+func redactURI(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	return redacted.String()
+}
+
+// readLimited reads r, failing with errTooLarge beyond maxBytes
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errTooLarge
+	}
+	return data, nil
+}
+
+// fileFetcher Reads inputs beneath allowed directories, such as a shared mount
+type fileFetcher struct {
+	roots []string
+}
+
+func (f *fileFetcher) fetch(_ context.Context, u *url.URL, maxBytes int64) ([]byte, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("file uris must be local, got host %s", u.Host)}
+	}
+
+	// Symlinks are resolved first, so that they can't lead outside the roots
+	path, err := filepath.EvalSymlinks(filepath.Clean(u.Path))
+	if err != nil {
+		return nil, invalidInput("source %s can't be read: %v", u.Path, err)
+	}
+	if !f.allowed(path) {
+		return nil, &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("reading %s is not allowed", u.Path)}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, invalidInput("source %s can't be read: %v", u.Path, err)
+	}
+	defer file.Close()
+
+	return readLimited(file, maxBytes)
+}
+
+// allowed reports whether path lies beneath one of the roots
+func (f *fileFetcher) allowed(path string) bool {
+	for _, root := range f.roots {
+		root, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+// s3Fetcher Downloads inputs from allowed buckets on the configured endpoint
+type s3Fetcher struct {
+	client  *minio.Client
+	buckets map[string]bool
+}
+
+func (f *s3Fetcher) fetch(ctx context.Context, u *url.URL, maxBytes int64) ([]byte, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if !f.buckets[bucket] {
+		return nil, &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("fetching from bucket %s is not allowed", bucket)}
+	}
+
+	object, err := f.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	info, err := object.Stat()
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, invalidInput("source %s not found", redactURI(u))
+		}
+		return nil, err
+	}
+	if info.Size > maxBytes {
+		return nil, errTooLarge
+	}
+
+	return readLimited(object, maxBytes)
+}
+
+// httpFetcher Downloads inputs from allowed hosts, including when redirected
+type httpFetcher struct {
+	hosts  map[string]bool
+	client *http.Client
+}
+
+func newHTTPFetcher(hosts map[string]bool) *httpFetcher {
+	f := &httpFetcher{hosts: hosts}
+	f.client = &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !f.allowed(req.URL) {
+				return &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("redirect to %s is not allowed", req.URL.Host)}
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// allowed reports whether u's host is allowlisted, with or without its port
+func (f *httpFetcher) allowed(u *url.URL) bool {
+	return f.hosts[u.Host] || f.hosts[u.Hostname()]
+}
+
+func (f *httpFetcher) fetch(ctx context.Context, u *url.URL, maxBytes int64) ([]byte, error) {
+	if !f.allowed(u) {
+		return nil, &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("fetching from %s is not allowed", u.Host)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, invalidInput("invalid source uri: %v", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Refused redirects are reported as they are, not as fetch failures
+		var be *builder.Error
+		if errors.As(err, &be) {
+			return nil, be
+		}
+		return nil, fmt.Errorf("fetching %s: %w", redactURI(u), err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, invalidInput("source %s not found", redactURI(u))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetching %s: %s", redactURI(u), resp.Status)
+	case resp.ContentLength > maxBytes:
+		return nil, errTooLarge
+	}
+
+	return readLimited(resp.Body, maxBytes)
+}
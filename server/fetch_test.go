@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestFileFetcher Checks only files beneath the roots can be read, even
+// through symlinks
+func TestFileFetcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "shared")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"shared/in.pdf": "%PDF-1.5", "secret": "key"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fileFetcher{roots: []string{root}}
+
+	tests := []struct {
+		path     string
+		maxBytes int64
+		code     codes.Code
+	}{
+		{"shared/in.pdf", 100, codes.OK},
+		{"shared/in.pdf", 4, codes.InvalidArgument},
+		{"secret", 100, codes.PermissionDenied},
+		{"shared/../secret", 100, codes.PermissionDenied},
+		{"shared/link", 100, codes.PermissionDenied},
+		{"shared/missing.pdf", 100, codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		u := &url.URL{Scheme: "file", Path: filepath.Join(dir, test.path)}
+		data, err := f.fetch(context.Background(), u, test.maxBytes)
+		if errors.Is(err, errTooLarge) {
+			err = invalidInput("too large")
+		}
+		if code := codeOf(err); code != test.code {
+			t.Errorf("%s: expected %s, got %v", test.path, test.code, err)
+		}
+		if test.code == codes.OK && string(data) != "%PDF-1.5" {
+			t.Errorf("%s: expected contents, got %q", test.path, data)
+		}
+	}
+}
+
+// TestHTTPFetcher Checks only allowlisted hosts are fetched from, including
+// after redirects
+func TestHTTPFetcher(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer elsewhere.Close()
+
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/in.pdf":
+			w.Write([]byte("%PDF-1.5"))
+		case "/redirect":
+			http.Redirect(w, r, elsewhere.URL+"/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer allowed.Close()
+
+	host, _ := url.Parse(allowed.URL)
+	f := newHTTPFetcher(map[string]bool{host.Host: true})
+
+	tests := []struct {
+		uri  string
+		code codes.Code
+	}{
+		{allowed.URL + "/in.pdf", codes.OK},
+		{allowed.URL + "/missing.pdf", codes.InvalidArgument},
+		{allowed.URL + "/redirect", codes.PermissionDenied},
+		{elsewhere.URL + "/", codes.PermissionDenied},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.uri)
+		data, err := f.fetch(context.Background(), u, 100)
+		if code := codeOf(err); code != test.code {
+			t.Errorf("%s: expected %s, got %v", test.uri, test.code, err)
+		}
+		if test.code == codes.OK && string(data) != "%PDF-1.5" {
+			t.Errorf("%s: expected contents, got %q", test.uri, data)
+		}
+	}
+
+	u, _ := url.Parse(allowed.URL + "/in.pdf")
+	if _, err := f.fetch(context.Background(), u, 4); !errors.Is(err, errTooLarge) {
+		t.Errorf("Expected size limit to apply, got %v", err)
+	}
+}
+
+// TestFetchInputs Checks fetched files are checked against their checksum,
+// and failures identify the file
+func TestFetchInputs(t *testing.T) {
+	previous := cfg
+	cfg.FetchMaxBytes = 1024
+	cfg.FetchTimeout = time.Second
+	defer func() { cfg = previous }()
+
+	root, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "in.pdf")
+	if err := ioutil.WriteFile(path, []byte("%PDF-1.5"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inputFetchers["file"] = &fileFetcher{roots: []string{root}}
+	defer delete(inputFetchers, "file")
+
+	sum := sha256.Sum256([]byte("%PDF-1.5"))
+	uri := "file://" + path
+
+	tests := []struct {
+		name string
+		file *pb.File
+		code codes.Code
+	}{
+		{"checksum", &pb.File{Name: "a.pdf", SourceUri: uri, Sha256: hex.EncodeToString(sum[:])}, codes.OK},
+		{"mismatch", &pb.File{Name: "a.pdf", SourceUri: uri, Sha256: "00"}, codes.InvalidArgument},
+		{"both", &pb.File{Name: "a.pdf", SourceUri: uri, Data: []byte("x")}, codes.InvalidArgument},
+		{"scheme", &pb.File{Name: "a.pdf", SourceUri: "s3://bucket/a.pdf"}, codes.PermissionDenied},
+	}
+
+	for _, test := range tests {
+		files := []*pb.File{{Name: "main.tex", Data: []byte("inline")}, test.file}
+		err := fetchInputs(context.Background(), files)
+		if code := codeOf(err); code != test.code {
+			t.Errorf("%s: expected %s, got %v", test.name, test.code, err)
+			continue
+		}
+
+		if err == nil {
+			if string(test.file.Data) != "%PDF-1.5" {
+				t.Errorf("%s: expected fetched data, got %q", test.name, test.file.Data)
+			}
+			continue
+		}
+
+		var be *builder.Error
+		if !errors.As(err, &be) || be.Field != "files[1].source_uri" {
+			t.Errorf("%s: expected error for files[1].source_uri, got %v", test.name, err)
+		}
+	}
+}
+
+// TestFetchFile Checks ExtractText and Render fetch their file, reporting
+// failures against the file field
+func TestFetchFile(t *testing.T) {
+	previous := cfg
+	cfg.LegacyErrors = false
+	defer func() { cfg = previous }()
+
+	file := func() *pb.File {
+		return &pb.File{Name: "a.pdf", SourceUri: "s3://bucket/a.pdf"}
+	}
+	s := &server{}
+
+	_, textErr := s.ExtractText(context.Background(), &pb.ExtractTextRequest{File: file()})
+	_, renderErr := s.Render(context.Background(), &pb.RenderRequest{File: file()})
+
+	for name, err := range map[string]error{"ExtractText": textErr, "Render": renderErr} {
+		st, _ := status.FromError(err)
+		if st.Code() != codes.PermissionDenied {
+			t.Errorf("%s: expected PermissionDenied, got %v", name, err)
+			continue
+		}
+		if len(st.Details()) == 0 {
+			t.Errorf("%s: expected a BadRequest detail", name)
+			continue
+		}
+		badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+		if !ok || badRequest.FieldViolations[0].Field != "file.source_uri" {
+			t.Errorf("%s: expected violation on file.source_uri, got %v", name, st.Details())
+		}
+	}
+
+	_, err := s.Render(context.Background(), &pb.RenderRequest{File: file(), Pdf: []byte("%PDF-1.5")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected both pdf and file to be rejected, got %v", err)
+	}
+}
+
+// codeOf returns the status code for err, which is OK when there is none
+func codeOf(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	return errorCode(err)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// builderService is the fully qualified name of the Builder gRPC service
+const builderService = "builder.Builder"
+
+// toolCheckTimeout limits how long a tool may take to report its version
+const toolCheckTimeout = 10 * time.Second
+
+// requiredTools are the external tools every build or merge may need, along
+// with arguments that make them run without doing any work
+var requiredTools = map[string][]string{
+	"latexmk": {"-v"},
+	"xelatex": {"--version"},
+	"qpdf":    {"--version"},
+	"convert": {"-version"},
+}
+
+// readinessCheck A named check that must pass for the service to be ready
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// readinessState The outcome of the most recent readiness checks
+type readinessState struct {
+	sync.RWMutex
+	ready   bool
+	results map[string]string
+	checked time.Time
+}
+
+var (
+	readiness = &readinessState{}
+	// live is set once the gRPC server is accepting connections
+	live int32
+	// jobsInFlight counts requests currently being worked on
+	jobsInFlight int64
+	// readinessRecheck triggers the readiness checks outside of their interval
+	readinessRecheck = make(chan struct{}, 1)
+)
+
+// isReady reports whether the last readiness checks all passed
+func (r *readinessState) isReady() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.ready
+}
+
+// snapshot returns a copy of the last check results
+func (r *readinessState) snapshot() (bool, map[string]string, time.Time) {
+	r.RLock()
+	defer r.RUnlock()
+
+	results := make(map[string]string, len(r.results))
+	for k, v := range r.results {
+		results[k] = v
+	}
+	return r.ready, results, r.checked
+}
+
+// readinessChecks returns every check that must pass for the service to be
+// ready to accept work
+func readinessChecks() []readinessCheck {
+	var checks []readinessCheck
+
+	var tools []string
+	for tool := range requiredTools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		tool := tool
+		checks = append(checks, readinessCheck{"tool_" + tool, func(ctx context.Context) error {
+			return checkTool(ctx, tool, requiredTools[tool]...)
+		}})
+	}
+
+	checks = append(checks,
+		readinessCheck{"temp_dir", checkTempDir},
+		readinessCheck{"jobs", checkJobs},
+		readinessCheck{"queues", checkQueues},
+		readinessCheck{"sandbox", checkSandbox},
+		readinessCheck{"self_test", checkSelfTest},
+	)
+
+	return checks
+}
+
+// checkTool ensures tool is on the PATH and can be run
+func checkTool(ctx context.Context, tool string, args ...string) error {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolCheckTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// checkTempDir ensures files can be written to the temp directory, which
+// every build and merge relies on
+func checkTempDir(_ context.Context) error {
+	f, err := ioutil.TempFile("", "healthcheck")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write([]byte("ok"))
+	closeErr := f.Close()
+	removeErr := os.Remove(f.Name())
+
+	for _, e := range []error{err, closeErr, removeErr} {
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// checkJobs fails when the server is already working on as many requests as
+// it has been configured to handle
+func checkJobs(_ context.Context) error {
+	if cfg.MaxInFlight <= 0 {
+		return nil
+	}
+
+	if n := atomic.LoadInt64(&jobsInFlight); n >= int64(cfg.MaxInFlight) {
+		return fmt.Errorf("saturated with %d jobs in flight", n)
+	}
+
+	return nil
+}
+
+// checkQueues fails while any pool's queue is full, as new work would only be
+// rejected
+func checkQueues(_ context.Context) error {
+	if full := sched.saturated(); len(full) > 0 {
+		sort.Strings(full)
+		return fmt.Errorf("queues full: %s", strings.Join(full, ", "))
+	}
+
+	return nil
+}
+
+// runReadinessChecks runs every check once, and records the outcome in
+// readiness and the gRPC health server
+func runReadinessChecks(ctx context.Context, hs *health.Server) {
+	ready := true
+	results := make(map[string]string)
+
+	for _, c := range readinessChecks() {
+		err := c.check(ctx)
+		if err != nil {
+			ready = false
+			results[c.name] = err.Error()
+			log.Warn().Err(err).Str("check", c.name).Msg("readiness check failed")
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	readiness.Lock()
+	changed := readiness.ready != ready || readiness.checked.IsZero()
+	readiness.ready = ready
+	readiness.results = results
+	readiness.checked = time.Now()
+	readiness.Unlock()
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if ready {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	hs.SetServingStatus("", status)
+	hs.SetServingStatus(builderService, status)
+
+	if changed {
+		log.Info().Bool("ready", ready).Msg("readiness changed")
+	}
+}
+
+// watchReadiness reruns the readiness checks on an interval, until ctx is done
+func watchReadiness(ctx context.Context, hs *health.Server) {
+	ticker := time.NewTicker(cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		runReadinessChecks(ctx, hs)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-readinessRecheck:
+		}
+	}
+}
+
+// requestReadinessCheck asks for the readiness checks to be rerun now, such as
+// after something they depend on has changed
+func requestReadinessCheck() {
+	select {
+	case readinessRecheck <- struct{}{}:
+	default:
+		// A recheck is already pending
+	}
+}
+
+// newHealthServer creates the standard gRPC health service, reporting every
+// service as not serving until the readiness checks have passed
+func newHealthServer() *health.Server {
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus(builderService, healthpb.HealthCheckResponse_NOT_SERVING)
+	return hs
+}
+
+// countJobs tracks how many requests are in flight.  Health checks aren't
+// counted, so that they're answered even when the server is busy
+func countJobs(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	atomic.AddInt64(&jobsInFlight, 1)
+	defer atomic.AddInt64(&jobsInFlight, -1)
+	jobsInFlightGauge.Inc()
+	defer jobsInFlightGauge.Dec()
+
+	return handler(ctx, req)
+}
+
+func isHealthMethod(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.") || method == "/"+builderService+"/Health"
+}
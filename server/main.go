@@ -1,29 +1,29 @@
 package main
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net"
 	"os"
-	"os/exec"
 	"os/signal"
-	"strconv"
-	"strings"
+	"regexp"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	"github.com/episub/gedoc/gedoc/builder"
 	pb "github.com/episub/gedoc/gedoc/lib"
-	"github.com/gofrs/uuid"
-	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	grpcOpentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/h2non/filetype"
-	"github.com/opentracing/opentracing-go"
+	"github.com/golang/protobuf/proto"
+	"github.com/minio/minio-go/v7"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -31,10 +31,107 @@ type config struct {
 	ExternalPort int    `env:"PORT" envDefault:"50051"`
 	InternalPort int    `env:"INTERNAL_PORT" envDefault:"50052"`
 	Debug        bool   `env:"DEBUG" envDefault:"false"`
-	DebugSpans   bool   `env:"DEBUG_SPANS" envDefault:"false"`
 	ServiceName  string `env:"SERVICE_NAME" envDefault:"gedoc"`
-	PdfBlankPath string `env:"PDF_BLANK_PATH" envDefault:"/gedoc/blank.pdf"`
 	HumanLogs    bool   `env:"HUMAN" envDefault:"false"`
+	NativeImages bool   `env:"NATIVE_IMAGES" envDefault:"true"`
+	// LegacyErrors reports failures only through the success and note reply
+	// fields, returning a nil error, for clients that predate status codes
+	LegacyErrors bool `env:"LEGACY_ERRORS" envDefault:"false"`
+	// HealthInterval is how often readiness checks are run
+	HealthInterval time.Duration `env:"HEALTH_CHECK_INTERVAL" envDefault:"30s"`
+	// MaxInFlight marks the server as not ready while it is working on this many
+	// requests.  Zero disables the limit
+	MaxInFlight int `env:"MAX_IN_FLIGHT" envDefault:"0"`
+	// SelfTest runs a canary build and merge at startup, and holds off reporting
+	// ready until it passes
+	SelfTest bool `env:"SELF_TEST" envDefault:"false"`
+	// TraceExporter selects where spans are sent: otlp, stdout or none
+	TraceExporter string `env:"TRACE_EXPORTER" envDefault:"otlp"`
+	// LatexConcurrency, ImageConcurrency, MergeConcurrency, RenderConcurrency
+	// and OCRConcurrency limit how many of each kind of job run at once.  Zero
+	// uses the number of CPUs
+	LatexConcurrency  int `env:"LATEX_CONCURRENCY" envDefault:"0"`
+	ImageConcurrency  int `env:"IMAGE_CONCURRENCY" envDefault:"0"`
+	MergeConcurrency  int `env:"MERGE_CONCURRENCY" envDefault:"0"`
+	RenderConcurrency int `env:"RENDER_CONCURRENCY" envDefault:"0"`
+	OCRConcurrency    int `env:"OCR_CONCURRENCY" envDefault:"0"`
+	// MergeParallelism limits how many files of a single merge are prepared at
+	// once.  Zero uses the number of CPUs
+	MergeParallelism int `env:"MERGE_PARALLELISM" envDefault:"0"`
+	// QueueSize is how many jobs of each kind may wait for a slot before
+	// further requests are rejected
+	QueueSize int `env:"QUEUE_SIZE" envDefault:"100"`
+	// QueueMaxWait is how long a job may wait for a slot before being rejected
+	QueueMaxWait time.Duration `env:"QUEUE_MAX_WAIT" envDefault:"1m"`
+	// TLSCertFile and TLSKeyFile enable TLS on the gRPC listener
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+	// TLSClientCAFile requires clients to present a certificate signed by this
+	// CA, whose common name identifies them
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+	// APIKeysFile lists the accepted API keys and the client each identifies.
+	// When set, every RPC other than health checks needs a key
+	APIKeysFile string `env:"API_KEYS_FILE"`
+	// QuotasFile sets rate limits and daily quotas for each client
+	QuotasFile string `env:"QUOTAS_FILE"`
+	// Sandbox compiles LaTeX in fresh namespaces with paranoid file access and
+	// the resource limits below.  Zero leaves a limit unset
+	Sandbox              bool   `env:"SANDBOX" envDefault:"false"`
+	SandboxCPUSeconds    uint64 `env:"SANDBOX_CPU_SECONDS" envDefault:"120"`
+	SandboxMemoryBytes   uint64 `env:"SANDBOX_MEMORY_BYTES" envDefault:"2147483648"`
+	SandboxFileSizeBytes uint64 `env:"SANDBOX_FILE_SIZE_BYTES" envDefault:"268435456"`
+	SandboxProcesses     uint64 `env:"SANDBOX_PROCESSES" envDefault:"64"`
+	// SandboxCgroup is a cgroup v2 directory delegated to the server, with the
+	// pids controller enabled for its children.  Each sandboxed tool gets a
+	// cgroup beneath it, which limits it to SandboxProcesses processes
+	SandboxCgroup string `env:"SANDBOX_CGROUP"`
+	// SandboxReadOnlyPaths are the parts of the filesystem sandboxed tools can
+	// read, besides their working directory.  Paths that don't exist are
+	// skipped
+	SandboxReadOnlyPaths []string `env:"SANDBOX_READ_ONLY_PATHS" envSeparator:"," envDefault:"/usr,/bin,/sbin,/lib,/lib64,/etc,/opt,/var/lib/texmf,/var/cache/fontconfig"`
+	// BuildCacheDir enables caching compiled PDFs on disk, keyed by a hash of
+	// their inputs, the latexmk settings and the toolchain version
+	BuildCacheDir string `env:"BUILD_CACHE_DIR"`
+	// BuildCacheMaxBytes limits the size of the build cache, beyond which the
+	// least recently used PDFs are evicted
+	BuildCacheMaxBytes int64 `env:"BUILD_CACHE_MAX_BYTES" envDefault:"1073741824"`
+	// BuildCacheTTL is how long a cached PDF is served for.  Zero keeps PDFs
+	// until they are evicted
+	BuildCacheTTL time.Duration `env:"BUILD_CACHE_TTL" envDefault:"24h"`
+	// ReproducibleEpoch is the creation date of reproducible builds that don't
+	// supply their own, in seconds since the Unix epoch
+	ReproducibleEpoch int64 `env:"REPRODUCIBLE_SOURCE_DATE_EPOCH" envDefault:"0"`
+	// ArtifactDir enables keeping every PDF returned on disk, for later
+	// requests to use by ID until it expires after ArtifactTTL.  The least
+	// recently used are evicted beyond ArtifactMaxBytes
+	ArtifactDir      string        `env:"ARTIFACT_DIR"`
+	ArtifactMaxBytes int64         `env:"ARTIFACT_MAX_BYTES" envDefault:"1073741824"`
+	ArtifactTTL      time.Duration `env:"ARTIFACT_TTL" envDefault:"1h"`
+	// OutputDir enables storing PDFs on the filesystem, beneath this directory
+	OutputDir string `env:"OUTPUT_DIR"`
+	// S3Endpoint enables storing PDFs in S3-compatible buckets, using the
+	// credentials below
+	S3Endpoint        string `env:"S3_ENDPOINT"`
+	S3Region          string `env:"S3_REGION" envDefault:"us-east-1"`
+	S3AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+	S3UseSSL          bool   `env:"S3_USE_SSL" envDefault:"true"`
+	// OutputS3Buckets are the buckets PDFs may be stored in.  S3 output is
+	// refused while it is empty
+	OutputS3Buckets []string `env:"OUTPUT_S3_BUCKETS" envSeparator:","`
+	// FetchFileRoots, FetchS3Buckets and FetchHTTPHosts allow inputs to be
+	// fetched by source_uri from beneath these directories, from these buckets
+	// on S3_ENDPOINT, and from these hosts.  A scheme is refused while its list
+	// is empty
+	FetchFileRoots []string `env:"FETCH_FILE_ROOTS" envSeparator:","`
+	FetchS3Buckets []string `env:"FETCH_S3_BUCKETS" envSeparator:","`
+	FetchHTTPHosts []string `env:"FETCH_HTTP_HOSTS" envSeparator:","`
+	// FetchTimeout limits how long fetching each input may take
+	FetchTimeout time.Duration `env:"FETCH_TIMEOUT" envDefault:"30s"`
+	// FetchMaxBytes is the largest input that may be fetched
+	FetchMaxBytes int64 `env:"FETCH_MAX_BYTES" envDefault:"104857600"`
+	// PipelineMaxSteps is the most steps a single pipeline may have
+	PipelineMaxSteps int `env:"PIPELINE_MAX_STEPS" envDefault:"32"`
 }
 
 var cfg config
@@ -43,399 +140,656 @@ type server struct{}
 
 // BuildLatex Implements BuildLatex, taking some files and returning a PDF
 func (s *server) BuildLatex(ctx context.Context, in *pb.BuildLatexRequest) (*pb.FileReply, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "BuildLatex")
-	defer span.Finish()
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, "BuildLatex")
+	defer span.End()
+	ctx = withReproducible(ctx, in.Reproducible)
 
-	final, err := buildLatexPDF(opentracing.ContextWithSpan(ctx, span), in.Files)
+	var final []byte
+	var pages int
+	var cacheHit bool
+
+	err := checkOutputTarget(ctx, in.Output)
+	if err == nil {
+		err = fetchInputs(ctx, in.Files)
+	}
+
+	for _, f := range in.Files {
+		observeInput(opBuildLatex, f.Data)
+	}
+
+	if err == nil {
+		final, pages, cacheHit, err = cachedLatexPDF(ctx, in.Files, in.BypassCache)
+	}
+
+	var optimization *pb.Optimization
+	if err == nil {
+		final, optimization, err = optimizePDF(ctx, final, in.Optimize)
+	}
+
+	if err == nil {
+		observeOutput(opBuildLatex, final, pages)
+		chargeOutputPages(ctx, pages)
+	}
 
 	note := "build successful"
 
 	if err != nil {
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("build failed")
 		note = err.Error()
 	}
 
 	reply := &pb.FileReply{
-		Data:    final,
-		Success: err == nil,
-		Note:    note,
+		Data:         final,
+		Success:      err == nil,
+		Note:         note,
+		Optimization: optimization,
+		CacheHit:     cacheHit,
+	}
+	if err == nil {
+		describeOutput(reply, pages, started)
+		saveArtifact(ctx, reply)
 	}
 
-	return reply, nil
+	attachPreview(ctx, reply, in.Preview)
+
+	if err == nil {
+		err = storeOutput(ctx, reply, in.Output)
+		if err != nil {
+			log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("storing build failed")
+			reply.Success = false
+			reply.Note = err.Error()
+		}
+	}
+
+	return reply, replyError(err)
 }
 
 // Merge Merges the provided files into a single PDF
 func (s *server) Merge(ctx context.Context, in *pb.MergeRequest) (*pb.FileReply, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "MergePDF")
-	defer span.Finish()
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, "MergePDF")
+	defer span.End()
+	ctx = withReproducible(ctx, in.Reproducible)
+
+	result := &builder.MergeResult{}
+	err := checkOutputTarget(ctx, in.Output)
+	if err == nil {
+		err = fetchInputs(ctx, in.Files)
+	}
 
-	final, err := mergeFiles(opentracing.ContextWithSpan(ctx, span), in.Files, in.ForceEven)
+	for _, f := range in.Files {
+		observeInput(opMerge, f.Data)
+	}
+
+	if err == nil {
+		result, err = newBuilder().Merge(ctx, builderFiles(in.Files), builder.MergeOptions{
+			ForceEven:   in.ForceEven,
+			OCR:         in.Ocr.GetEnabled(),
+			OCRLanguage: in.Ocr.GetLanguage(),
+			Sanitize:    in.Sanitize,
+			Strict:      in.Strict,
+			SkipInvalid: in.SkipInvalid,
+		})
+	}
+	final := result.Data
+
+	var optimization *pb.Optimization
+	if err == nil {
+		final, optimization, err = optimizePDF(ctx, final, in.Optimize)
+	}
+
+	if err == nil {
+		observeOutput(opMerge, final, result.Pages)
+		chargeOutputPages(ctx, result.Pages)
+	}
 
+	files, preflight := fileResults(result.Files)
 	note := "merge successful"
 
 	if err != nil {
-		log.Error().Err(err).Msg("merge failed")
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("merge failed")
 		note = err.Error()
 	}
 
 	reply := &pb.FileReply{
-		Data:    final,
-		Success: err == nil,
-		Note:    note,
+		Data:         final,
+		Success:      err == nil,
+		Note:         note,
+		Optimization: optimization,
+		Preflight:    preflight,
+		Files:        files,
+	}
+	if err == nil {
+		describeOutput(reply, result.Pages, started)
+		saveArtifact(ctx, reply)
 	}
 
-	return reply, nil
-}
+	attachPreview(ctx, reply, in.Preview)
 
-// Health Implements health, and simply returns true for now.  If server is unreachable, no reply will be given
-func (s *server) Health(ctx context.Context, _ *pb.HealthRequest) (*pb.HealthReply, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "rpc_Health")
-	defer span.Finish()
+	if err == nil {
+		err = storeOutput(ctx, reply, in.Output)
+		if err != nil {
+			log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("storing merge failed")
+			reply.Success = false
+			reply.Note = err.Error()
+		}
+	}
+
+	// The reply is discarded when an error is returned, so include the file
+	// results in the error details instead
+	var details []proto.Message
+	for _, f := range files {
+		details = append(details, f)
+	}
 
-	return &pb.HealthReply{Healthy: true}, nil
+	return reply, replyError(err, details...)
 }
 
-func main() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+// Render Rasterises pages of the provided PDF, building it first if requested
+func (s *server) Render(ctx context.Context, in *pb.RenderRequest) (*pb.RenderReply, error) {
+	ctx, span := tracer.Start(ctx, "Render")
+	defer span.End()
+
+	var images []*pb.Image
+	var err error
+	pdf := in.Pdf
+
+	switch {
+	case in.File != nil && (len(in.Pdf) > 0 || in.Build != nil):
+		err = invalidInput("must provide only one of pdf, file and build")
+	case in.Build != nil:
+		err = fetchInputs(ctx, in.Build.Files)
+		for _, f := range in.Build.Files {
+			observeInput(opRender, f.Data)
+		}
+		if err == nil {
+			buildCtx := withReproducible(ctx, in.Build.Reproducible)
+			pdf, _, _, err = cachedLatexPDF(buildCtx, in.Build.Files, in.Build.BypassCache)
+		}
+	case in.File != nil:
+		err = fetchFile(ctx, in.File, "file")
+		pdf = in.File.Data
+		observeInput(opRender, pdf)
+	default:
+		observeInput(opRender, pdf)
+	}
 
-	log.Info().Msg("gedoc service starting")
+	if err == nil {
+		images, err = renderPDF(ctx, pdf, in.Pages, in.Options)
+	}
+
+	note := "render successful"
 
-	err := env.Parse(&cfg)
 	if err != nil {
-		log.Fatal().Err(err).Msg("parsing env vars")
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("render failed")
+		note = err.Error()
 	}
 
-	if cfg.Debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	reply := &pb.RenderReply{
+		Images:  images,
+		Success: err == nil,
+		Note:    note,
 	}
 
-	if cfg.HumanLogs {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	}
+	return reply, replyError(err)
+}
 
-	tracer, closer := initJaeger(cfg.ServiceName)
-	defer func(closer io.Closer) {
-		err := closer.Close()
-		if err != nil {
-			log.Fatal().Err(err).Msg("jaeger init")
-		}
-	}(closer)
+// ExtractText Returns the text of each page of the provided file
+func (s *server) ExtractText(ctx context.Context, in *pb.ExtractTextRequest) (*pb.ExtractTextReply, error) {
+	ctx, span := tracer.Start(ctx, "ExtractText")
+	defer span.End()
+
+	var pages []*pb.PageText
+	err := fetchFile(ctx, in.File, "file")
+	if in.File != nil {
+		observeInput(opExtractText, in.File.Data)
+	}
 
-	// StartSpanFromContext uses the global tracer, so we need to set it here to
-	// be our jaeger tracer
-	opentracing.SetGlobalTracer(tracer)
+	if err == nil {
+		pages, err = extractText(ctx, in.File, in.Ocr)
+	}
 
-	// Start router for reporting and metrics
-	go startRouters(tracer)
+	note := "extraction successful"
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.ExternalPort))
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to listen")
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("text extraction failed")
+		note = err.Error()
 	}
-	s := grpc.NewServer(
-		grpc.StreamInterceptor(grpcMiddleware.ChainStreamServer(
-			grpcOpentracing.StreamServerInterceptor(),
-		)),
-		grpc.UnaryInterceptor(grpcMiddleware.ChainUnaryServer(
-			grpcOpentracing.UnaryServerInterceptor(),
-		)),
-		grpc.MaxRecvMsgSize(1024000000),
-	)
-	pb.RegisterBuilderServer(s, &server{})
-	// Register reflection service on gRPC server.
-	reflection.Register(s)
-
-	go gracefulStopChecker(s)
 
-	if err := s.Serve(lis); err != nil {
-		log.Fatal().Err(err).Msg("failed to serve")
+	reply := &pb.ExtractTextReply{
+		Pages:   pages,
+		Success: err == nil,
+		Note:    note,
 	}
+
+	return reply, replyError(err)
 }
 
-func gracefulStopChecker(s *grpc.Server) {
-	var gracefulStop = make(chan os.Signal)
-	signal.Notify(gracefulStop, syscall.SIGTERM)
-	signal.Notify(gracefulStop, syscall.SIGKILL)
-	signal.Notify(gracefulStop, syscall.SIGINT)
+// RunPipeline Runs a graph of steps, returning the output of the final step
+// along with how each step went
+func (s *server) RunPipeline(ctx context.Context, in *pb.PipelineRequest) (*pb.PipelineReply, error) {
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, "RunPipeline")
+	defer span.End()
+	ctx = withReproducible(ctx, in.Reproducible)
+
+	var output *stepOutput
+	var results []*pb.StepResult
+	var pages int
+
+	p, err := validatePipeline(in)
+	if err == nil {
+		err = checkOutputTarget(ctx, in.Target)
+	}
 
-	sig := <-gracefulStop
-	log.Info().Str("signal", sig.String()).Msg("caught sig")
-	if s != nil {
-		s.GracefulStop()
+	for i := 0; err == nil && i < len(in.Steps); i++ {
+		if err = fetchInputs(ctx, in.Steps[i].Files); err != nil {
+			err = stepFailure(i, in.Steps[i].Name, err)
+		}
+		for _, f := range in.Steps[i].Files {
+			observeInput(opPipeline, f.Data)
+		}
 	}
-	os.Exit(0)
-}
 
-func buildLatexPDF(ctx context.Context, files []*pb.File) ([]byte, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "buildLatexPDF")
-	defer span.Finish()
+	if err == nil {
+		output, results, err = p.run(ctx)
+	}
 
-	var final []byte
+	if err == nil {
+		pages = output.pages
+		if pages == 0 {
+			pages, err = newBuilder().PageCount(ctx, output.file.Data)
+		}
+	}
 
-	id, err := uuid.NewV4()
-	if err != nil {
-		return final, err
+	if err == nil {
+		observeOutput(opPipeline, output.file.Data, pages)
+		chargeOutputPages(ctx, pages)
 	}
 
-	resultFileName := id.String() + ".pdf"
+	note := "pipeline successful"
 
-	directory, err := ioutil.TempDir("", "buildLatexPDF")
 	if err != nil {
-		return final, err
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("pipeline failed")
+		note = err.Error()
 	}
-	directoryLogger := log.With().Str("directory", directory).Logger()
-	directoryLogger.Info().Msg("temp directory created")
-	defer func(path string) {
-		directoryLogger.Info().Msg("removing temp directory")
-		err := os.RemoveAll(path)
-		if err != nil {
-			directoryLogger.Error().Err(err).Msg("temp directory")
+
+	reply := &pb.PipelineReply{
+		Success: err == nil,
+		Note:    note,
+		Steps:   results,
+	}
+	if err == nil {
+		reply.Output = &pb.FileReply{
+			Data:    output.file.Data,
+			Success: true,
+			Note:    note,
 		}
-	}(directory)
+		describeOutput(reply.Output, pages, started)
+		saveArtifact(ctx, reply.Output)
 
-	if len(files) == 0 {
-		return final, fmt.Errorf("must provide one or more files")
+		err = storeOutput(ctx, reply.Output, in.Target)
+		if err != nil {
+			log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("storing pipeline output failed")
+			reply.Success = false
+			reply.Note = err.Error()
+			reply.Output.Success = false
+			reply.Output.Note = err.Error()
+		}
 	}
 
-	// Use our predefined settings
-	err = copyLatexSettings(directory)
-	if err != nil {
-		return final, err
+	// The reply is discarded when an error is returned, so include the step
+	// results in the error details instead
+	var details []proto.Message
+	for _, r := range results {
+		details = append(details, r)
 	}
 
-	// Create the provided files in a unique folder
-	for _, f := range files {
-		err := ioutil.WriteFile(directory+"/"+f.Name, f.Data, os.ModePerm)
+	return reply, replyError(err, details...)
+}
 
-		if err != nil {
-			return final, err
-		}
-	}
+// GetArtifact Returns a PDF kept in the artifact store by an earlier request
+func (s *server) GetArtifact(ctx context.Context, in *pb.GetArtifactRequest) (*pb.FileReply, error) {
+	ctx, span := tracer.Start(ctx, "GetArtifact")
+	defer span.End()
 
-	// Clean, and then run the build
-	clean := exec.Command("latexmk", "-C")
-	cmd := exec.Command("latexmk", fmt.Sprintf("-jobname=%s", id))
+	started := time.Now()
+	data, pages, err := loadArtifact(ctx, in.ArtifactId)
 
-	cmd.Dir = directory
-	clean.Dir = directory
+	note := "artifact found"
 
-	log.Info().Msg("cleaning")
-	out, err := clean.Output()
 	if err != nil {
-		log.Error().Err(err).Str("stdout", string(out)).Msg("running latexmk clean")
-		return final, err
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("get artifact failed")
+		note = err.Error()
 	}
 
-	log.Printf("building")
-	out, err = cmd.Output()
-	if err != nil {
-		log.Error().Err(err).Str("stdout", string(out)).Msg("running latexmk build")
-		return final, err
+	reply := &pb.FileReply{
+		Data:    data,
+		Success: err == nil,
+		Note:    note,
+	}
+	if err == nil {
+		describeOutput(reply, pages, started)
+		reply.ArtifactId = in.ArtifactId
 	}
 
-	// Load the produced PDF to return
-	return ioutil.ReadFile(directory + "/" + resultFileName)
+	return reply, replyError(err)
 }
 
-func copyLatexSettings(folder string) error {
-	var latexMakeConfig = []byte(`
-$pdf_mode = 1;
-$pdflatex=q/xelatex -synctex=1 %O %S/
-`)
+// DeleteArtifact Removes a PDF from the artifact store before it expires
+func (s *server) DeleteArtifact(ctx context.Context, in *pb.DeleteArtifactRequest) (*pb.DeleteArtifactReply, error) {
+	ctx, span := tracer.Start(ctx, "DeleteArtifact")
+	defer span.End()
 
-	dest, err := os.Create(folder + "/.latexmkrc")
+	err := deleteArtifact(ctx, in.ArtifactId)
+
+	note := "artifact deleted"
 
 	if err != nil {
-		return err
+		log.Error().Err(err).Str("client", clientFromContext(ctx)).Msg("delete artifact failed")
+		note = err.Error()
 	}
 
-	_, err = dest.Write(latexMakeConfig)
+	reply := &pb.DeleteArtifactReply{
+		Success: err == nil,
+		Note:    note,
+	}
 
-	return err
+	return reply, replyError(err)
 }
 
-func mergeFiles(ctx context.Context, files []*pb.File, forceEven bool) ([]byte, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "mergeFiles")
-	defer span.Finish()
+// Health Implements health, returning whether the last readiness checks passed.
+// New clients should use the standard grpc.health.v1 service instead
+func (s *server) Health(ctx context.Context, _ *pb.HealthRequest) (*pb.HealthReply, error) {
+	ctx, span := tracer.Start(ctx, "rpc_Health")
+	defer span.End()
 
-	var merged []byte
-	var prepared [][]byte // We need to store each file as a PDF first before merging
+	return &pb.HealthReply{Healthy: readiness.isReady()}, nil
+}
 
-	id, err := uuid.NewV4()
+func main() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+
+	log.Info().Msg("gedoc service starting")
+
+	err := env.Parse(&cfg)
 	if err != nil {
-		return merged, err
+		log.Fatal().Err(err).Msg("parsing env vars")
 	}
 
-	directory, err := ioutil.TempDir("", "mergeFiles")
-	if err != nil {
-		return merged, err
+	if cfg.Debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
-	directoryLogger := log.With().Str("directory", directory).Logger()
-	directoryLogger.Info().Msg("temp directory created")
-	defer func(path string) {
-		directoryLogger.Info().Msg("removing temp directory")
-		err := os.RemoveAll(path)
-		if err != nil {
-			directoryLogger.Error().Err(err).Msg("temp directory")
-		}
-	}(directory)
 
-	for _, f := range files {
-		kind, unknown := filetype.Match(f.Data)
+	sched = newScheduler()
 
-		if unknown != nil {
-			return merged, fmt.Errorf("file type for %s unsupported", f.Name)
+	if cfg.APIKeysFile != "" {
+		apiKeys, err = loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("loading api keys")
 		}
+		log.Info().Int("keys", len(apiKeys)).Msg("api keys loaded")
+	}
 
-		switch kind.Extension {
-		case "pdf":
-			prepared = append(prepared, f.Data)
-		case "jpg", "png":
-			converted, err := imageToPDF(f.Data)
-			if err != nil {
-				return merged, fmt.Errorf("failed to convert image %s to pdf: %s", f.Name, err)
-			}
-			prepared = append(prepared, converted)
+	if cfg.QuotasFile != "" {
+		quotas, err = loadQuotas(cfg.QuotasFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("loading quotas")
 		}
+	}
 
-		log.Info().
-			Str("file_type", kind.MIME.Value).
-			Str("extension", kind.Extension).
-			Str("filename", f.Name).
-			Msg("file info")
+	if cfg.BuildCacheDir != "" {
+		latexCache, err = openDiskCache("build", cfg.BuildCacheDir, cfg.BuildCacheMaxBytes, cfg.BuildCacheTTL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("opening build cache")
+		}
 	}
 
-	// Create the provided files in a unique folder, and note their names
-	outputFileName := id.String() + ".pdf"
-	var args = []string{
-		"--warning-exit-0",
-		"--empty",
-		outputFileName,
-		"--pages",
+	var s3 *minio.Client
+	if cfg.S3Endpoint != "" {
+		s3, err = newS3Client(cfg.S3Endpoint, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretAccessKey, cfg.S3UseSSL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("configuring s3")
+		}
 	}
-	for i, p := range prepared {
-		where := fmt.Sprintf("%s/%d.pdf", directory, i)
-		pdfFileName := fmt.Sprintf("%d.pdf", i)
+	configureOutputStores(s3)
+	configureFetchers(s3)
 
-		log.Debug().Int("bytes", len(p)).Str("file_location", where).Msg("writing file")
-		if err := ioutil.WriteFile(where, p, os.ModePerm); err != nil {
-			return merged, err
+	if cfg.ArtifactDir != "" {
+		artifacts, err = openDiskCache("artifact", cfg.ArtifactDir, cfg.ArtifactMaxBytes, cfg.ArtifactTTL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("opening artifact store")
 		}
+	}
 
-		if forceEven {
-			// read file back and check page number, if odd then merge blank.pdf to the end
-			cmd := exec.Command("qpdf", "--show-npages", pdfFileName)
-			cmd.Dir = directory
-			output := bytes.NewBufferString("")
-			cmd.Stderr = output
-			log.Info().Str("qpdf stderr", output.String()).
-				Str("qpdf cmd", cmd.String()).
-				Msg("ran show pages")
-			out, err := cmd.Output()
-			if err != nil {
-				return merged, fmt.Errorf("exec qpdf page count: %v", err)
-			}
+	if cfg.Sandbox && os.Getuid() == 0 {
+		log.Warn().Msg("sandboxed tools keep the server's user, so running as root weakens the sandbox")
+	}
+	// A sandbox that can't confine tools mustn't be relied on
+	if err := checkSandbox(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("SANDBOX is set, but tools can't be started in the sandbox")
+	}
+	if cfg.Sandbox && cfg.SandboxProcesses > 0 && cfg.SandboxCgroup == "" {
+		log.Warn().Msg("SANDBOX_PROCESSES is set without SANDBOX_CGROUP, so sandboxed processes aren't limited")
+	}
 
-			pageCount, err := strconv.Atoi(strings.TrimSpace(string(out)))
-			if err != nil {
-				return merged, fmt.Errorf("show-npages output to int: %v", err)
-			}
+	creds, err := serverCredentials()
+	if err != nil {
+		log.Fatal().Err(err).Msg("tls init")
+	}
 
-			isOdd := pageCount%2 == 1
-			log.Debug().
-				Str("pdf_filename", pdfFileName).
-				Int("page_count", pageCount).
-				Bool("is_odd", isOdd).
-				Msg("pdf stats")
-			if isOdd {
-				blankMergeCmd := exec.Command("qpdf", "--warning-exit-0", "--replace-input", pdfFileName, "--pages", pdfFileName, cfg.PdfBlankPath, "--")
-				blankMergeCmd.Dir = directory
-				output, err := blankMergeCmd.CombinedOutput()
-				log.Info().
-					Str("qpdf output", string(output)).
-					Str("qpdf cmd", blankMergeCmd.String()).
-					Msg("ran blank page merge")
-				if err != nil {
-					return merged, fmt.Errorf("adding blank to odd numberd pdf %d: %v", i, err)
-				}
-			}
-		}
+	if cfg.HumanLogs {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	}
 
-		args = append(args, pdfFileName)
+	shutdownTracing, err := initTracing(context.Background(), cfg.ServiceName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("tracing init")
 	}
 
-	args = append(args, "--")
+	// Start router for reporting and metrics
+	go startRouters()
 
-	cmd := exec.Command("qpdf", args...)
-	cmd.Dir = directory
-	output, err := cmd.CombinedOutput()
-	log.Info().
-		Str("qpdf output", string(output)).
-		Str("qpdf cmd", cmd.String()).
-		Msg("ran merge command")
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.ExternalPort))
 	if err != nil {
-		return merged, fmt.Errorf("failed merging pdf files: %s", err)
+		log.Fatal().Err(err).Msg("failed to listen")
+	}
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			recoverPanic,
+			authenticate,
+			observeRPC,
+			enforceQuotas,
+			countJobs,
+		),
+		grpc.ChainStreamInterceptor(
+			recoverStreamPanic,
+			authenticateStream,
+			enforceStreamQuotas,
+		),
+		grpc.MaxRecvMsgSize(1024000000),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
 	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterBuilderServer(s, &server{})
+	hs := newHealthServer()
+	healthpb.RegisterHealthServer(s, hs)
+	// Register reflection service on gRPC server.
+	reflection.Register(s)
 
-	// Load the produced PDF to return
-	merged, err = ioutil.ReadFile(directory + "/" + outputFileName)
+	go watchReadiness(context.Background(), hs)
+	if cfg.SelfTest {
+		go runSelfTest(context.Background())
+	}
+	go gracefulStopChecker(s, shutdownTracing)
 
-	if err != nil {
-		return merged, fmt.Errorf("failed reading produced PDF: %s", err)
+	atomic.StoreInt32(&live, 1)
+	if err := s.Serve(lis); err != nil {
+		log.Fatal().Err(err).Msg("failed to serve")
 	}
+}
 
-	return merged, nil
+func gracefulStopChecker(s *grpc.Server, shutdownTracing func(context.Context) error) {
+	var gracefulStop = make(chan os.Signal, 1)
+	signal.Notify(gracefulStop, syscall.SIGTERM)
+	signal.Notify(gracefulStop, syscall.SIGKILL)
+	signal.Notify(gracefulStop, syscall.SIGINT)
+
+	sig := <-gracefulStop
+	log.Info().Str("signal", sig.String()).Msg("caught sig")
+	if s != nil {
+		s.GracefulStop()
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error().Err(err).Msg("tracing shutdown")
+	}
+	os.Exit(0)
 }
 
-func imageToPDF(file []byte) ([]byte, error) {
-	var pdf []byte
+// newBuilder returns a builder configured from cfg, running tools through the
+// server's runner and waiting for room in the scheduler.  LaTeX is compiled in
+// the sandbox when it is enabled
+func newBuilder(opts ...builder.Option) *builder.Builder {
+	options := []builder.Option{
+		builder.WithRunner(toolRunner{}),
+		builder.WithLimiter(sched),
+		builder.WithNativeImages(cfg.NativeImages),
+		builder.WithMergeParallelism(cfg.MergeParallelism),
+		builder.WithLatexmkObserver(observeLatexmk),
+		builder.WithCleanupObserver(func(error) { tempDirCleanupFailures.Inc() }),
+	}
+	if cfg.Sandbox {
+		options = append(options,
+			builder.WithLatexRunner(toolRunner{prepare: sandboxCommand}),
+			builder.WithLatexEngine(latexEngine()...),
+		)
+	}
 
-	id, err := uuid.NewV4()
-	if err != nil {
-		return pdf, err
+	return builder.New(append(options, opts...)...)
+}
+
+// latexEngine returns the engine newBuilder has latexmk compile with
+func latexEngine() []string {
+	if !cfg.Sandbox {
+		return builder.LatexEngine
 	}
 
-	resultFileName := id.String() + ".pdf"
+	// Each TeX run gets its own helper, so a limit it breaks is reported.  It
+	// stays in latexmk's cgroup, so their processes are counted together
+	return append(sandboxHelperArgs("", "", builder.LatexEngine[0]), builder.LatexEngine[1:]...)
+}
 
-	directory, err := ioutil.TempDir("", "imageToPDF")
-	if err != nil {
-		return pdf, err
+// builderFiles returns files as the builder's input files
+func builderFiles(files []*pb.File) []builder.File {
+	converted := make([]builder.File, len(files))
+	for i, f := range files {
+		converted[i] = builder.File{Name: f.Name, Folder: f.Folder, Data: f.Data}
 	}
-	directoryLogger := log.With().Str("directory", directory).Logger()
-	directoryLogger.Info().Msg("temp directory created")
-	defer func(path string) {
-		directoryLogger.Info().Msg("removing temp directory")
-		err := os.RemoveAll(path)
-		if err != nil {
-			directoryLogger.Error().Err(err).Msg("temp directory")
+	return converted
+}
+
+// fileStatuses maps the builder's file statuses to those in replies
+var fileStatuses = map[builder.FileStatus]pb.FileStatus{
+	builder.StatusUnprocessed: pb.FileStatus_UNPROCESSED,
+	builder.StatusOK:          pb.FileStatus_OK,
+	builder.StatusSkipped:     pb.FileStatus_SKIPPED,
+	builder.StatusFailed:      pb.FileStatus_FAILED,
+}
+
+// fileErrorCodes maps the builder's file error codes to those in replies
+var fileErrorCodes = map[builder.FileErrorCode]pb.FileErrorCode{
+	builder.CodeNone:             pb.FileErrorCode_NO_ERROR,
+	builder.CodeUnsupportedType:  pb.FileErrorCode_UNSUPPORTED_TYPE,
+	builder.CodeConversionFailed: pb.FileErrorCode_CONVERSION_FAILED,
+	builder.CodeOCRFailed:        pb.FileErrorCode_OCR_FAILED,
+	builder.CodeDamaged:          pb.FileErrorCode_DAMAGED,
+	builder.CodeRepairNotAllowed: pb.FileErrorCode_REPAIR_NOT_ALLOWED,
+	builder.CodeSanitizeFailed:   pb.FileErrorCode_SANITIZE_FAILED,
+	builder.CodePageCountFailed:  pb.FileErrorCode_PAGE_COUNT_FAILED,
+	builder.CodePaddingFailed:    pb.FileErrorCode_PADDING_FAILED,
+	builder.CodeInternal:         pb.FileErrorCode_INTERNAL_ERROR,
+}
+
+// preflightStatuses maps the builder's preflight statuses to those in replies
+var preflightStatuses = map[builder.PreflightStatus]pb.PreflightStatus{
+	builder.PreflightClean:    pb.PreflightStatus_CLEAN,
+	builder.PreflightRepaired: pb.PreflightStatus_REPAIRED,
+	builder.PreflightRejected: pb.PreflightStatus_REJECTED,
+}
+
+// fileResults converts the results of a merge for its reply, returning them
+// along with the preflight results they include
+func fileResults(results []*builder.FileResult) ([]*pb.FileResult, []*pb.PreflightResult) {
+	var files []*pb.FileResult
+	var preflight []*pb.PreflightResult
+	for _, r := range results {
+		f := &pb.FileResult{
+			Index:     int32(r.Index),
+			Name:      r.Name,
+			MimeType:  r.MimeType,
+			PageCount: int32(r.PageCount),
+			Status:    fileStatuses[r.Status],
+			ErrorCode: fileErrorCodes[r.ErrorCode],
+			Message:   r.Message,
+		}
+		if p := r.Preflight; p != nil {
+			f.Preflight = &pb.PreflightResult{
+				Index:    int32(p.Index),
+				Name:     p.Name,
+				Status:   preflightStatuses[p.Status],
+				Problems: p.Problems,
+				Removed:  p.Removed,
+			}
+			preflight = append(preflight, f.Preflight)
 		}
-	}(directory)
+		files = append(files, f)
+	}
+	return files, preflight
+}
 
-	// Save image so we can work with it
-	err = ioutil.WriteFile(directory+"/img", file, os.ModePerm)
-	if err != nil {
-		return pdf, err
-	}
-
-	cmd := exec.Command(
-		"convert",
-		"img",
-		"-resize",
-		"595x842",
-		"-background",
-		"white",
-		"-page",
-		"a4",
-		resultFileName,
-	)
-	cmd.Dir = directory
-	output, err := cmd.CombinedOutput()
-	log.Info().
-		Str("convert command", cmd.String()).
-		Str("convert output", string(output)).
-		Msg("ran convert command")
-	if err != nil {
-		return pdf, fmt.Errorf(err.Error() + ": " + string(output))
+// buildLatexPDF compiles files with latexmk, returning the PDF and its page
+// count.  Failures caused by breaking the sandbox's restrictions are reported
+// as such
+func buildLatexPDF(ctx context.Context, files []*pb.File) ([]byte, int, error) {
+	pdf, pages, err := newBuilder().BuildLatex(ctx, builderFiles(files))
+
+	var e *builder.Error
+	if cfg.Sandbox && errors.As(err, &e) && e.Kind == builder.KindCompile {
+		if violation := sandboxViolation(e.Output, e.Log); violation != nil {
+			return nil, 0, violation
+		}
 	}
 
-	return ioutil.ReadFile(directory + "/" + resultFileName)
+	return pdf, pages, err
+}
+
+// pdfHeaderPattern matches the version in a PDF's header, which must appear
+// within its first 1024 bytes
+var pdfHeaderPattern = regexp.MustCompile(`%PDF-(\d\.\d)`)
+
+// describeOutput records the details of the PDF in reply, which has pages
+// pages and took since started to produce, so callers needn't work them out
+func describeOutput(reply *pb.FileReply, pages int, started time.Time) {
+	sum := sha256.Sum256(reply.Data)
+	reply.Sha256 = hex.EncodeToString(sum[:])
+	reply.SizeBytes = int64(len(reply.Data))
+	reply.PageCount = int32(pages)
+	reply.PdfVersion = pdfVersion(reply.Data)
+	reply.DurationMs = time.Since(started).Milliseconds()
+}
+
+// pdfVersion returns the version from the header of pdf, or an empty string if
+// it has none
+func pdfVersion(pdf []byte) string {
+	if len(pdf) > 1024 {
+		pdf = pdf[:1024]
+	}
+	if m := pdfHeaderPattern.FindSubmatch(pdf); m != nil {
+		return string(m[1])
+	}
+	return ""
 }
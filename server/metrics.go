@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/h2non/filetype"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const metricsNamespace = "gedoc"
+
+// Operation labels, shared by the input and output metrics
+const (
+	opBuildLatex  = "build_latex"
+	opMerge       = "merge"
+	opRender      = "render"
+	opExtractText = "extract_text"
+	opPipeline    = "pipeline"
+)
+
+// inputTypes are the detected file types given their own label.  Anything
+// else is counted as other, to keep the number of series bounded
+var inputTypes = map[string]bool{
+	"pdf": true,
+	"jpg": true,
+	"png": true,
+}
+
+var (
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Time taken to handle each RPC, by method, outcome and client.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"method", "outcome", "code", "client"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "auth_failures_total",
+		Help:      "Number of requests rejected for failing authentication, by reason.",
+	}, []string{"reason"})
+
+	quotaRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "quota_rejections_total",
+		Help:      "Number of requests rejected for exceeding a quota, by client and limit.",
+	}, []string{"client", "limit"})
+
+	jobsInFlightGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "jobs_in_flight",
+		Help:      "Number of requests currently being worked on, excluding health checks.",
+	})
+
+	latexmkPasses = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "latexmk_passes",
+		Help:      "Number of times latexmk ran the TeX engine for each build.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 8),
+	})
+
+	latexmkDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "latexmk_duration_seconds",
+		Help:      "Time taken by latexmk to build each document.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 10),
+	})
+
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Time taken by each invocation of an external tool, by tool and exit code.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"tool", "exit_code"})
+
+	inputFiles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "input_files_total",
+		Help:      "Number of files received, by operation and detected type.",
+	}, []string{"operation", "type"})
+
+	inputBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "input_bytes_total",
+		Help:      "Size of files received, by operation and detected type.",
+	}, []string{"operation", "type"})
+
+	outputBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "output_pdf_bytes",
+		Help:      "Size of each PDF returned, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 4, 9),
+	}, []string{"operation"})
+
+	outputPages = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "output_pdf_pages",
+		Help:      "Number of pages in each PDF returned, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	}, []string{"operation"})
+
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_depth",
+		Help:      "Number of jobs waiting for a slot, by pool.",
+	}, []string{"pool"})
+
+	queueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_wait_seconds",
+		Help:      "Time jobs waited for a slot before starting, by pool.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"pool"})
+
+	poolActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pool_active_jobs",
+		Help:      "Number of jobs currently holding a slot, by pool.",
+	}, []string{"pool"})
+
+	schedulerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scheduler_rejections_total",
+		Help:      "Number of jobs rejected without running, by pool and reason.",
+	}, []string{"pool", "reason"})
+
+	sandboxViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "sandbox_violations_total",
+		Help:      "Number of sandboxed builds that broke a restriction, by violation.",
+	}, []string{"violation"})
+
+	fetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Time taken to fetch each input referenced by URI, by scheme and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"scheme", "outcome"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cache lookups, by cache and result.",
+	}, []string{"cache", "result"})
+
+	cacheBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_bytes",
+		Help:      "Size of the entries held by each cache.",
+	}, []string{"cache"})
+
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries removed from each cache, by reason.",
+	}, []string{"cache", "reason"})
+
+	tempDirCleanupFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "temp_dir_cleanup_failures_total",
+		Help:      "Number of temporary directories that couldn't be removed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		rpcDuration,
+		authFailures,
+		quotaRejections,
+		jobsInFlightGauge,
+		latexmkPasses,
+		latexmkDuration,
+		toolDuration,
+		inputFiles,
+		inputBytes,
+		outputBytes,
+		outputPages,
+		queueDepth,
+		queueWait,
+		poolActive,
+		schedulerRejections,
+		sandboxViolations,
+		fetchDuration,
+		cacheRequests,
+		cacheBytes,
+		cacheEvictions,
+		tempDirCleanupFailures,
+	)
+}
+
+// observeRPC records the duration and outcome of each RPC.  In legacy error
+// mode failures are only visible in the reply, so its success field is checked
+// too
+func observeRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	} else if r, ok := resp.(interface{ GetSuccess() bool }); ok && !r.GetSuccess() {
+		outcome = "failure"
+	}
+
+	// Only registered methods reach interceptors, and clients come from the
+	// keys file or the client CA, so labels are bounded
+	rpcDuration.WithLabelValues(path.Base(info.FullMethod), outcome, status.Code(err).String(), clientFromContext(ctx)).
+		Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// observeInput counts a file received by operation, by its detected type
+func observeInput(operation string, data []byte) {
+	kind := "other"
+	if t, err := filetype.Match(data); err == nil && inputTypes[t.Extension] {
+		kind = t.Extension
+	}
+
+	inputFiles.WithLabelValues(operation, kind).Inc()
+	inputBytes.WithLabelValues(operation, kind).Add(float64(len(data)))
+}
+
+// observeOutput records the size and page count of a PDF returned by
+// operation.  A page count of zero means it isn't known
+func observeOutput(operation string, pdf []byte, pages int) {
+	outputBytes.WithLabelValues(operation).Observe(float64(len(pdf)))
+	if pages > 0 {
+		outputPages.WithLabelValues(operation).Observe(float64(pages))
+	}
+}
+
+// observeTool records how long an invocation of tool took, and how it exited
+func observeTool(tool string, start time.Time, err error) {
+	toolDuration.WithLabelValues(tool, exitCodeLabel(err)).Observe(time.Since(start).Seconds())
+}
+
+// exitCodeLabel returns a bounded label for the exit status of a command that
+// finished with err.  The codes tools use to report problems worth telling
+// apart are kept, while any others are grouped together
+func exitCodeLabel(err error) string {
+	if err == nil {
+		return "0"
+	}
+
+	if errors.Is(err, exec.ErrNotFound) {
+		return "not_found"
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return "not_run"
+	}
+
+	switch code := exitErr.ExitCode(); {
+	case code < 0:
+		return "killed"
+	case code <= 3:
+		return strconv.Itoa(code)
+	}
+
+	return "other"
+}
+
+// observeLatexmk records the duration of a latexmk build, and how many times it
+// ran the TeX engine, which it reports in its output
+func observeLatexmk(output []byte, duration time.Duration) {
+	latexmkDuration.Observe(duration.Seconds())
+	if passes := bytes.Count(output, []byte("Run number ")); passes > 0 {
+		latexmkPasses.Observe(float64(passes))
+	}
+}
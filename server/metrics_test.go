@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+)
+
+// TestExitCodeLabel Checks exit codes are reduced to a bounded set of labels
+func TestExitCodeLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		label string
+	}{
+		{"success", []string{"-c", "exit 0"}, "0"},
+		{"warnings", []string{"-c", "exit 3"}, "3"},
+		{"unusual", []string{"-c", "exit 42"}, "other"},
+		{"killed", []string{"-c", "kill -9 $$"}, "killed"},
+	}
+
+	for _, test := range tests {
+		err := exec.Command("sh", test.args...).Run()
+		if label := exitCodeLabel(err); label != test.label {
+			t.Errorf("%s: expected label %s, got %s", test.name, test.label, label)
+		}
+	}
+
+	_, err := exec.LookPath("gedoc-missing-tool")
+	if label := exitCodeLabel(err); label != "not_found" {
+		t.Errorf("Expected label not_found for a missing tool, got %s", label)
+	}
+}
+
+// TestObserveRPC Checks unsuccessful replies are counted as failures, even when
+// no error is returned
+func TestObserveRPC(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/builder.Builder/TestObserveRPC"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.FileReply{Success: false}, nil
+	}
+
+	if _, err := observeRPC(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	observed := &dto.Metric{}
+	histogram := rpcDuration.WithLabelValues("TestObserveRPC", "failure", "OK", anonymousClient).(prometheus.Histogram)
+	if err := histogram.Write(observed); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := observed.GetHistogram().GetSampleCount(); count != 1 {
+		t.Errorf("Expected one failed call observed, got %d", count)
+	}
+}
+
+// TestObserveLatexmk Counts TeX engine runs in latexmk output
+func TestObserveLatexmk(t *testing.T) {
+	before := &dto.Metric{}
+	if err := latexmkPasses.Write(before); err != nil {
+		t.Fatal(err)
+	}
+
+	output := []byte("Run number 1 of rule 'pdflatex'\nRun number 2 of rule 'pdflatex'\n")
+	observeLatexmk(output, 0)
+
+	after := &dto.Metric{}
+	if err := latexmkPasses.Write(after); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := after.GetHistogram().GetSampleSum() - before.GetHistogram().GetSampleSum(); sum != 2 {
+		t.Errorf("Expected two passes observed, got %v", sum)
+	}
+}
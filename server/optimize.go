@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/rs/zerolog/log"
+)
+
+// ghostscriptSettings maps lossy presets to Ghostscript's -dPDFSETTINGS
+var ghostscriptSettings = map[pb.OptimizePreset]string{
+	pb.OptimizePreset_SCREEN: "/screen",
+	pb.OptimizePreset_EBOOK:  "/ebook",
+	pb.OptimizePreset_PRINT:  "/printer",
+}
+
+// optimizePDF reduces the size of pdf according to preset.  Lossy presets
+// redistill the document with Ghostscript, downsampling images and subsetting
+// fonts, after which qpdf compresses object streams and linearizes the result.
+// The pdf is returned unchanged for NONE
+func optimizePDF(ctx context.Context, pdf []byte, preset pb.OptimizePreset) ([]byte, *pb.Optimization, error) {
+	if preset == pb.OptimizePreset_NONE {
+		return pdf, nil, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "optimizePDF")
+	defer span.End()
+
+	settings, lossy := ghostscriptSettings[preset]
+	if !lossy && preset != pb.OptimizePreset_LOSSLESS {
+		return nil, nil, invalidInput("unknown optimize preset %d", preset)
+	}
+
+	release, err := sched.acquire(ctx, poolRender)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("optimizePDF")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), pdf, os.ModePerm); err != nil {
+		return nil, nil, err
+	}
+
+	source := "input.pdf"
+	if lossy {
+		output, err := b.RunTool(ctx, directory, "gs",
+			"-dSAFER",
+			"-dBATCH",
+			"-dNOPAUSE",
+			"-dQUIET",
+			"-sDEVICE=pdfwrite",
+			"-dCompatibilityLevel=1.5",
+			"-dPDFSETTINGS="+settings,
+			"-dSubsetFonts=true",
+			"-dCompressFonts=true",
+			"-dDetectDuplicateImages=true",
+			"-sOutputFile=distilled.pdf",
+			"input.pdf",
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("distilling pdf: %w: %s", err, output)
+		}
+		source = "distilled.pdf"
+	}
+
+	args := append([]string{
+		"--warning-exit-0",
+		"--linearize",
+		"--object-streams=generate",
+		"--compress-streams=y",
+		"--recompress-flate",
+		"--compression-level=9",
+	}, builder.DeterministicIDArgs(ctx)...)
+	output, err := b.RunTool(ctx, directory, "qpdf", append(args, source, "optimized.pdf")...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compressing pdf: %w: %s", err, output)
+	}
+
+	optimized, err := ioutil.ReadFile(filepath.Join(directory, "optimized.pdf"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Info().
+		Str("preset", preset.String()).
+		Int("original_size", len(pdf)).
+		Int("optimized_size", len(optimized)).
+		Msg("optimized pdf")
+
+	return optimized, &pb.Optimization{
+		Preset:        preset,
+		OriginalSize:  int64(len(pdf)),
+		OptimizedSize: int64(len(optimized)),
+	}, nil
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// stepNamePattern matches the names steps may have
+var stepNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// outputNamePattern matches the file names step outputs may be given, which
+// never leave the step's directory
+var outputNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// stepArity The number of inputs, counting both step outputs and files, a
+// kind of step takes.  A max of zero means no limit
+type stepArity struct {
+	min int
+	max int
+}
+
+// stepArities holds the arity of every kind of step that can be run
+var stepArities = map[pb.StepKind]stepArity{
+	pb.StepKind_RENDER_TEMPLATE: {1, 1},
+	pb.StepKind_BUILD_LATEX:     {1, 0},
+	pb.StepKind_CONVERT_IMAGE:   {1, 1},
+	pb.StepKind_CONVERT_OFFICE:  {1, 1},
+	pb.StepKind_MERGE_PDFS:      {1, 0},
+	pb.StepKind_STAMP:           {2, 2},
+	pb.StepKind_ENCRYPT:         {1, 1},
+	pb.StepKind_OPTIMIZE:        {1, 1},
+}
+
+// pipeline A validated graph of steps
+type pipeline struct {
+	steps []*pb.PipelineStep
+	// index maps each step's name to its position in steps
+	index map[string]int
+	// output is the position of the step whose output is returned
+	output int
+}
+
+// stepOutput A file produced by a step, or given to one
+type stepOutput struct {
+	file *pb.File
+	// pages is the page count of a PDF, or zero when it isn't known
+	pages int
+}
+
+// validatePipeline checks the steps in in form a graph that can be run,
+// without running any of them
+func validatePipeline(in *pb.PipelineRequest) (*pipeline, error) {
+	p := &pipeline{steps: in.Steps, index: map[string]int{}}
+
+	if len(in.Steps) == 0 {
+		return nil, invalidInput("must provide one or more steps")
+	}
+	if len(in.Steps) > cfg.PipelineMaxSteps {
+		return nil, invalidInput("pipelines may have at most %d steps", cfg.PipelineMaxSteps)
+	}
+
+	for i, step := range in.Steps {
+		if !stepNamePattern.MatchString(step.Name) {
+			return nil, stepInvalid(i, "name", "step name %q must be letters, digits, dashes and underscores", step.Name)
+		}
+		if _, ok := p.index[step.Name]; ok {
+			return nil, stepInvalid(i, "name", "step name %q is used more than once", step.Name)
+		}
+		p.index[step.Name] = i
+	}
+
+	for i, step := range in.Steps {
+		arity, ok := stepArities[step.Kind]
+		if !ok {
+			return nil, stepInvalid(i, "kind", "step %s has unknown kind %s", step.Name, step.Kind)
+		}
+
+		n := len(step.Inputs) + len(step.Files)
+		if n < arity.min || (arity.max > 0 && n > arity.max) {
+			return nil, stepInvalid(i, "inputs", "%s steps take %s, %s has %d", step.Kind, arity, step.Name, n)
+		}
+
+		if step.OutputName != "" && !outputNamePattern.MatchString(step.OutputName) {
+			return nil, stepInvalid(i, "output_name", "invalid output name %q", step.OutputName)
+		}
+
+		for _, input := range step.Inputs {
+			j, ok := p.index[input]
+			if !ok {
+				return nil, stepInvalid(i, "inputs", "step %s uses unknown step %q", step.Name, input)
+			}
+			// Templates give LaTeX source, which only a build can use
+			if in.Steps[j].Kind == pb.StepKind_RENDER_TEMPLATE && step.Kind != pb.StepKind_BUILD_LATEX {
+				return nil, stepInvalid(i, "inputs", "%s steps can't use template %s, which isn't a PDF", step.Kind, input)
+			}
+		}
+	}
+
+	if err := p.checkAcyclic(); err != nil {
+		return nil, err
+	}
+
+	p.output = len(in.Steps) - 1
+	if in.Output != "" {
+		i, ok := p.index[in.Output]
+		if !ok {
+			return nil, &builder.Error{Kind: builder.KindInvalidInput, Err: fmt.Errorf("output names unknown step %q", in.Output), Field: "output"}
+		}
+		p.output = i
+	}
+	if p.steps[p.output].Kind == pb.StepKind_RENDER_TEMPLATE {
+		return nil, &builder.Error{Kind: builder.KindInvalidInput, Err: fmt.Errorf("output step %s must give a PDF", p.steps[p.output].Name), Field: "output"}
+	}
+
+	return p, nil
+}
+
+func (a stepArity) String() string {
+	switch {
+	case a.max == 0:
+		return fmt.Sprintf("at least %d inputs", a.min)
+	case a.min == a.max:
+		return fmt.Sprintf("%d inputs", a.min)
+	}
+	return fmt.Sprintf("%d to %d inputs", a.min, a.max)
+}
+
+// checkAcyclic returns an error naming a step on a cycle, if there is one.
+// Steps are removed once everything they use has been, and any left over must
+// depend on each other
+func (p *pipeline) checkAcyclic() error {
+	waiting := make([]int, len(p.steps))
+	users := make([][]int, len(p.steps))
+	var ready []int
+
+	for i, step := range p.steps {
+		waiting[i] = len(step.Inputs)
+		for _, input := range step.Inputs {
+			users[p.index[input]] = append(users[p.index[input]], i)
+		}
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	removed := 0
+	for len(ready) > 0 {
+		i := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		removed++
+		for _, user := range users[i] {
+			if waiting[user]--; waiting[user] == 0 {
+				ready = append(ready, user)
+			}
+		}
+	}
+
+	if removed == len(p.steps) {
+		return nil
+	}
+
+	for i, n := range waiting {
+		if n > 0 {
+			return stepInvalid(i, "inputs", "step %s depends on itself", p.steps[i].Name)
+		}
+	}
+	return nil
+}
+
+// stepInvalid returns an error for a problem with field of the step at index
+func stepInvalid(index int, field string, format string, args ...interface{}) error {
+	return &builder.Error{
+		Kind:  builder.KindInvalidInput,
+		Err:   fmt.Errorf(format, args...),
+		Field: fmt.Sprintf("steps[%d].%s", index, field),
+	}
+}
+
+// stepFailure places an error from the step at index within the request, so
+// that a field such as files[0].data becomes steps[1].files[0].data
+func stepFailure(index int, name string, err error) error {
+	var be *builder.Error
+	if !errors.As(err, &be) {
+		return &builder.Error{Kind: builder.KindInternal, Err: fmt.Errorf("step %s: %w", name, err), Field: fmt.Sprintf("steps[%d]", index)}
+	}
+
+	field := fmt.Sprintf("steps[%d]", index)
+	if be.Field != "" {
+		field += "." + be.Field
+	}
+	failure := *be
+	failure.Err = fmt.Errorf("step %s: %w", name, be.Err)
+	failure.Field = field
+	return &failure
+}
+
+// run runs every step once the steps it uses have finished, so that steps
+// which don't depend on each other run at the same time.  The first step to
+// fail stops the rest, and is returned.  A result is always returned for each
+// step, in request order
+func (p *pipeline) run(ctx context.Context) (*stepOutput, []*pb.StepResult, error) {
+	ctx, span := tracer.Start(ctx, "runPipeline")
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := time.Now()
+	results := make([]*pb.StepResult, len(p.steps))
+	outputs := make([]*stepOutput, len(p.steps))
+	done := make([]chan struct{}, len(p.steps))
+	for i, step := range p.steps {
+		results[i] = &pb.StepResult{Name: step.Name, Status: pb.FileStatus_UNPROCESSED}
+		done[i] = make(chan struct{})
+	}
+
+	var failure error
+	var failOnce sync.Once
+	var wg sync.WaitGroup
+
+	for i, step := range p.steps {
+		wg.Add(1)
+		go func(i int, step *pb.PipelineStep) {
+			defer wg.Done()
+			defer close(done[i])
+
+			// The outputs of inputs are only read once they're done, and stay
+			// nil when they failed or never ran
+			var inputs []*stepOutput
+			for _, name := range step.Inputs {
+				j := p.index[name]
+				select {
+				case <-done[j]:
+				case <-ctx.Done():
+					return
+				}
+				if outputs[j] == nil {
+					return
+				}
+				inputs = append(inputs, outputs[j])
+			}
+			for _, f := range step.Files {
+				inputs = append(inputs, &stepOutput{file: f})
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			stepStarted := time.Now()
+			results[i].StartedMs = stepStarted.Sub(started).Milliseconds()
+			out, err := runStep(ctx, step, inputs)
+			results[i].DurationMs = time.Since(stepStarted).Milliseconds()
+
+			if err != nil {
+				results[i].Status = pb.FileStatus_FAILED
+				results[i].Message = err.Error()
+				failOnce.Do(func() {
+					failure = stepFailure(i, step.Name, err)
+					cancel()
+				})
+				return
+			}
+
+			results[i].Status = pb.FileStatus_OK
+			results[i].SizeBytes = int64(len(out.file.Data))
+			outputs[i] = out
+		}(i, step)
+	}
+
+	wg.Wait()
+
+	if failure != nil {
+		return nil, results, failure
+	}
+	// Cancelled by the caller before any step failed
+	if err := ctx.Err(); outputs[p.output] == nil && err != nil {
+		return nil, results, err
+	}
+
+	return outputs[p.output], results, nil
+}
+
+// runStep runs a single step on its inputs, which are in the order the step
+// uses them
+func runStep(ctx context.Context, step *pb.PipelineStep, inputs []*stepOutput) (*stepOutput, error) {
+	ctx, span := tracer.Start(ctx, "pipelineStep")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("gedoc.step", step.Name),
+		attribute.String("gedoc.step_kind", step.Kind.String()),
+	)
+
+	name := step.OutputName
+	if name == "" {
+		name = step.Name + ".pdf"
+		if step.Kind == pb.StepKind_RENDER_TEMPLATE {
+			name = step.Name + ".tex"
+		}
+	}
+
+	var data []byte
+	var pages int
+	var err error
+	first := inputs[0]
+
+	// These steps pass on the pages of their first input, which are only
+	// counted once qpdf has checked it when they aren't known, as for files
+	// from the client
+	switch step.Kind {
+	case pb.StepKind_STAMP, pb.StepKind_ENCRYPT, pb.StepKind_OPTIMIZE:
+		if first.pages == 0 {
+			if first, err = checkedInput(ctx, first); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch step.Kind {
+	case pb.StepKind_RENDER_TEMPLATE:
+		data, err = renderTemplate(first.file, step.TemplateData)
+	case pb.StepKind_BUILD_LATEX:
+		files := make([]*pb.File, len(inputs))
+		for i, input := range inputs {
+			files[i] = input.file
+		}
+		data, pages, _, err = cachedLatexPDF(ctx, files, false)
+	case pb.StepKind_CONVERT_IMAGE:
+		data, err = newBuilder().ImageToPDF(ctx, first.file.Data)
+		pages = 1
+	case pb.StepKind_CONVERT_OFFICE:
+		data, err = officeToPDF(ctx, first.file)
+	case pb.StepKind_MERGE_PDFS:
+		files := make([]*pb.File, len(inputs))
+		for i, input := range inputs {
+			files[i] = input.file
+		}
+		var result *builder.MergeResult
+		result, err = newBuilder().Merge(ctx, builderFiles(files), builder.MergeOptions{ForceEven: step.ForceEven})
+		data, pages = result.Data, result.Pages
+	case pb.StepKind_STAMP:
+		data, err = stampPDF(ctx, first.file.Data, inputs[1].file.Data, step.Underlay)
+		pages = first.pages
+	case pb.StepKind_ENCRYPT:
+		data, err = encryptPDF(ctx, first.file.Data, step.UserPassword, step.OwnerPassword)
+		pages = first.pages
+	case pb.StepKind_OPTIMIZE:
+		data, _, err = optimizePDF(ctx, first.file.Data, step.Optimize)
+		pages = first.pages
+	default:
+		err = invalidInput("unknown step kind %s", step.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stepOutput{file: &pb.File{Name: name, Data: data}, pages: pages}, nil
+}
+
+// checkedInput returns input with its PDF checked, and repaired if needed, by
+// preflight, along with its page count
+func checkedInput(ctx context.Context, input *stepOutput) (*stepOutput, error) {
+	data, pages, _, err := newBuilder().Preflight(ctx, builder.File{Name: input.file.Name, Data: input.file.Data}, builder.MergeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &stepOutput{file: &pb.File{Name: input.file.Name, Data: data}, pages: pages}, nil
+}
+
+// latexEscaper escapes the characters LaTeX treats specially
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`%`, `\%`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// renderTemplate executes the template in f against data, a JSON document.
+// Templates use [[ ]] as delimiters, since braces are everywhere in LaTeX, and
+// escape values with the latex function
+func renderTemplate(f *pb.File, data string) ([]byte, error) {
+	var values interface{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &values); err != nil {
+			return nil, invalidInput("invalid template data: %v", err)
+		}
+	}
+
+	tmpl, err := template.New(f.Name).
+		Delims("[[", "]]").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"latex": func(v interface{}) string { return latexEscaper.Replace(fmt.Sprint(v)) },
+		}).
+		Parse(string(f.Data))
+	if err != nil {
+		return nil, invalidInput("invalid template %s: %v", f.Name, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return nil, invalidInput("executing template %s: %v", f.Name, err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// officeToPDF converts the document in f with LibreOffice
+func officeToPDF(ctx context.Context, f *pb.File) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "officeToPDF")
+	defer span.End()
+
+	name := filepath.Base(f.Name)
+	if !outputNamePattern.MatchString(name) || filepath.Ext(name) == "" {
+		return nil, invalidInput("office document name %q must be a file name with an extension", f.Name)
+	}
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("officeToPDF")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, name), f.Data, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// Conversions share the image pool, being similarly heavy
+	release, err := sched.acquire(ctx, poolImage)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// LibreOffice parses untrusted documents, so is confined like TeX
+	run := b.RunTool
+	if cfg.Sandbox {
+		run = runSandboxed
+	}
+
+	// Each conversion gets its own profile, as LibreOffice refuses to run
+	// twice with the same one
+	output, err := run(ctx, directory, "soffice",
+		"-env:UserInstallation=file://"+filepath.Join(directory, "profile"),
+		"--headless",
+		"--norestore",
+		"--convert-to", "pdf",
+		"--outdir", "out",
+		name,
+	)
+	if err != nil {
+		if cfg.Sandbox {
+			if violation := sandboxViolation(output); violation != nil {
+				return nil, violation
+			}
+		}
+		return nil, fmt.Errorf("converting %s: %w: %s", f.Name, err, output)
+	}
+
+	pdf, err := ioutil.ReadFile(filepath.Join(directory, "out", strings.TrimSuffix(name, filepath.Ext(name))+".pdf"))
+	if os.IsNotExist(err) {
+		// soffice exits successfully even when it can't read the document
+		return nil, &builder.Error{Kind: builder.KindUnsupportedFile, Err: fmt.Errorf("%s could not be converted", f.Name)}
+	}
+	return pdf, err
+}
+
+// stampPDF places the first page of stamp over every page of pdf, or beneath
+// it when underlay is set
+func stampPDF(ctx context.Context, pdf []byte, stamp []byte, underlay bool) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "stampPDF")
+	defer span.End()
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("stampPDF")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	for name, data := range map[string][]byte{"input.pdf": pdf, "stamp.pdf": stamp} {
+		if err := ioutil.WriteFile(filepath.Join(directory, name), data, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	layer := "--overlay"
+	if underlay {
+		layer = "--underlay"
+	}
+
+	args := append([]string{"--warning-exit-0"}, builder.DeterministicIDArgs(ctx)...)
+	args = append(args, "input.pdf", layer, "stamp.pdf", "--repeat=1", "--", "stamped.pdf")
+	output, err := b.RunTool(ctx, directory, "qpdf", args...)
+	if err != nil {
+		return nil, fmt.Errorf("stamping pdf: %w: %s", err, output)
+	}
+
+	return ioutil.ReadFile(filepath.Join(directory, "stamped.pdf"))
+}
+
+// encryptPDF encrypts pdf with AES-256.  The owner password defaults to the
+// user password, so that the PDF can't be changed without one
+func encryptPDF(ctx context.Context, pdf []byte, userPassword string, ownerPassword string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "encryptPDF")
+	defer span.End()
+
+	if ownerPassword == "" {
+		ownerPassword = userPassword
+	}
+	if ownerPassword == "" {
+		return nil, invalidInput("encrypting needs a user or owner password")
+	}
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("encryptPDF")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), pdf, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// qpdf reads its arguments from a file readable only by the server, as
+	// passwords on the command line are visible to every user on the host.
+	// It reads one argument per line, so passwords can't contain line breaks.
+	// qpdf can't give encrypted files a deterministic ID, so encrypted output
+	// differs between runs even when reproducible
+	if strings.ContainsAny(userPassword+ownerPassword, "\r\n") {
+		return nil, invalidInput("passwords must not contain line breaks")
+	}
+	args := strings.Join([]string{
+		"--warning-exit-0",
+		"--encrypt",
+		"--user-password=" + userPassword,
+		"--owner-password=" + ownerPassword,
+		"--bits=256",
+		"--",
+		"input.pdf",
+		"encrypted.pdf",
+	}, "\n") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(directory, "qpdf.args"), []byte(args), 0600); err != nil {
+		return nil, err
+	}
+
+	output, err := b.RunTool(ctx, directory, "qpdf", "@qpdf.args")
+	if err != nil {
+		return nil, fmt.Errorf("encrypting pdf: %w: %s", err, output)
+	}
+
+	return ioutil.ReadFile(filepath.Join(directory, "encrypted.pdf"))
+}
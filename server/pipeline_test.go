@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/grpc/codes"
+)
+
+// TestValidatePipeline Checks graphs that can't be run are rejected, and
+// identify the offending step
+func TestValidatePipeline(t *testing.T) {
+	previous := cfg
+	cfg.PipelineMaxSteps = 4
+	defer func() { cfg = previous }()
+
+	file := func(name string) []*pb.File { return []*pb.File{{Name: name, Data: []byte("data")}} }
+	step := func(name string, kind pb.StepKind, inputs ...string) *pb.PipelineStep {
+		return &pb.PipelineStep{Name: name, Kind: kind, Inputs: inputs}
+	}
+	withFiles := func(s *pb.PipelineStep, files []*pb.File) *pb.PipelineStep {
+		s.Files = files
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		in     *pb.PipelineRequest
+		field  string
+		output int
+	}{
+		{"valid", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("tex", pb.StepKind_RENDER_TEMPLATE), file("letter.tex")),
+			step("letter", pb.StepKind_BUILD_LATEX, "tex"),
+			withFiles(step("logo", pb.StepKind_CONVERT_IMAGE), file("logo.png")),
+			step("final", pb.StepKind_MERGE_PDFS, "letter", "logo"),
+		}}, "", 3},
+		{"forward reference", &pb.PipelineRequest{Output: "letter", Steps: []*pb.PipelineStep{
+			step("letter", pb.StepKind_BUILD_LATEX, "tex"),
+			withFiles(step("tex", pb.StepKind_RENDER_TEMPLATE), file("letter.tex")),
+		}}, "", 0},
+		{"empty", &pb.PipelineRequest{}, "", 0},
+		{"too many", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			step("a", pb.StepKind_OPTIMIZE), step("b", pb.StepKind_OPTIMIZE), step("c", pb.StepKind_OPTIMIZE),
+			step("d", pb.StepKind_OPTIMIZE), step("e", pb.StepKind_OPTIMIZE),
+		}}, "", 0},
+		{"bad name", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("../a", pb.StepKind_OPTIMIZE), file("a.pdf")),
+		}}, "steps[0].name", 0},
+		{"duplicate name", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("a", pb.StepKind_OPTIMIZE), file("a.pdf")),
+			withFiles(step("a", pb.StepKind_OPTIMIZE), file("a.pdf")),
+		}}, "steps[1].name", 0},
+		{"unknown kind", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("a", pb.StepKind_STEP_UNSPECIFIED), file("a.pdf")),
+		}}, "steps[0].kind", 0},
+		{"too few inputs", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("a", pb.StepKind_STAMP), file("a.pdf")),
+		}}, "steps[0].inputs", 0},
+		{"unknown input", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			step("a", pb.StepKind_OPTIMIZE, "missing"),
+		}}, "steps[0].inputs", 0},
+		{"template not built", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("tex", pb.StepKind_RENDER_TEMPLATE), file("letter.tex")),
+			step("a", pb.StepKind_MERGE_PDFS, "tex"),
+		}}, "steps[1].inputs", 0},
+		{"cycle", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("a", pb.StepKind_MERGE_PDFS, "c"), file("a.pdf")),
+			step("b", pb.StepKind_OPTIMIZE, "a"),
+			step("c", pb.StepKind_OPTIMIZE, "b"),
+		}}, "steps[0].inputs", 0},
+		{"bad output name", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			{Name: "a", Kind: pb.StepKind_OPTIMIZE, Files: file("a.pdf"), OutputName: "../a.pdf"},
+		}}, "steps[0].output_name", 0},
+		{"unknown output", &pb.PipelineRequest{Output: "b", Steps: []*pb.PipelineStep{
+			withFiles(step("a", pb.StepKind_OPTIMIZE), file("a.pdf")),
+		}}, "output", 0},
+		{"template output", &pb.PipelineRequest{Steps: []*pb.PipelineStep{
+			withFiles(step("tex", pb.StepKind_RENDER_TEMPLATE), file("letter.tex")),
+		}}, "output", 0},
+	}
+
+	for _, test := range tests {
+		p, err := validatePipeline(test.in)
+		valid := test.name == "valid" || test.name == "forward reference"
+
+		if valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			} else if p.output != test.output {
+				t.Errorf("%s: expected output step %d, got %d", test.name, test.output, p.output)
+			}
+			continue
+		}
+
+		var be *builder.Error
+		if !errors.As(err, &be) || be.Kind != builder.KindInvalidInput {
+			t.Errorf("%s: expected invalid input, got %v", test.name, err)
+			continue
+		}
+		if be.Field != test.field {
+			t.Errorf("%s: expected field %q, got %q", test.name, test.field, be.Field)
+		}
+	}
+}
+
+// TestRenderTemplate Checks templates use [[ ]] delimiters, escape values for
+// LaTeX, and fail on missing data
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		data     string
+		expect   string
+		valid    bool
+	}{
+		{`\textbf{[[ .name | latex ]]}`, `{"name": "Smith & Sons_50%"}`, `\textbf{Smith \& Sons\_50\%}`, true},
+		{`[[ range .items ]]\item [[ latex . ]][[ end ]]`, `{"items": ["a", "{b}"]}`, `\item a\item \{b\}`, true},
+		{`\def\x{[[ latex .path ]]}`, `{"path": "C:\\temp~"}`, `\def\x{C:\textbackslash{}temp\textasciitilde{}}`, true},
+		{`[[ .missing ]]`, `{"name": "a"}`, "", false},
+		{`[[ .name`, `{}`, "", false},
+		{`[[ .name ]]`, `{not json`, "", false},
+	}
+
+	for _, test := range tests {
+		out, err := renderTemplate(&pb.File{Name: "t.tex", Data: []byte(test.template)}, test.data)
+		if !test.valid {
+			if errorCode(err) != errorCode(invalidInput("")) {
+				t.Errorf("%s: expected invalid input, got %v", test.template, err)
+			}
+			continue
+		}
+		if err != nil || string(out) != test.expect {
+			t.Errorf("%s: expected %q, got %q, %v", test.template, test.expect, out, err)
+		}
+	}
+}
+
+// TestRunPipeline Checks steps are given the outputs of the steps they use,
+// and that a failure leaves the steps depending on it unprocessed
+func TestRunPipeline(t *testing.T) {
+	previous := cfg
+	cfg.PipelineMaxSteps = 10
+	cfg.NativeImages = true
+	defer func() { cfg = previous }()
+
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := validatePipeline(&pb.PipelineRequest{Output: "logo", Steps: []*pb.PipelineStep{
+		{Name: "logo", Kind: pb.StepKind_CONVERT_IMAGE, Files: []*pb.File{{Name: "logo.png", Data: logo.Bytes()}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, results, err := p.run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.file.Name != "logo.pdf" || output.pages != 1 || !bytes.HasPrefix(output.file.Data, []byte("%PDF-")) {
+		t.Errorf("Expected 1 page logo.pdf, got %s with %d pages", output.file.Name, output.pages)
+	}
+	if len(results) != 1 || results[0].Status != pb.FileStatus_OK || results[0].SizeBytes != int64(len(output.file.Data)) {
+		t.Errorf("Expected step result for logo, got %v", results)
+	}
+
+	p, err = validatePipeline(&pb.PipelineRequest{Steps: []*pb.PipelineStep{
+		{Name: "notes", Kind: pb.StepKind_CONVERT_IMAGE, Files: []*pb.File{{Name: "notes.txt", Data: []byte("plain text")}}},
+		{Name: "protected", Kind: pb.StepKind_ENCRYPT, Inputs: []string{"notes"}, UserPassword: "secret"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, results, err = p.run(context.Background())
+	var be *builder.Error
+	if !errors.As(err, &be) || be.Kind != builder.KindUnsupportedFile || be.Field != "steps[0]" {
+		t.Fatalf("Expected unsupported file in steps[0], got %v", err)
+	}
+
+	expected := []pb.FileStatus{pb.FileStatus_FAILED, pb.FileStatus_UNPROCESSED}
+	for i, r := range results {
+		if r.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s", r.Name, expected[i], r.Status)
+		}
+	}
+}
+
+// TestEncryptPDF Checks passwords reach qpdf through a file only the server
+// can read, rather than on its command line
+func TestEncryptPDF(t *testing.T) {
+	bin, err := ioutil.TempDir("", "bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+
+	// A stand in for qpdf, recording how it was called
+	script := `#!/bin/sh
+echo "$@" > "$QPDF_RECORD/args"
+cp "${1#@}" "$QPDF_RECORD/argfile"
+stat -c %a "${1#@}" > "$QPDF_RECORD/mode"
+cp input.pdf encrypted.pdf
+`
+	if err := ioutil.WriteFile(filepath.Join(bin, "qpdf"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("QPDF_RECORD", bin)
+
+	pdf, err := encryptPDF(context.Background(), []byte("%PDF-1.5"), "user secret", "owner secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pdf) != "%PDF-1.5" {
+		t.Errorf("Expected qpdf's output, got %q", pdf)
+	}
+
+	record := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(bin, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if args := record("args"); strings.Contains(args, "secret") {
+		t.Errorf("Expected no passwords on the command line, got %s", args)
+	}
+	if argfile := record("argfile"); !strings.Contains(argfile, "--user-password=user secret\n--owner-password=owner secret\n") {
+		t.Errorf("Expected passwords in the argument file, got %s", argfile)
+	}
+	if mode := record("mode"); mode != "600\n" {
+		t.Errorf("Expected argument file readable only by the server, got mode %s", mode)
+	}
+
+	if _, err := encryptPDF(context.Background(), []byte("%PDF-1.5"), "line\nbreak", ""); errorCode(err) != codes.InvalidArgument {
+		t.Errorf("Expected passwords with line breaks to be rejected, got %v", err)
+	}
+}
+
+// TestPipelineUncheckedInput Checks client PDFs passed through unchanged are
+// checked before their pages are counted, so damaged ones fail the request
+// rather than the server
+func TestPipelineUncheckedInput(t *testing.T) {
+	previous := cfg
+	cfg.PipelineMaxSteps = 10
+	defer func() { cfg = previous }()
+
+	damaged := []byte("%PDF-1.5\n1 0 obj\n<< /Type /XRef /Size 2 /W [1 1 1] /Length 9223372036854775807 >>\nstream\nxx\nendstream\nendobj\nstartxref\n9\n%%EOF")
+
+	for _, step := range []*pb.PipelineStep{
+		{Name: "optimized", Kind: pb.StepKind_OPTIMIZE, Optimize: pb.OptimizePreset_NONE},
+		{Name: "protected", Kind: pb.StepKind_ENCRYPT, UserPassword: "secret"},
+	} {
+		step.Files = []*pb.File{{Name: "damaged.pdf", Data: damaged}}
+		reply, err := (&server{}).RunPipeline(context.Background(), &pb.PipelineRequest{Steps: []*pb.PipelineStep{step}})
+		if err == nil || reply.Success {
+			t.Errorf("%s: expected damaged input to fail, got %v", step.Name, reply)
+		}
+	}
+}
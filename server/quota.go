@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"github.com/golang/protobuf/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Limits a request may exceed, used as metric labels
+const (
+	limitRate        = "rate"
+	limitInputBytes  = "input_bytes"
+	limitTexCPU      = "tex_cpu"
+	limitOutputPages = "output_pages"
+)
+
+// quotaLimits The limits applied to one client.  Zero leaves a limit unset
+type quotaLimits struct {
+	RequestsPerSecond  float64 `json:"requests_per_second"`
+	Burst              int     `json:"burst"`
+	DailyInputBytes    int64   `json:"daily_input_bytes"`
+	DailyTexCPUSeconds float64 `json:"daily_tex_cpu_seconds"`
+	DailyOutputPages   int64   `json:"daily_output_pages"`
+}
+
+// quotaConfig The contents of the quotas file.  Clients without their own
+// limits get the defaults
+type quotaConfig struct {
+	Default quotaLimits            `json:"default"`
+	Clients map[string]quotaLimits `json:"clients"`
+}
+
+// clientUsage Tracks one client's request rate and usage for the day
+type clientUsage struct {
+	mu     sync.Mutex
+	limits quotaLimits
+
+	tokens float64
+	filled time.Time
+
+	day         string
+	inputBytes  int64
+	texCPU      float64
+	outputPages int64
+}
+
+// quotaTracker Holds the usage of every client seen
+type quotaTracker struct {
+	config *quotaConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*clientUsage
+}
+
+// texTools are the tools whose CPU time counts towards the TeX quota.  latexmk
+// waits for the TeX engine, so its usage includes the engine's
+var texTools = map[string]bool{"latexmk": true}
+
+// usageKey is the context key for the usage of the client making the request
+type usageKey struct{}
+
+// quotas enforces the configured limits.  It is nil when no quotas file is set
+var quotas *quotaTracker
+
+// loadQuotas reads the quotas file at path
+func loadQuotas(path string) (*quotaTracker, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config quotaConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return newQuotaTracker(&config), nil
+}
+
+func newQuotaTracker(config *quotaConfig) *quotaTracker {
+	return &quotaTracker{
+		config:  config,
+		now:     time.Now,
+		clients: map[string]*clientUsage{},
+	}
+}
+
+// usage returns the usage record for client, creating it on first use
+func (q *quotaTracker) usage(client string) *clientUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u, ok := q.clients[client]
+	if !ok {
+		limits, ok := q.config.Clients[client]
+		if !ok {
+			limits = q.config.Default
+		}
+		u = &clientUsage{limits: limits, tokens: float64(limits.burst()), filled: q.now()}
+		q.clients[client] = u
+	}
+
+	return u
+}
+
+// burst returns how many requests may be made at once.  It is at least one,
+// so that a rate below one per second still admits requests
+func (l quotaLimits) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Max(1, math.Ceil(l.RequestsPerSecond)))
+}
+
+// admit takes a token and charges inputBytes to the day's usage, returning the
+// exceeded limit and how long to wait when the request isn't allowed
+func (u *clientUsage) admit(now time.Time, inputBytes int64) (string, time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.rollover(now)
+	untilTomorrow := nextDay(now).Sub(now)
+
+	if u.limits.DailyTexCPUSeconds > 0 && u.texCPU >= u.limits.DailyTexCPUSeconds {
+		return limitTexCPU, untilTomorrow
+	}
+	if u.limits.DailyOutputPages > 0 && u.outputPages >= u.limits.DailyOutputPages {
+		return limitOutputPages, untilTomorrow
+	}
+	if u.limits.DailyInputBytes > 0 && u.inputBytes+inputBytes > u.limits.DailyInputBytes {
+		return limitInputBytes, untilTomorrow
+	}
+
+	if rate := u.limits.RequestsPerSecond; rate > 0 {
+		burst := float64(u.limits.burst())
+		u.tokens = math.Min(burst, u.tokens+now.Sub(u.filled).Seconds()*rate)
+		u.filled = now
+		if u.tokens < 1 {
+			return limitRate, time.Duration((1 - u.tokens) / rate * float64(time.Second))
+		}
+		u.tokens--
+	}
+
+	u.inputBytes += inputBytes
+	return "", 0
+}
+
+// rollover starts a new day's usage once the day has changed
+func (u *clientUsage) rollover(now time.Time) {
+	day := now.UTC().Format("2006-01-02")
+	if u.day != day {
+		u.day = day
+		u.inputBytes = 0
+		u.texCPU = 0
+		u.outputPages = 0
+	}
+}
+
+// nextDay returns the start of the next UTC day, when daily quotas reset
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// enforceQuotas rejects requests from clients that have exceeded their limits.
+// The usage of admitted requests is charged to the client as it happens
+func enforceQuotas(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if quotas == nil || isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	var size int64
+	if m, ok := req.(proto.Message); ok {
+		size = int64(proto.Size(m))
+	}
+
+	u, err := admit(ctx, size, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, usageKey{}, u), req)
+}
+
+// enforceStreamQuotas applies the rate limit to streaming RPCs
+func enforceStreamQuotas(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if quotas == nil || isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	u, err := admit(ss.Context(), 0, ss.SetHeader)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), usageKey{}, u)})
+}
+
+// admit checks the calling client's limits, returning its usage record.  When
+// a limit has been exceeded, retry-after is sent with setHeader
+func admit(ctx context.Context, inputBytes int64, setHeader func(metadata.MD) error) (*clientUsage, error) {
+	client := clientFromContext(ctx)
+	u := quotas.usage(client)
+
+	limit, wait := u.admit(quotas.now(), inputBytes)
+	if limit == "" {
+		return u, nil
+	}
+
+	quotaRejections.WithLabelValues(client, limit).Inc()
+	log.Warn().Str("client", client).Str("limit", limit).Dur("retry_after", wait).Msg("quota exceeded")
+
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if err := setHeader(metadata.Pairs("retry-after", strconv.FormatInt(seconds, 10))); err != nil {
+		log.Error().Err(err).Msg("setting retry-after")
+	}
+
+	err := &builder.Error{Kind: builder.KindResourceExhausted, Err: fmt.Errorf("%s quota exceeded for %s", limit, client)}
+	return nil, errorStatus(err, &errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}).Err()
+}
+
+// chargeTexCPU adds the CPU time of a TeX tool to the usage of the client
+// making the request
+func chargeTexCPU(ctx context.Context, tool string, state *os.ProcessState) {
+	u, ok := ctx.Value(usageKey{}).(*clientUsage)
+	if !ok || state == nil || !texTools[tool] {
+		return
+	}
+
+	u.mu.Lock()
+	u.texCPU += (state.UserTime() + state.SystemTime()).Seconds()
+	u.mu.Unlock()
+}
+
+// chargeInputBytes adds the size of inputs the server fetched itself to the
+// usage of the client making the request.  They aren't part of the request
+// size charged when it was admitted
+func chargeInputBytes(ctx context.Context, n int64) {
+	u, ok := ctx.Value(usageKey{}).(*clientUsage)
+	if !ok {
+		return
+	}
+
+	u.mu.Lock()
+	u.inputBytes += n
+	u.mu.Unlock()
+}
+
+// chargeOutputPages adds pages returned to the usage of the client making the
+// request
+func chargeOutputPages(ctx context.Context, pages int) {
+	u, ok := ctx.Value(usageKey{}).(*clientUsage)
+	if !ok {
+		return
+	}
+
+	u.mu.Lock()
+	u.outputPages += int64(pages)
+	u.mu.Unlock()
+}
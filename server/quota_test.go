@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestRateLimit Checks requests beyond the burst wait for the bucket to refill
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	u := &clientUsage{limits: quotaLimits{RequestsPerSecond: 2, Burst: 2}, tokens: 2, filled: now}
+
+	for i := 0; i < 2; i++ {
+		if limit, _ := u.admit(now, 0); limit != "" {
+			t.Fatalf("Expected request %d within burst to be admitted, got %s", i, limit)
+		}
+	}
+
+	limit, wait := u.admit(now, 0)
+	if limit != limitRate || wait != 500*time.Millisecond {
+		t.Errorf("Expected rate limit with 500ms wait, got %q after %s", limit, wait)
+	}
+
+	if limit, _ := u.admit(now.Add(500*time.Millisecond), 0); limit != "" {
+		t.Errorf("Expected request to be admitted once refilled, got %s", limit)
+	}
+}
+
+// TestDailyQuotas Checks daily usage is limited until the next UTC day
+func TestDailyQuotas(t *testing.T) {
+	now := time.Date(2026, 1, 2, 18, 0, 0, 0, time.UTC)
+	u := &clientUsage{limits: quotaLimits{DailyInputBytes: 100, DailyOutputPages: 10, DailyTexCPUSeconds: 60}}
+
+	if limit, _ := u.admit(now, 80); limit != "" {
+		t.Fatalf("Expected first request to be admitted, got %s", limit)
+	}
+
+	limit, wait := u.admit(now, 30)
+	if limit != limitInputBytes || wait != 6*time.Hour {
+		t.Errorf("Expected input bytes limit until midnight, got %q after %s", limit, wait)
+	}
+
+	u.outputPages = 10
+	if limit, _ := u.admit(now, 0); limit != limitOutputPages {
+		t.Errorf("Expected output pages limit, got %q", limit)
+	}
+
+	u.outputPages = 0
+	u.texCPU = 61
+	if limit, _ := u.admit(now, 0); limit != limitTexCPU {
+		t.Errorf("Expected tex cpu limit, got %q", limit)
+	}
+
+	if limit, _ := u.admit(now.Add(7*time.Hour), 80); limit != "" {
+		t.Errorf("Expected usage to reset the next day, got %s", limit)
+	}
+}
+
+// TestEnforceQuotas Checks clients get their own limits, and rejections carry
+// retry details
+func TestEnforceQuotas(t *testing.T) {
+	quotas = newQuotaTracker(&quotaConfig{
+		Default: quotaLimits{RequestsPerSecond: 1},
+		Clients: map[string]quotaLimits{"billing": {RequestsPerSecond: 100}},
+	})
+	defer func() { quotas = nil }()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/builder.Builder/Merge"}
+	billing := context.WithValue(context.Background(), clientKey{}, "billing")
+
+	for i := 0; i < 5; i++ {
+		if _, err := enforceQuotas(billing, nil, info, handler); err != nil {
+			t.Fatalf("Expected billing's own limit to apply, got %v", err)
+		}
+	}
+
+	if _, err := enforceQuotas(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := enforceQuotas(context.Background(), nil, info, handler)
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %s", st.Code())
+	}
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+		t.Errorf("Expected retry info, got %v", st.Details())
+	}
+}
+
+// TestStreamInterceptors Drives a stream through authentication and quotas,
+// checking the client identified is the one charged and seen by the handler
+func TestStreamInterceptors(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	apiKeys = map[string]string{hex.EncodeToString(sum[:]): "billing"}
+	defer func() { apiKeys = nil }()
+
+	quotas = newQuotaTracker(&quotaConfig{
+		Default: quotaLimits{RequestsPerSecond: 100, Burst: 100},
+		Clients: map[string]quotaLimits{"billing": {RequestsPerSecond: 1, Burst: 1}},
+	})
+	defer func() { quotas = nil }()
+
+	var client string
+	var charged bool
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		client = clientFromContext(ss.Context())
+		_, charged = ss.Context().Value(usageKey{}).(*clientUsage)
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"}
+	chained := func(srv interface{}, ss grpc.ServerStream) error {
+		return enforceStreamQuotas(srv, ss, info, handler)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+
+	if err := authenticateStream(nil, &testStream{ctx: ctx}, info, chained); err != nil {
+		t.Fatal(err)
+	}
+	if client != "billing" || !charged {
+		t.Errorf("Expected billing to reach the handler with its usage, got %q, %v", client, charged)
+	}
+
+	// Only billing's own limit of one request a second stops a second stream
+	err := authenticateStream(nil, &testStream{ctx: ctx}, info, chained)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected billing's limit to apply, got %v", err)
+	}
+}
+
+// testStream A ServerStream carrying ctx, which discards headers
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testStream) SetHeader(metadata.MD) error {
+	return nil
+}
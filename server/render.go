@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultRenderDPI = 150
+	maxRenderDPI     = 600
+)
+
+// renderPDF rasterises the requested pages of pdf with Ghostscript, returning
+// one image per page.  All pages are rendered when pages is empty
+func renderPDF(ctx context.Context, pdf []byte, pages []int32, opts *pb.RenderOptions) ([]*pb.Image, error) {
+	ctx, span := tracer.Start(ctx, "renderPDF")
+	defer span.End()
+
+	if opts == nil {
+		opts = &pb.RenderOptions{}
+	}
+
+	dpi := int(opts.Dpi)
+	if dpi == 0 {
+		dpi = defaultRenderDPI
+	}
+	if dpi < 0 || dpi > maxRenderDPI {
+		return nil, invalidInput("dpi must be between 1 and %d", maxRenderDPI)
+	}
+	if opts.MaxDimension < 0 {
+		return nil, invalidInput("max dimension must not be negative")
+	}
+	if len(pdf) == 0 {
+		return nil, invalidInput("must provide a pdf to render")
+	}
+
+	pageNumbers, err := normalisePages(pages)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := sched.acquire(ctx, poolRender)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("renderPDF")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), pdf, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-dSAFER",
+		"-dBATCH",
+		"-dNOPAUSE",
+		"-dQUIET",
+		"-sDEVICE=png16m",
+		"-dTextAlphaBits=4",
+		"-dGraphicsAlphaBits=4",
+		fmt.Sprintf("-r%d", dpi),
+	}
+	if len(pageNumbers) > 0 {
+		var list []string
+		for _, p := range pageNumbers {
+			list = append(list, strconv.Itoa(p))
+		}
+		args = append(args, "-sPageList="+strings.Join(list, ","))
+	}
+	args = append(args, "-sOutputFile=page-%d.png", "input.pdf")
+
+	output, err := b.RunTool(ctx, directory, "gs", args...)
+	if err != nil {
+		return nil, fmt.Errorf("rendering pdf: %w: %s", err, output)
+	}
+
+	rendered, err := filepath.Glob(filepath.Join(directory, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	// Ghostscript numbers output files sequentially from 1, so sort numerically
+	// to line them up with the requested pages
+	sort.Slice(rendered, func(i, j int) bool {
+		return outputIndex(rendered[i]) < outputIndex(rendered[j])
+	})
+
+	if len(pageNumbers) > 0 && len(rendered) != len(pageNumbers) {
+		return nil, invalidInput("requested %d pages but rendered %d, check that all pages exist", len(pageNumbers), len(rendered))
+	}
+
+	var images []*pb.Image
+	for i, r := range rendered {
+		page := i + 1
+		if len(pageNumbers) > 0 {
+			page = pageNumbers[i]
+		}
+
+		image, err := finishImage(ctx, b, directory, filepath.Base(r), opts)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page, err)
+		}
+		image.Page = int32(page)
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// finishImage resizes and converts a rendered page as required by opts
+func finishImage(ctx context.Context, b *builder.Builder, directory string, name string, opts *pb.RenderOptions) (*pb.Image, error) {
+	image := &pb.Image{MimeType: "image/png"}
+	result := name
+
+	if opts.MaxDimension > 0 || opts.Format == pb.ImageFormat_JPEG {
+		args := []string{name}
+		if opts.MaxDimension > 0 {
+			// The trailing > only ever shrinks images
+			args = append(args, "-resize", fmt.Sprintf("%dx%d>", opts.MaxDimension, opts.MaxDimension))
+		}
+		if opts.Format == pb.ImageFormat_JPEG {
+			result = strings.TrimSuffix(name, ".png") + ".jpg"
+			image.MimeType = "image/jpeg"
+			args = append(args, "-quality", "85")
+		} else {
+			result = "resized-" + name
+		}
+		args = append(args, result)
+
+		output, err := b.RunTool(ctx, directory, "convert", args...)
+		if err != nil {
+			return nil, fmt.Errorf("converting image: %w: %s", err, output)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(directory, result))
+	if err != nil {
+		return nil, err
+	}
+	image.Data = data
+
+	return image, nil
+}
+
+// normalisePages sorts and removes duplicates from the requested pages
+func normalisePages(pages []int32) ([]int, error) {
+	seen := make(map[int]bool)
+	var result []int
+	for _, p := range pages {
+		if p < 1 {
+			return nil, invalidInput("page numbers start at 1, got %d", p)
+		}
+		if !seen[int(p)] {
+			seen[int(p)] = true
+			result = append(result, int(p))
+		}
+	}
+	sort.Ints(result)
+
+	return result, nil
+}
+
+// outputIndex returns the sequence number from a page-%d.png file name
+func outputIndex(path string) int {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "page-"), ".png")
+	i, _ := strconv.Atoi(name)
+	return i
+}
+
+// attachPreview renders the first page of reply's document as its preview.
+// Failing to render a preview doesn't fail the request, so errors are noted
+// but otherwise ignored
+func attachPreview(ctx context.Context, reply *pb.FileReply, opts *pb.RenderOptions) {
+	if opts == nil || !reply.Success {
+		return
+	}
+
+	images, err := renderPDF(ctx, reply.Data, []int32{1}, opts)
+	if err != nil || len(images) == 0 {
+		log.Warn().Err(err).Msg("rendering preview")
+		return
+	}
+
+	reply.Preview = images[0]
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withReproducible marks ctx as belonging to a reproducible request when opts
+// enables it, so that the tools it runs give byte-identical output
+func withReproducible(ctx context.Context, opts *pb.ReproducibleOptions) context.Context {
+	if opts == nil || !opts.Enabled {
+		return ctx
+	}
+
+	epoch := opts.SourceDateEpoch
+	if epoch == 0 {
+		epoch = cfg.ReproducibleEpoch
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("gedoc.source_date_epoch", epoch))
+	return builder.WithSourceDateEpoch(ctx, epoch)
+}
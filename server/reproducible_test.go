@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+)
+
+// TestReproducibleEnv Checks tools run for reproducible requests get a fixed
+// date, from the request or the config
+func TestReproducibleEnv(t *testing.T) {
+	previous := cfg
+	cfg.ReproducibleEpoch = 1700000000
+	defer func() { cfg = previous }()
+
+	tests := []struct {
+		name string
+		opts *pb.ReproducibleOptions
+		want string
+	}{
+		{"unset", nil, ","},
+		{"disabled", &pb.ReproducibleOptions{SourceDateEpoch: 1}, ","},
+		{"default", &pb.ReproducibleOptions{Enabled: true}, "1700000000,1"},
+		{"request", &pb.ReproducibleOptions{Enabled: true, SourceDateEpoch: 1234}, "1234,1"},
+	}
+
+	for _, test := range tests {
+		ctx := withReproducible(context.Background(), test.opts)
+		output, err := newBuilder().ToolOutput(ctx, "", "sh", "-c", `echo "$SOURCE_DATE_EPOCH,$FORCE_SOURCE_DATE"`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(output)); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+
+		if args := builder.DeterministicIDArgs(ctx); (len(args) > 0) != (test.want != ",") {
+			t.Errorf("%s: unexpected qpdf arguments %v", test.name, args)
+		}
+	}
+}
@@ -1,32 +1,26 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
-	"google.golang.org/grpc"
-
-	pb "github.com/episub/gedoc/gedoc/lib"
 	"github.com/go-chi/chi"
-	grpcOpentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing-contrib/go-stdlib/nethttp"
-	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
-)
-
-const (
-	address = "localhost:50051"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // startRouters Starts each of the external and internal routers
-func startRouters(tracer opentracing.Tracer) {
+func startRouters() {
 	log.Info().Msg("Starting routers")
-	internalRouter := newRouter(tracer)
+	internalRouter := newRouter()
 	internalRouter.Get("/health", healthHandler)
 	internalRouter.Get("/live", liveHandler)
+	internalRouter.Get("/selftest", selfTestResultHandler)
+	internalRouter.Post("/selftest", selfTestRunHandler)
 	internalRouter.Handle("/metrics", promhttp.Handler())
 
 	log.Info().Int("internal_port", cfg.InternalPort).Int("external_port", cfg.ExternalPort).Msg("listening on ports")
@@ -37,36 +31,30 @@ func startRouters(tracer opentracing.Tracer) {
 }
 
 // newRouter returns a new router with all default values set
-func newRouter(tracer opentracing.Tracer) chi.Router {
+func newRouter() chi.Router {
 	router := chi.NewRouter()
-	router.Use(Opentracing(tracer))
+	router.Use(Tracing)
 
 	return router
 }
 
-// Opentracing Adds opentracing to context
-func Opentracing(tracer opentracing.Tracer) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return nethttp.Middleware(tracer, next)
-	}
+// Tracing Starts a span for each request, continuing any trace the caller
+// propagated
+func Tracing(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "internal")
 }
 
-// liveHandler Returns true when the service is live and ready to receive requests
-// Works by acting as a client, and actually performing a request
+// liveHandler Returns OK once the gRPC server is accepting connections.  It
+// doesn't depend on readiness, so that a busy or degraded server isn't
+// restarted
 func liveHandler(w http.ResponseWriter, r *http.Request) {
-	span, _ := opentracing.StartSpanFromContext(r.Context(), "liveHandler")
-	defer span.Finish()
+	_, span := tracer.Start(r.Context(), "liveHandler")
+	defer span.End()
 
-	log.Info().Msg("liveness request received")
-	healthy, err := checkGRPCHealth(opentracing.ContextWithSpan(r.Context(), span))
-
-	if err != nil {
-		log.Warn().Bool("healthy", healthy).Err(err).Msg("liveness report encountered error")
-	}
+	isLive := atomic.LoadInt32(&live) == 1
+	log.Debug().Bool("live", isLive).Msg("liveness request received")
 
-	log.Info().Bool("healthy", healthy).Msg("health reply")
-
-	if !healthy {
+	if !isLive {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		return
 	}
@@ -74,79 +62,61 @@ func liveHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// healthHandler Returns true when the service is live and ready to receive requests
-// Works by acting as a client, and actually performing a request
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	span, _ := opentracing.StartSpanFromContext(r.Context(), "healthHandler")
-	defer span.Finish()
-
-	log.Printf("Health request received")
-	healthy, err := checkGRPCHealth(opentracing.ContextWithSpan(r.Context(), span))
+// healthReply The body returned by healthHandler
+type healthReply struct {
+	Ready   bool              `json:"ready"`
+	Checks  map[string]string `json:"checks"`
+	Checked time.Time         `json:"checked"`
+}
 
-	if err != nil {
-		log.Warn().Bool("healthy", healthy).Err(err).Msg("liveness report encountered error")
-	}
+// healthHandler Returns OK when the service is ready to receive requests,
+// reporting the outcome of each readiness check
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "healthHandler")
+	defer span.End()
 
-	log.Info().Bool("healthy", healthy).Msg("health reply")
+	ready, checks, checked := readiness.snapshot()
+	log.Debug().Bool("ready", ready).Msg("health request received")
 
-	if !healthy {
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
 		w.WriteHeader(http.StatusServiceUnavailable)
-		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
-
-func checkGRPCHealth(ctx context.Context) (bool, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "checkGRPCHealth")
-	defer span.Finish()
-
-	// Set up a local grpc client so that server can query itself for liveness.  This is a better simulation, to ensure that the grpc server is still receiving at least some requests
-	//conn, err := grpc.Dial(address, grpc.WithInsecure())
-	conn, err := createClientGRPCConn(opentracing.ContextWithSpan(ctx, span), address)
+	err := json.NewEncoder(w).Encode(healthReply{Ready: ready, Checks: checks, Checked: checked})
 	if err != nil {
-		return false, err
+		log.Error().Err(err).Msg("writing health reply")
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			log.Fatal().Err(err).Msg("closing grpc client connection")
-		}
-	}(conn)
-
-	c := pb.NewBuilderClient(conn)
-
-	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
-	r, err := c.Health(opentracing.ContextWithSpan(ctx, span), &pb.HealthRequest{})
+}
 
-	if err != nil {
-		return false, err
+// selfTestResultHandler Returns the result of the most recent self test
+func selfTestResultHandler(w http.ResponseWriter, r *http.Request) {
+	result := selfTest.last()
+	if result == nil {
+		http.Error(w, "self test has not run", http.StatusNotFound)
+		return
 	}
 
-	return r.Healthy, nil
+	writeSelfTestResult(w, result)
 }
 
-// ctx is the incoming gRPC request's context
-// addr is the address for the new outbound request
-func createClientGRPCConn(ctx context.Context, addr string) (*grpc.ClientConn, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "createGRPCConn")
-	defer span.Finish()
-
-	var opts []grpc.DialOption
+// selfTestRunHandler Runs the self test, returning its result once complete
+func selfTestRunHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "selfTestRunHandler")
+	defer span.End()
 
-	opts = append(opts, grpc.WithStreamInterceptor(grpcOpentracing.StreamClientInterceptor()))
-	opts = append(opts, grpc.WithUnaryInterceptor(grpcOpentracing.UnaryClientInterceptor()))
+	log.Info().Msg("self test requested")
+	writeSelfTestResult(w, runSelfTest(ctx))
+}
 
-	opts = append(opts, grpc.WithInsecure())
-	if !cfg.Debug {
-		log.Warn().Msg("using grpc in insecure mode")
+func writeSelfTestResult(w http.ResponseWriter, result *selfTestResult) {
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Passed {
+		w.WriteHeader(http.StatusInternalServerError)
 	}
 
-	conn, err := grpc.DialContext(ctx, addr, opts...)
+	err := json.NewEncoder(w).Encode(result)
 	if err != nil {
-		log.Error().Err(err).Msg("connection to application address")
-		return nil, err
+		log.Error().Err(err).Msg("writing self test result")
 	}
-	return conn, nil
 }
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// sandboxArg makes the server binary act as the sandbox helper, which applies
+// resource limits before running a tool.  Go can't set rlimits on a child
+// process directly, so the helper sets them on itself for the tool to inherit.
+// Processes are counted per user rather than per tool by RLIMIT_NPROC, so they
+// are limited by a cgroup instead
+const sandboxArg = "__gedoc_sandbox"
+
+// sandboxCPUGrace is how long past its CPU limit a tool may run after being
+// sent SIGXCPU, before the kernel kills it outright
+const sandboxCPUGrace = 5
+
+// Violations reported by the sandbox
+const (
+	violationFileAccess = "file_access"
+	violationCPU        = "cpu"
+	violationMemory     = "memory"
+	violationFileSize   = "file_size"
+	violationProcesses  = "processes"
+)
+
+var (
+	// sandboxHelper is set when the binary was run as the sandbox helper.  It
+	// is a variable so that it runs before any init functions, which may start
+	// servers in tests
+	sandboxHelper = runSandboxHelper()
+
+	// sandboxExecutable is the binary re-run as the sandbox helper
+	sandboxExecutable, sandboxExecutableErr = os.Executable()
+
+	// sandboxLimitPattern matches the helper's report of a limit being hit
+	sandboxLimitPattern = regexp.MustCompile(`gedoc sandbox: (\w+) limit exceeded`)
+
+	// sandboxViolationPatterns match how other violations show in tool output
+	// and TeX logs
+	sandboxViolationPatterns = []struct {
+		violation string
+		pattern   *regexp.Regexp
+	}{
+		{violationFileAccess, regexp.MustCompile(`Not (reading from|writing to) .* \(open(in|out)_any = p\)`)},
+		{violationCPU, regexp.MustCompile(`CPU time limit exceeded`)},
+		{violationFileSize, regexp.MustCompile(`File size limit exceeded`)},
+		{violationMemory, regexp.MustCompile(`(?i)memory exhausted|cannot allocate memory|out of memory`)},
+		{violationProcesses, regexp.MustCompile(`(?i)fork: resource temporarily unavailable|cannot fork`)},
+	}
+)
+
+// sandboxNamespaces are the fresh namespaces sandboxed tools run in, leaving
+// them without network access or sight of other processes, and with their own
+// view of the filesystem
+const sandboxNamespaces = syscall.CLONE_NEWUSER |
+	syscall.CLONE_NEWNS |
+	syscall.CLONE_NEWNET |
+	syscall.CLONE_NEWPID |
+	syscall.CLONE_NEWIPC |
+	syscall.CLONE_NEWUTS
+
+// runSandboxed runs an external tool like Builder.RunTool, inside the sandbox
+func runSandboxed(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	return builder.Run(ctx, toolRunner{prepare: sandboxCommand}, builder.Command(ctx, dir, name, args...))
+}
+
+// sandboxCommand wraps cmd to run under the sandbox helper, in fresh
+// namespaces and its own cgroup.  The tool sees only the read only paths and
+// its working directory, and TeX's file access is further restricted to the
+// working directory.  It returns a function that removes what was created for
+// the tool, to call once cmd has exited
+func sandboxCommand(cmd *exec.Cmd) func() {
+	if cmd.Err != nil {
+		return nil
+	}
+	if sandboxExecutableErr != nil {
+		cmd.Err = fmt.Errorf("locating sandbox helper: %w", sandboxExecutableErr)
+		return nil
+	}
+
+	// The helper mounts the tool's filesystem on root, in its own namespace
+	root, err := ioutil.TempDir("", "sandbox-root")
+	if err != nil {
+		cmd.Err = fmt.Errorf("creating sandbox root: %w", err)
+		return nil
+	}
+
+	cgroup, err := newSandboxCgroup()
+	if err != nil {
+		os.Remove(root)
+		cmd.Err = fmt.Errorf("creating sandbox cgroup: %w", err)
+		return nil
+	}
+
+	cmd.Args = append(sandboxHelperArgs(root, cmd.Dir, cmd.Path), cmd.Args[1:]...)
+	cmd.Path = sandboxExecutable
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// Home is the only place tools can write besides /tmp, so caches they keep
+	// there go in the working directory
+	home := cmd.Dir
+	if home == "" {
+		home = "/tmp"
+	}
+	cmd.Env = append(cmd.Env, "openin_any=p", "openout_any=p", "shell_escape=f", "HOME="+home)
+
+	uid, gid := os.Getuid(), os.Getgid()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  sandboxNamespaces,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	if cgroup == nil {
+		return func() { os.Remove(root) }
+	}
+
+	// The helper starts in the cgroup, so everything it runs is counted
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+	return func() {
+		removeSandboxCgroup(cgroup)
+		os.Remove(root)
+	}
+}
+
+// newSandboxCgroup creates a cgroup beneath SANDBOX_CGROUP limited to
+// SANDBOX_PROCESSES processes, returning it open.  It returns nil when
+// processes aren't limited
+func newSandboxCgroup() (*os.File, error) {
+	if cfg.SandboxCgroup == "" || cfg.SandboxProcesses == 0 {
+		return nil, nil
+	}
+
+	dir, err := ioutil.TempDir(cfg.SandboxCgroup, "gedoc-")
+	if err != nil {
+		return nil, err
+	}
+
+	limit := strconv.FormatUint(cfg.SandboxProcesses, 10)
+	if err := ioutil.WriteFile(filepath.Join(dir, "pids.max"), []byte(limit), 0); err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+
+	cgroup, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	return cgroup, nil
+}
+
+// removeSandboxCgroup kills anything left in cgroup and removes it.  Processes
+// may take a moment to leave once killed, so removal is retried briefly
+func removeSandboxCgroup(cgroup *os.File) {
+	defer cgroup.Close()
+	dir := cgroup.Name()
+
+	// cgroup.kill needs Linux 5.14, and otherwise the PID namespace's
+	// processes were already killed when the helper exited
+	ioutil.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Warn().Err(err).Str("cgroup", dir).Msg("removing sandbox cgroup")
+}
+
+// sandboxHelperArgs returns the command line that runs tool under the sandbox
+// helper with the configured limits.  When root is set, the helper first
+// confines the tool to a filesystem mounted there, holding the read only
+// paths and dir, writable.  Helpers already inside the sandbox leave it unset
+func sandboxHelperArgs(root, dir, tool string) []string {
+	var paths string
+	if root != "" {
+		// Tools run TeX through the helper, so it must be visible too
+		paths = strings.Join(append(cfg.SandboxReadOnlyPaths, sandboxExecutable), string(os.PathListSeparator))
+	}
+
+	return []string{
+		sandboxExecutable,
+		sandboxArg,
+		strconv.FormatUint(cfg.SandboxCPUSeconds, 10),
+		strconv.FormatUint(cfg.SandboxMemoryBytes, 10),
+		strconv.FormatUint(cfg.SandboxFileSizeBytes, 10),
+		root,
+		dir,
+		paths,
+		tool,
+	}
+}
+
+// runSandboxHelper runs a tool with resource limits and exits, if the binary
+// was started as the sandbox helper.  Otherwise it returns false
+func runSandboxHelper() bool {
+	if len(os.Args) < 2 || os.Args[1] != sandboxArg {
+		return false
+	}
+
+	os.Exit(sandboxRun(os.Args[2:]))
+	return true
+}
+
+// sandboxRun applies the limits in args and confines the filesystem, then
+// runs the tool that follows them, returning its exit code.  Limits the tool
+// is killed for are reported on standard error, where they can be found in its
+// output
+func sandboxRun(args []string) int {
+	if len(args) < 7 {
+		fmt.Fprintln(os.Stderr, "gedoc sandbox: usage: cpu memory file_size root dir paths tool [args...]")
+		return 2
+	}
+
+	var limits [3]uint64
+	for i := range limits {
+		n, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gedoc sandbox: invalid limit %q\n", args[i])
+			return 2
+		}
+		limits[i] = n
+	}
+	cpu, memory, fileSize := limits[0], limits[1], limits[2]
+	root, dir, paths, tool := args[3], args[4], args[5], args[6]
+
+	if root != "" {
+		if err := sandboxFilesystem(root, dir, filepath.SplitList(paths), fileSize); err != nil {
+			fmt.Fprintf(os.Stderr, "gedoc sandbox: confining filesystem: %v\n", err)
+			return 2
+		}
+	}
+
+	for _, l := range []struct {
+		resource int
+		limit    unix.Rlimit
+	}{
+		{unix.RLIMIT_CPU, unix.Rlimit{Cur: cpu, Max: cpu + sandboxCPUGrace}},
+		{unix.RLIMIT_AS, unix.Rlimit{Cur: memory, Max: memory}},
+		{unix.RLIMIT_FSIZE, unix.Rlimit{Cur: fileSize, Max: fileSize}},
+	} {
+		if l.limit.Cur == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(l.resource, &l.limit); err != nil {
+			fmt.Fprintf(os.Stderr, "gedoc sandbox: setting limit: %v\n", err)
+			return 2
+		}
+	}
+
+	cmd := exec.Command(tool, args[7:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+
+	err := cmd.Run()
+	if errors.Is(err, syscall.EAGAIN) {
+		fmt.Fprintf(os.Stderr, "gedoc sandbox: %s limit exceeded starting %s\n", violationProcesses, tool)
+		return 126
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gedoc sandbox: %v\n", err)
+			return 127
+		}
+		return 0
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return exitErr.ExitCode()
+	}
+
+	used := exitErr.UserTime() + exitErr.SystemTime()
+	switch sig := status.Signal(); {
+	case sig == syscall.SIGXCPU, sig == syscall.SIGKILL && cpu > 0 && uint64(used.Seconds()) >= cpu:
+		fmt.Fprintf(os.Stderr, "gedoc sandbox: %s limit exceeded by %s\n", violationCPU, tool)
+	case sig == syscall.SIGXFSZ:
+		fmt.Fprintf(os.Stderr, "gedoc sandbox: %s limit exceeded by %s\n", violationFileSize, tool)
+	}
+
+	return 128 + int(status.Signal())
+}
+
+// sandboxDevices are the devices tools may use
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// sandboxFilesystem makes root the root of the helper's mount namespace,
+// holding read only binds of paths that exist, dir writable, a few devices,
+// /proc and a /tmp of at most tmpSize bytes.  The helper is root in its user
+// namespace, so it may mount, but only within its own mount namespace
+func sandboxFilesystem(root, dir string, paths []string, tmpSize uint64) error {
+	// Nothing mounted here may propagate back to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mounting root: %w", err)
+	}
+
+	// /tmp comes first, as working directories are often beneath it
+	tmpOptions := "mode=1777"
+	if tmpSize > 0 {
+		tmpOptions += ",size=" + strconv.FormatUint(tmpSize, 10)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, tmpOptions); err != nil {
+		return fmt.Errorf("mounting /tmp: %w", err)
+	}
+
+	for _, p := range paths {
+		if err := sandboxBind(root, p, true); err != nil {
+			return err
+		}
+	}
+	for _, p := range sandboxDevices {
+		if err := sandboxBind(root, p, false); err != nil {
+			return err
+		}
+	}
+	if dir != "" {
+		if err := sandboxBind(root, dir, false); err != nil {
+			return err
+		}
+	}
+
+	// The helper leads its own PID namespace, so /proc shows only the tool.
+	// Where the host's /proc is partly hidden, as in many containers, the
+	// kernel refuses a fresh one and tools go without
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		return err
+	}
+	unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	// Switch to the new root, detaching the old one so nothing else remains
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivoting root: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("detaching old root: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("making root read only: %w", err)
+	}
+
+	if dir == "" {
+		dir = "/"
+	}
+	return os.Chdir(dir)
+}
+
+// sandboxBind binds path to the same place beneath root, read only if
+// readOnly is set.  Symbolic links are copied rather than followed, and
+// paths that don't exist are skipped
+func sandboxBind(root, path string, readOnly bool) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	case info.IsDir():
+		err = os.MkdirAll(target, 0755)
+	default:
+		err = ioutil.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("binding %s: %w", path, err)
+	}
+	if !readOnly {
+		return nil
+	}
+
+	// A remount must keep the flags the host mount was locked with
+	var st unix.Statfs_t
+	if err := unix.Statfs(target, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for _, locked := range []struct {
+		statfs int64
+		mount  uintptr
+	}{
+		{unix.ST_NOSUID, unix.MS_NOSUID},
+		{unix.ST_NODEV, unix.MS_NODEV},
+		{unix.ST_NOEXEC, unix.MS_NOEXEC},
+		{unix.ST_NOATIME, unix.MS_NOATIME},
+		{unix.ST_NODIRATIME, unix.MS_NODIRATIME},
+		{unix.ST_RELATIME, unix.MS_RELATIME},
+	} {
+		if st.Flags&locked.statfs != 0 {
+			flags |= locked.mount
+		}
+	}
+	if err := unix.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("making %s read only: %w", path, err)
+	}
+	return nil
+}
+
+// sandboxViolation returns an error describing the sandbox violation that
+// caused a tool to fail, judging by its output and any log it wrote, or nil if
+// there was none
+func sandboxViolation(logs ...[]byte) error {
+	violation := ""
+	for _, l := range logs {
+		if match := sandboxLimitPattern.FindSubmatch(l); match != nil {
+			violation = string(match[1])
+			break
+		}
+	}
+
+	for _, v := range sandboxViolationPatterns {
+		if violation != "" {
+			break
+		}
+		for _, l := range logs {
+			if v.pattern.Match(l) {
+				violation = v.violation
+				break
+			}
+		}
+	}
+
+	if violation == "" {
+		return nil
+	}
+
+	sandboxViolations.WithLabelValues(violation).Inc()
+	return &builder.Error{
+		Kind:      builder.KindSandbox,
+		Err:       fmt.Errorf("sandbox %s violation", violation),
+		Violation: violation,
+	}
+}
+
+// checkSandbox ensures tools can be started in the sandbox when it's enabled,
+// which needs unprivileged user namespaces
+func checkSandbox(ctx context.Context) error {
+	if !cfg.Sandbox {
+		return nil
+	}
+
+	output, err := runSandboxed(ctx, "", "true")
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"google.golang.org/grpc/codes"
+)
+
+// withSandbox enables the sandbox with the given limits for the duration of a
+// test, skipping it where namespaces aren't available
+func withSandbox(t *testing.T, cpu, fileSize uint64) func() {
+	previous := cfg
+	cfg.Sandbox = true
+	cfg.SandboxCPUSeconds = cpu
+	cfg.SandboxFileSizeBytes = fileSize
+	cfg.SandboxProcesses = 0
+	cfg.SandboxMemoryBytes = 0
+	cfg.SandboxReadOnlyPaths = []string{"/usr", "/bin", "/lib", "/lib64", "/etc"}
+
+	if err := checkSandbox(context.Background()); err != nil {
+		cfg = previous
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+
+	return func() { cfg = previous }
+}
+
+// TestSandboxIsolation Checks sandboxed tools get their own process and network
+// namespaces, and paranoid TeX file access
+func TestSandboxIsolation(t *testing.T) {
+	defer withSandbox(t, 10, 0)()
+
+	output, err := runSandboxed(context.Background(), "", "sh", "-c", `readlink /proc/self/ns/pid /proc/self/ns/net; echo "$openin_any$openout_any"; cat /proc/net/dev`)
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+
+	for _, ns := range []string{"pid", "net"} {
+		own, err := os.Readlink("/proc/self/ns/" + ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(output, []byte(own)) {
+			t.Errorf("Expected tool to run in a new %s namespace, got %s", ns, output)
+		}
+	}
+	if !bytes.Contains(output, []byte("pp\n")) {
+		t.Errorf("Expected paranoid file access, got %s", output)
+	}
+	if bytes.Contains(output, []byte("eth")) {
+		t.Errorf("Expected no network interfaces besides loopback, got %s", output)
+	}
+}
+
+// TestSandboxFilesystem Checks sandboxed tools can write only to their working
+// directory, and can't see the rest of the host's files
+func TestSandboxFilesystem(t *testing.T) {
+	defer withSandbox(t, 10, 0)()
+
+	secret, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Close()
+	defer os.Remove(secret.Name())
+
+	dir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := `echo written > out; test -e "$1" && echo host visible; touch /usr/gedoc 2>/dev/null && echo usr writable; touch /gedoc 2>/dev/null && echo root writable; echo done`
+	output, err := runSandboxed(context.Background(), dir, "sh", "-c", script, "sh", secret.Name())
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if string(output) != "done\n" {
+		t.Errorf("Expected only the working directory to be writable and nothing else visible, got %s", output)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "out")); err != nil || string(data) != "written\n" {
+		t.Errorf("Expected output in the working directory, got %q %v", data, err)
+	}
+}
+
+// TestSandboxLimits Checks tools breaking a resource limit are reported with
+// the right violation
+func TestSandboxLimits(t *testing.T) {
+	defer withSandbox(t, 1, 4096)()
+
+	dir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		script    string
+		violation string
+		code      codes.Code
+	}{
+		{"cpu", "while :; do :; done", violationCPU, codes.ResourceExhausted},
+		{"file size", "head -c 100000 /dev/zero > big", violationFileSize, codes.ResourceExhausted},
+	}
+
+	for _, test := range tests {
+		output, err := runSandboxed(context.Background(), dir, "sh", "-c", test.script)
+		if err == nil {
+			t.Errorf("%s: expected tool to fail", test.name)
+			continue
+		}
+
+		violation := sandboxViolation(output)
+		var be *builder.Error
+		if !errors.As(violation, &be) || be.Violation != test.violation {
+			t.Errorf("%s: expected %s violation, got %v from %s", test.name, test.violation, violation, output)
+			continue
+		}
+		if code := errorCode(violation); code != test.code {
+			t.Errorf("%s: expected code %s, got %s", test.name, test.code, code)
+		}
+	}
+}
+
+// TestSandboxProcesses Checks tools are limited to SANDBOX_PROCESSES processes
+// by a cgroup of their own, which is removed once they exit.  It needs a
+// delegated cgroup, named by SANDBOX_CGROUP
+func TestSandboxProcesses(t *testing.T) {
+	cgroup := os.Getenv("SANDBOX_CGROUP")
+	if cgroup == "" {
+		t.Skip("SANDBOX_CGROUP not set")
+	}
+	defer withSandbox(t, 10, 0)()
+	cfg.SandboxCgroup = cgroup
+	cfg.SandboxProcesses = 4
+
+	output, err := runSandboxed(context.Background(), "", "sh", "-c", "for i in 1 2 3 4 5 6 7 8; do sleep 1 & done; wait")
+	if err == nil {
+		t.Fatalf("Expected tool to fail, got %s", output)
+	}
+	var be *builder.Error
+	if violation := sandboxViolation(output); !errors.As(violation, &be) || be.Violation != violationProcesses {
+		t.Errorf("Expected processes violation, got %v from %s", violation, output)
+	}
+
+	left, err := filepath.Glob(filepath.Join(cgroup, "gedoc-*"))
+	if err != nil || len(left) > 0 {
+		t.Errorf("Expected sandbox cgroups to be removed, got %v %v", left, err)
+	}
+}
+
+// TestSandboxViolation Recognises violations reported by TeX
+func TestSandboxViolation(t *testing.T) {
+	texLog := []byte("(./main.tex\nxelatex: Not reading from /etc/passwd (openin_any = p).\n! LaTeX Error: File `/etc/passwd' not found.\n")
+
+	err := sandboxViolation([]byte("Latexmk: Errors"), texLog)
+	if code := errorCode(err); code != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for file access, got %s", code)
+	}
+
+	st := errorStatus(err)
+	if len(st.Details()) != 1 || !strings.Contains(st.Message(), violationFileAccess) {
+		t.Errorf("Expected file access error info, got %s %v", st.Message(), st.Details())
+	}
+
+	if err := sandboxViolation([]byte("! Undefined control sequence.")); err != nil {
+		t.Errorf("Expected ordinary errors not to be violations, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+)
+
+// Pools that heavy work is scheduled on
+const (
+	poolLatex = string(builder.WorkLatex)
+	poolImage = string(builder.WorkImage)
+	poolMerge = string(builder.WorkMerge)
+	// poolRender runs Ghostscript to render, preview and optimize PDFs
+	poolRender = "render"
+	// poolOCR runs text extraction and OCR
+	poolOCR = "ocr"
+)
+
+// pool Limits how many jobs of one kind run at once.  Jobs beyond the limit
+// wait in a bounded queue, and are started in the order they arrived
+type pool struct {
+	name     string
+	limit    int
+	maxQueue int
+	maxWait  time.Duration
+
+	mu      sync.Mutex
+	active  int
+	waiting *list.List
+}
+
+// scheduler Holds a pool for each kind of heavy work
+type scheduler struct {
+	pools map[string]*pool
+}
+
+// sched schedules all heavy work in the server.  It is recreated from the config
+// at startup
+var sched = newScheduler()
+
+// newScheduler creates the pools with the configured limits.  A limit of zero
+// defaults to the number of CPUs
+func newScheduler() *scheduler {
+	limit := func(n int) int {
+		if n <= 0 {
+			return runtime.NumCPU()
+		}
+		return n
+	}
+
+	s := &scheduler{pools: map[string]*pool{}}
+	for name, n := range map[string]int{
+		poolLatex:  cfg.LatexConcurrency,
+		poolImage:  cfg.ImageConcurrency,
+		poolMerge:  cfg.MergeConcurrency,
+		poolRender: cfg.RenderConcurrency,
+		poolOCR:    cfg.OCRConcurrency,
+	} {
+		s.pools[name] = newPool(name, limit(n), cfg.QueueSize, cfg.QueueMaxWait)
+	}
+
+	return s
+}
+
+func newPool(name string, limit int, maxQueue int, maxWait time.Duration) *pool {
+	return &pool{
+		name:     name,
+		limit:    limit,
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+		waiting:  list.New(),
+	}
+}
+
+// acquire waits for a slot in the named pool, returning a function that frees
+// it again
+func (s *scheduler) acquire(ctx context.Context, name string) (func(), error) {
+	return s.pools[name].acquire(ctx)
+}
+
+// Acquire waits for a slot in the pool for work, so that the scheduler limits
+// the builder's work
+func (s *scheduler) Acquire(ctx context.Context, work builder.Work) (func(), error) {
+	return s.acquire(ctx, string(work))
+}
+
+// saturated returns the names of any pools whose queue is full
+func (s *scheduler) saturated() []string {
+	var full []string
+	for name, p := range s.pools {
+		p.mu.Lock()
+		if p.waiting.Len() >= p.maxQueue {
+			full = append(full, name)
+		}
+		p.mu.Unlock()
+	}
+	return full
+}
+
+// acquire waits for a free slot, returning a function that frees it again.  It
+// fails straight away if the queue is full, and once the slot has been waited
+// for longer than the pool allows
+func (p *pool) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+
+	p.mu.Lock()
+	if p.active < p.limit && p.waiting.Len() == 0 {
+		p.active++
+		p.mu.Unlock()
+		p.started(start)
+		return p.release, nil
+	}
+
+	if p.waiting.Len() >= p.maxQueue {
+		p.mu.Unlock()
+		schedulerRejections.WithLabelValues(p.name, "queue_full").Inc()
+		return nil, &builder.Error{Kind: builder.KindResourceExhausted, Err: fmt.Errorf("%s queue is full", p.name)}
+	}
+
+	ready := make(chan struct{})
+	element := p.waiting.PushBack(ready)
+	queueDepth.WithLabelValues(p.name).Set(float64(p.waiting.Len()))
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.maxWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		p.started(start)
+		return p.release, nil
+	case <-timer.C:
+		schedulerRejections.WithLabelValues(p.name, "timeout").Inc()
+		err = &builder.Error{Kind: builder.KindResourceExhausted, Err: fmt.Errorf("timed out after %s waiting for %s slot", p.maxWait, p.name)}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-ready:
+		// The slot was handed over while giving up, so pass it on
+		p.releaseLocked()
+	default:
+		p.waiting.Remove(element)
+		queueDepth.WithLabelValues(p.name).Set(float64(p.waiting.Len()))
+	}
+
+	return nil, err
+}
+
+// started records that a job has been given a slot
+func (p *pool) started(queued time.Time) {
+	queueWait.WithLabelValues(p.name).Observe(time.Since(queued).Seconds())
+	poolActive.WithLabelValues(p.name).Inc()
+}
+
+func (p *pool) release() {
+	poolActive.WithLabelValues(p.name).Dec()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.releaseLocked()
+}
+
+// releaseLocked hands a slot to the longest waiting job, or frees it if none
+// are waiting
+func (p *pool) releaseLocked() {
+	front := p.waiting.Front()
+	if front == nil {
+		p.active--
+		return
+	}
+
+	p.waiting.Remove(front)
+	queueDepth.WithLabelValues(p.name).Set(float64(p.waiting.Len()))
+	close(front.Value.(chan struct{}))
+}
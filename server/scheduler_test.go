@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/grpc/codes"
+)
+
+// TestPoolOrder Checks waiting jobs are started in the order they arrived
+func TestPoolOrder(t *testing.T) {
+	p := newPool("test", 1, 10, time.Minute)
+
+	release, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		go func() {
+			release, err := p.acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			started <- i
+			release()
+		}()
+
+		// Wait for each job to join the queue before starting the next
+		for waiting(p) != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	release()
+
+	for i := 0; i < 3; i++ {
+		if n := <-started; n != i {
+			t.Errorf("Expected job %d to start next, got %d", i, n)
+		}
+	}
+}
+
+// TestPoolRejections Checks jobs are rejected once the queue is full, or after
+// waiting too long
+func TestPoolRejections(t *testing.T) {
+	p := newPool("test", 1, 1, 50*time.Millisecond)
+
+	release, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	timedOut := make(chan error)
+	go func() {
+		_, err := p.acquire(context.Background())
+		timedOut <- err
+	}()
+	for waiting(p) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = p.acquire(context.Background())
+	if code := errorCode(err); code != codes.ResourceExhausted {
+		t.Errorf("Expected full queue to be rejected with ResourceExhausted, got %s", code)
+	}
+
+	if code := errorCode(<-timedOut); code != codes.ResourceExhausted {
+		t.Errorf("Expected wait to time out with ResourceExhausted, got %s", code)
+	}
+
+	if n := waiting(p); n != 0 {
+		t.Errorf("Expected timed out job to leave the queue, but %d waiting", n)
+	}
+}
+
+// TestPoolCancel Checks a cancelled job gives up its place without leaking a
+// slot
+func TestPoolCancel(t *testing.T) {
+	p := newPool("test", 1, 1, time.Minute)
+
+	release, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.acquire(ctx); err != context.Canceled {
+		t.Errorf("Expected cancellation, got %v", err)
+	}
+
+	release()
+
+	release, err = p.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Expected slot to be free, got %v", err)
+	}
+	release()
+}
+
+func waiting(p *pool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waiting.Len()
+}
+
+// TestHeavyWorkPools Checks rendering, optimization and text extraction wait
+// for their pools before running any tools
+func TestHeavyWorkPools(t *testing.T) {
+	previous := sched
+	defer func() { sched = previous }()
+	sched = &scheduler{pools: map[string]*pool{
+		poolRender: newPool(poolRender, 1, 0, time.Second),
+		poolOCR:    newPool(poolOCR, 1, 0, time.Second),
+	}}
+
+	for _, name := range []string{poolRender, poolOCR} {
+		release, err := sched.acquire(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+
+	ctx := context.Background()
+	pdf := []byte("%PDF-1.5")
+	work := map[string]func() error{
+		"render": func() error {
+			_, err := renderPDF(ctx, pdf, nil, nil)
+			return err
+		},
+		"optimize": func() error {
+			_, _, err := optimizePDF(ctx, pdf, pb.OptimizePreset_SCREEN)
+			return err
+		},
+		"pdf text": func() error {
+			_, err := pdfText(ctx, pdf)
+			return err
+		},
+		"ocr": func() error {
+			_, err := ocrImageText(ctx, []byte("png"), "eng")
+			return err
+		},
+	}
+
+	for name, run := range work {
+		if code := errorCode(run()); code != codes.ResourceExhausted {
+			t.Errorf("%s: expected to be held back by its pool, got %s", name, code)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/rs/zerolog/log"
+)
+
+// selfTestTimeout limits how long the whole self test may take
+const selfTestTimeout = 2 * time.Minute
+
+var (
+	//go:embed selftest/canary.tex
+	canaryTex []byte
+	//go:embed selftest/canary.png
+	canaryPNG []byte
+)
+
+// selfTestStep The outcome of one stage of the self test
+type selfTestStep struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// selfTestResult The outcome of a complete self test run
+type selfTestResult struct {
+	Passed   bool           `json:"passed"`
+	Started  time.Time      `json:"started"`
+	Duration time.Duration  `json:"duration"`
+	Steps    []selfTestStep `json:"steps"`
+}
+
+// selfTestState Holds the most recent self test result, and ensures only one
+// run happens at a time
+type selfTestState struct {
+	run    sync.Mutex
+	mu     sync.RWMutex
+	result *selfTestResult
+}
+
+var selfTest = &selfTestState{}
+
+// last returns the most recent result, or nil if the self test hasn't run
+func (s *selfTestState) last() *selfTestResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.result
+}
+
+// runSelfTest builds an embedded LaTeX document, converts an embedded image
+// with ImageMagick, and merges both with force_even, exercising every tool a
+// real request may need.  Readiness is rechecked once it completes
+func runSelfTest(ctx context.Context) *selfTestResult {
+	selfTest.run.Lock()
+	defer selfTest.run.Unlock()
+
+	ctx, span := tracer.Start(ctx, "runSelfTest")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	result := &selfTestResult{Started: time.Now(), Passed: true}
+	var tex, img []byte
+
+	step := func(name string, f func() error) {
+		if !result.Passed {
+			return
+		}
+
+		start := time.Now()
+		err := f()
+		s := selfTestStep{Name: name, Passed: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			s.Error = err.Error()
+			result.Passed = false
+		}
+		result.Steps = append(result.Steps, s)
+	}
+
+	step("build_latex", func() error {
+		var err error
+		tex, _, err = buildLatexPDF(ctx, []*pb.File{{Name: "canary.tex", Data: canaryTex}})
+		return err
+	})
+
+	step("convert_image", func() error {
+		var err error
+		img, err = newBuilder(builder.WithNativeImages(false)).ImageToPDF(ctx, canaryPNG)
+		return err
+	})
+
+	step("merge_force_even", func() error {
+		b := newBuilder()
+		merged, err := b.Merge(ctx, []builder.File{
+			{Name: "canary.pdf", Data: tex},
+			{Name: "canary-image.pdf", Data: img},
+		}, builder.MergeOptions{ForceEven: true})
+		if err != nil {
+			return err
+		}
+
+		return checkCanaryMerge(ctx, b, merged.Data)
+	})
+
+	result.Duration = time.Since(result.Started)
+
+	selfTest.mu.Lock()
+	selfTest.result = result
+	selfTest.mu.Unlock()
+
+	log.Info().Bool("passed", result.Passed).Interface("steps", result.Steps).Msg("self test complete")
+	requestReadinessCheck()
+
+	return result
+}
+
+// checkCanaryMerge ensures both single page canaries were padded with a blank
+// page when merged
+func checkCanaryMerge(ctx context.Context, b *builder.Builder, merged []byte) error {
+	pages, err := b.PageCount(ctx, merged)
+	if err != nil {
+		return err
+	}
+
+	if pages != 4 {
+		return fmt.Errorf("expected 4 pages in merged canary, got %d", pages)
+	}
+
+	return nil
+}
+
+// checkSelfTest fails until a self test has passed, and whenever the most
+// recent run failed
+func checkSelfTest(_ context.Context) error {
+	result := selfTest.last()
+
+	if result == nil {
+		if cfg.SelfTest {
+			return errors.New("self test has not completed")
+		}
+		return nil
+	}
+
+	if !result.Passed {
+		return errors.New("self test failed")
+	}
+
+	return nil
+}
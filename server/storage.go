@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// outputStore Stores PDFs somewhere other than the reply
+type outputStore interface {
+	// check returns an error if target can't be written to, so that bad
+	// targets are rejected before any work is done
+	check(ctx context.Context, target *pb.OutputTarget) error
+	// put stores data at target, beneath the calling client's prefix, returning
+	// a URI for where it was stored
+	put(ctx context.Context, target *pb.OutputTarget, data []byte) (string, error)
+}
+
+// outputStores holds the configured storage backends.  INLINE is handled by
+// returning the PDF in the reply, so never has a store
+var outputStores = map[pb.StorageBackend]outputStore{}
+
+// configureOutputStores sets up the storage backends enabled in the config.
+// s3 is nil when no S3 endpoint is configured
+func configureOutputStores(s3 *minio.Client) {
+	if cfg.OutputDir != "" {
+		outputStores[pb.StorageBackend_FILESYSTEM] = &fileStore{root: cfg.OutputDir}
+	}
+
+	if len(cfg.OutputS3Buckets) > 0 {
+		if s3 == nil {
+			log.Warn().Msg("OUTPUT_S3_BUCKETS is set without S3_ENDPOINT, so s3 output is refused")
+		} else {
+			outputStores[pb.StorageBackend_S3] = &s3Store{client: s3, buckets: allowlist(cfg.OutputS3Buckets)}
+		}
+	}
+}
+
+// clientPrefix returns the path segment beneath which the calling client's
+// output is stored, so that clients can't overwrite each other's PDFs
+func clientPrefix(ctx context.Context) string {
+	prefix := url.PathEscape(clientFromContext(ctx))
+	// Escaping leaves dots alone, which would otherwise allow . and ..
+	if strings.HasPrefix(prefix, ".") {
+		prefix = "%2E" + prefix[1:]
+	}
+	return prefix
+}
+
+// checkOutputTarget returns an InvalidArgument error if target names a backend
+// that isn't configured, or a location it can't write to
+func checkOutputTarget(ctx context.Context, target *pb.OutputTarget) error {
+	if target == nil || target.Backend == pb.StorageBackend_INLINE {
+		return nil
+	}
+
+	store, ok := outputStores[target.Backend]
+	if !ok {
+		return invalidInput("storage backend %s is not configured", target.Backend)
+	}
+
+	return store.check(ctx, target)
+}
+
+// storeOutput moves the PDF in a successful reply to target, replacing its
+// data with the location it was stored at.  The checksum and size already in
+// the reply still describe the stored PDF
+func storeOutput(ctx context.Context, reply *pb.FileReply, target *pb.OutputTarget) error {
+	if target == nil || target.Backend == pb.StorageBackend_INLINE {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "storeOutput")
+	defer span.End()
+
+	if err := checkOutputTarget(ctx, target); err != nil {
+		return err
+	}
+
+	location, err := outputStores[target.Backend].put(ctx, target, reply.Data)
+	if err != nil {
+		return fmt.Errorf("storing output in %s: %w", target.Backend, err)
+	}
+	span.SetAttributes(attribute.String("gedoc.output_location", location))
+
+	log.Info().
+		Str("location", location).
+		Int64("size_bytes", reply.SizeBytes).
+		Str("client", clientFromContext(ctx)).
+		Msg("stored output")
+
+	reply.Location = location
+	reply.Data = nil
+
+	return nil
+}
+
+// fileStore Writes PDFs beneath a directory, which may be a shared mount, in
+// a subdirectory for each client
+type fileStore struct {
+	root string
+}
+
+// resolve returns where the target's path lives beneath the client's
+// directory, refusing paths that would escape it
+func (s *fileStore) resolve(ctx context.Context, target *pb.OutputTarget) (string, error) {
+	if target.Path == "" {
+		return "", invalidInput("output path is required")
+	}
+
+	clean := filepath.Clean("/" + target.Path)
+	if clean == "/" {
+		return "", invalidInput("output path %q names no file", target.Path)
+	}
+
+	return filepath.Join(s.root, clientPrefix(ctx), clean), nil
+}
+
+func (s *fileStore) check(ctx context.Context, target *pb.OutputTarget) error {
+	_, err := s.resolve(ctx, target)
+	return err
+}
+
+// put writes data to a temporary file first, so that readers of the shared
+// directory never see part of a PDF
+func (s *fileStore) put(ctx context.Context, target *pb.OutputTarget, data []byte) (string, error) {
+	path, err := s.resolve(ctx, target)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String(), nil
+}
+
+// s3Store Uploads PDFs to the allowed buckets of an S3-compatible service,
+// such as MinIO, with keys beneath a prefix for each client
+type s3Store struct {
+	client  *minio.Client
+	buckets map[string]bool
+}
+
+// newS3Client returns a client for an S3-compatible service, shared by output
+// storage and input fetching
+func newS3Client(endpoint string, region string, accessKeyID string, secretAccessKey string, useSSL bool) (*minio.Client, error) {
+	// minio expects a host, but an endpoint given as a URL is accepted too
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+		useSSL = u.Scheme == "https"
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating s3 client: %w", err)
+	}
+
+	return client, nil
+}
+
+func (s *s3Store) check(_ context.Context, target *pb.OutputTarget) error {
+	if target.Bucket == "" || strings.Trim(target.Key, "/") == "" {
+		return invalidInput("output bucket and key are required")
+	}
+	if !s.buckets[target.Bucket] {
+		return &builder.Error{Kind: builder.KindPermissionDenied, Err: fmt.Errorf("storing in bucket %s is not allowed", target.Bucket), Field: "output.bucket"}
+	}
+	return nil
+}
+
+// key returns the object key for target, beneath the client's prefix
+func (s *s3Store) key(ctx context.Context, target *pb.OutputTarget) string {
+	return clientPrefix(ctx) + "/" + strings.TrimLeft(target.Key, "/")
+}
+
+func (s *s3Store) put(ctx context.Context, target *pb.OutputTarget, data []byte) (string, error) {
+	key := s.key(ctx, target)
+	_, err := s.client.PutObject(ctx, target.Bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:    "application/pdf",
+		SendContentMd5: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return (&url.URL{Scheme: "s3", Host: target.Bucket, Path: "/" + key}).String(), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"google.golang.org/grpc/codes"
+)
+
+// TestFileStore Checks PDFs are written beneath each client's directory in
+// the root, and can't escape it
+func TestFileStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := &fileStore{root: root}
+
+	tests := []struct {
+		client string
+		path   string
+		want   string
+	}{
+		{"billing", "reports/jan.pdf", "billing/reports/jan.pdf"},
+		{"billing", "/abs.pdf", "billing/abs.pdf"},
+		{"billing", "../../etc/escape.pdf", "billing/etc/escape.pdf"},
+		{"reports", "reports/jan.pdf", "reports/reports/jan.pdf"},
+		{"..", "../escape.pdf", "%2E./escape.pdf"},
+		{"a/b", "c.pdf", "a%2Fb/c.pdf"},
+	}
+
+	for _, test := range tests {
+		ctx := context.WithValue(context.Background(), clientKey{}, test.client)
+		location, err := store.put(ctx, &pb.OutputTarget{Path: test.path}, []byte("%PDF-1.5"))
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+
+		want := filepath.Join(root, test.want)
+		if location != (&url.URL{Scheme: "file", Path: want}).String() {
+			t.Errorf("%s: expected location %s, got %s", test.path, want, location)
+		}
+		if data, err := ioutil.ReadFile(want); err != nil || string(data) != "%PDF-1.5" {
+			t.Errorf("%s: expected PDF at %s, got %q, %v", test.path, want, data, err)
+		}
+	}
+
+	for _, path := range []string{"", "/", ".."} {
+		if err := store.check(context.Background(), &pb.OutputTarget{Path: path}); errorCode(err) != codes.InvalidArgument {
+			t.Errorf("%q: expected InvalidArgument, got %v", path, err)
+		}
+	}
+}
+
+// TestS3Store Checks PDFs are uploaded to the bucket and key requested,
+// beneath the client's prefix, and only to allowed buckets
+func TestS3Store(t *testing.T) {
+	var method, path, contentType, length string
+	var body []byte
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.Path, r.Header.Get("Content-Type")
+		length = r.Header.Get("X-Amz-Decoded-Content-Length")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer s3.Close()
+
+	client, err := newS3Client(s3.URL, "us-east-1", "key", "secret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &s3Store{client: client, buckets: map[string]bool{"archive": true}}
+
+	ctx := context.WithValue(context.Background(), clientKey{}, "billing")
+	location, err := store.put(ctx, &pb.OutputTarget{Bucket: "archive", Key: "2026/report.pdf"}, []byte("%PDF-1.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location != "s3://archive/billing/2026/report.pdf" {
+		t.Errorf("Expected s3 location beneath the client, got %s", location)
+	}
+	if method != http.MethodPut || path != "/archive/billing/2026/report.pdf" || contentType != "application/pdf" {
+		t.Errorf("Expected PDF put to bucket path, got %s %s %s", method, path, contentType)
+	}
+	// Without TLS the body is sent in signed chunks
+	if length != "8" || !bytes.Contains(body, []byte("\r\n%PDF-1.5\r\n")) {
+		t.Errorf("Expected PDF body, got %q with length %s", body, length)
+	}
+
+	if err := store.check(ctx, &pb.OutputTarget{Bucket: "archive"}); errorCode(err) != codes.InvalidArgument {
+		t.Errorf("Expected missing key to be rejected, got %v", err)
+	}
+	if err := store.check(ctx, &pb.OutputTarget{Bucket: "other", Key: "report.pdf"}); errorCode(err) != codes.PermissionDenied {
+		t.Errorf("Expected bucket outside the allowlist to be refused, got %v", err)
+	}
+}
+
+// TestStoreOutput Checks stored PDFs are replaced by their location in the
+// reply, and unconfigured backends are rejected
+func TestStoreOutput(t *testing.T) {
+	root, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	outputStores[pb.StorageBackend_FILESYSTEM] = &fileStore{root: root}
+	defer delete(outputStores, pb.StorageBackend_FILESYSTEM)
+
+	reply := &pb.FileReply{Data: []byte("%PDF-1.5"), Success: true}
+	describeOutput(reply, 1, time.Now())
+
+	target := &pb.OutputTarget{Backend: pb.StorageBackend_FILESYSTEM, Path: "out.pdf"}
+	if err := storeOutput(context.Background(), reply, target); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Data != nil || reply.Location != "file://"+filepath.Join(root, anonymousClient, "out.pdf") || reply.SizeBytes != 8 {
+		t.Errorf("Expected location in place of data, got %q at %s", reply.Data, reply.Location)
+	}
+
+	if err := checkOutputTarget(context.Background(), &pb.OutputTarget{Backend: pb.StorageBackend_S3}); errorCode(err) != codes.InvalidArgument {
+		t.Errorf("Expected unconfigured backend to be rejected, got %v", err)
+	}
+	if err := checkOutputTarget(context.Background(), nil); err != nil {
+		t.Errorf("Expected inline output by default, got %v", err)
+	}
+}
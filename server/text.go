@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	pb "github.com/episub/gedoc/gedoc/lib"
+	"github.com/h2non/filetype"
+)
+
+// ocrDPI is the resolution PDF pages are rendered at before running OCR
+const ocrDPI = 300
+
+// extractText returns the text of each page in file, which may be a PDF or,
+// when OCR is enabled, an image
+func extractText(ctx context.Context, file *pb.File, ocr *pb.OCROptions) ([]*pb.PageText, error) {
+	ctx, span := tracer.Start(ctx, "extractText")
+	defer span.End()
+
+	if file == nil || len(file.Data) == 0 {
+		return nil, invalidInput("must provide a file")
+	}
+
+	ocrEnabled := ocr != nil && ocr.Enabled
+	language, err := builder.OCRLanguage(ocr.GetLanguage())
+	if err != nil {
+		return nil, err
+	}
+
+	kind, unknown := filetype.Match(file.Data)
+	if unknown != nil {
+		return nil, &builder.Error{Kind: builder.KindUnsupportedFile, Err: fmt.Errorf("file type for %s unsupported", file.Name), Field: "file.data"}
+	}
+
+	switch kind.Extension {
+	case "pdf":
+		pages, err := pdfText(ctx, file.Data)
+		if err != nil {
+			return nil, err
+		}
+		if ocrEnabled {
+			err = ocrEmptyPages(ctx, file.Data, pages, language)
+		}
+		return pages, err
+	case "jpg", "png":
+		if !ocrEnabled {
+			return nil, invalidInput("text can only be extracted from image %s with ocr enabled", file.Name)
+		}
+		text, err := ocrImageText(ctx, file.Data, language)
+		if err != nil {
+			return nil, err
+		}
+		return []*pb.PageText{{Page: 1, Text: text}}, nil
+	}
+
+	return nil, &builder.Error{Kind: builder.KindUnsupportedFile, Err: fmt.Errorf("file type %s for %s unsupported", kind.Extension, file.Name), Field: "file.data"}
+}
+
+// pdfText extracts the text layer of each page with pdftotext
+func pdfText(ctx context.Context, pdf []byte) ([]*pb.PageText, error) {
+	release, err := sched.acquire(ctx, poolOCR)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("pdfText")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "input.pdf"), pdf, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	output, err := b.RunTool(ctx, directory, "pdftotext", "-layout", "-enc", "UTF-8", "input.pdf", "output.txt")
+	if err != nil {
+		return nil, fmt.Errorf("extracting text: %w: %s", err, output)
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(directory, "output.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	// pdftotext ends every page, including the last, with a form feed
+	split := strings.Split(string(text), "\f")
+	if len(split) > 1 && split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+
+	var pages []*pb.PageText
+	for i, t := range split {
+		pages = append(pages, &pb.PageText{Page: int32(i + 1), Text: t})
+	}
+
+	return pages, nil
+}
+
+// ocrEmptyPages runs OCR over any pages without a text layer, which is
+// typical of scanned documents
+func ocrEmptyPages(ctx context.Context, pdf []byte, pages []*pb.PageText, language string) error {
+	var empty []int32
+	for _, p := range pages {
+		if strings.TrimSpace(p.Text) == "" {
+			empty = append(empty, p.Page)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+
+	images, err := renderPDF(ctx, pdf, empty, &pb.RenderOptions{Dpi: ocrDPI})
+	if err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		text, err := ocrImageText(ctx, img.Data, language)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", img.Page, err)
+		}
+		pages[img.Page-1].Text = text
+	}
+
+	return nil
+}
+
+// ocrImageText returns the text Tesseract recognises in an image
+func ocrImageText(ctx context.Context, img []byte, language string) (string, error) {
+	release, err := sched.acquire(ctx, poolOCR)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	b := newBuilder()
+	directory, cleanup, err := b.WorkDir("ocrImageText")
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "img"), img, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	// Tesseract appends the extension for the chosen output format itself
+	output, err := b.RunTool(ctx, directory, "tesseract", "img", "output", "-l", language, "txt")
+	if err != nil {
+		return "", fmt.Errorf("running ocr: %w: %s", err, output)
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join(directory, "output.txt"))
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
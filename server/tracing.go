@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/episub/gedoc/gedoc/builder"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace exporters that may be selected with TRACE_EXPORTER
+const (
+	exporterOTLP   = "otlp"
+	exporterStdout = "stdout"
+	exporterNone   = "none"
+)
+
+const (
+	// traceShutdownTimeout limits how long buffered spans may take to flush
+	// when the server stops
+	traceShutdownTimeout = 5 * time.Second
+	// stderrTailSize is how much of the end of a tool's error output is
+	// recorded on its span
+	stderrTailSize = 1024
+	redacted       = "[REDACTED]"
+)
+
+// tracer creates every span in the server.  It uses the global provider, so
+// spans are only exported once initTracing has been called
+var tracer = otel.Tracer("github.com/episub/gedoc/server")
+
+// secretArgPattern matches command line flags whose values shouldn't be
+// recorded
+var secretArgPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|key)`)
+
+// initTracing installs the global tracer provider using the configured
+// exporter, returning a function that flushes any buffered spans.  The OTLP
+// exporter is configured with the standard OTEL_EXPORTER_OTLP_* variables, and
+// doesn't need a collector to be running: spans that can't be delivered are
+// dropped quietly
+func initTracing(ctx context.Context, service string) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.TraceExporter {
+	case exporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	case exporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case exporterNone:
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.TraceExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter: %w", cfg.TraceExporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(service),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	// Failing to reach a collector is expected when none is deployed, so
+	// export errors are only of interest while debugging
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Debug().Err(err).Msg("tracing")
+	}))
+
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, traceShutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// startCommandSpan starts a child span for running an external tool, recording
+// its arguments with any secrets redacted
+func startCommandSpan(ctx context.Context, name string, args []string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		semconv.ProcessExecutableName(name),
+		semconv.ProcessCommandArgs(append([]string{name}, redactArgs(args)...)...),
+	))
+}
+
+// endCommandSpan records how the tool run by startCommandSpan finished, and
+// ends its span
+func endCommandSpan(span trace.Span, start time.Time, stderr []byte, err error) {
+	span.SetAttributes(
+		attribute.Int64("process.duration_ms", time.Since(start).Milliseconds()),
+		attribute.String("process.stderr_tail", tail(stderr, stderrTailSize)),
+	)
+
+	if err != nil {
+		if code := builder.ExitCode(err); code >= 0 {
+			span.SetAttributes(semconv.ProcessExitCode(code))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(semconv.ProcessExitCode(0))
+	}
+
+	span.End()
+}
+
+// redactArgs returns a copy of args with the values of any secret looking
+// flags replaced, whether given as --flag=value or --flag value
+func redactArgs(args []string) []string {
+	safe := make([]string, len(args))
+	redactNext := false
+
+	for i, arg := range args {
+		switch {
+		case redactNext:
+			safe[i] = redacted
+			redactNext = false
+		case strings.HasPrefix(arg, "-") && secretArgPattern.MatchString(arg):
+			if eq := strings.Index(arg, "="); eq >= 0 {
+				safe[i] = arg[:eq+1] + redacted
+			} else {
+				safe[i] = arg
+				redactNext = true
+			}
+		default:
+			safe[i] = arg
+		}
+	}
+
+	return safe
+}
+
+// tail returns at most the last n bytes of b
+func tail(b []byte, n int) string {
+	if len(b) > n {
+		b = b[len(b)-n:]
+	}
+	return string(b)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestRedactArgs Checks secret flag values are hidden in both forms
+func TestRedactArgs(t *testing.T) {
+	args := []string{"--decrypt", "--password=hunter2", "-sOwnerPassword", "hunter2", "--api-key", "abc", "in.pdf", "out.pdf"}
+	expected := []string{"--decrypt", "--password=[REDACTED]", "-sOwnerPassword", "[REDACTED]", "--api-key", "[REDACTED]", "in.pdf", "out.pdf"}
+
+	if safe := redactArgs(args); !reflect.DeepEqual(safe, expected) {
+		t.Errorf("Expected %v, got %v", expected, safe)
+	}
+}
+
+// TestCommandSpan Checks each external process gets a span recording how it
+// was run and how it finished
+func TestCommandSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	_, err := newBuilder().RunTool(context.Background(), "", "sh", "-c", "echo failed >&2; exit 2", "--token=abc")
+	if err == nil {
+		t.Fatal("Expected command to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+
+	attributes := map[string]interface{}{}
+	for _, kv := range spans[0].Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+
+	if code := attributes["process.exit.code"]; code != int64(2) {
+		t.Errorf("Expected exit code 2, got %v", code)
+	}
+	if stderr := attributes["process.stderr_tail"]; stderr != "failed\n" {
+		t.Errorf("Expected stderr tail to be recorded, got %q", stderr)
+	}
+
+	args, _ := attributes["process.command_args"].([]string)
+	if len(args) == 0 || args[len(args)-1] != "--token=[REDACTED]" {
+		t.Errorf("Expected token to be redacted, got %v", args)
+	}
+}